@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+// Principal is the authenticated user making a request
+type Principal struct {
+	UserID int
+	Email  string
+	Name   string
+	Role   string // Global role: "app_admin", "stakeholder", or "user"
+}
+
+// IsAdmin reports whether the principal is an app admin
+func (p *Principal) IsAdmin() bool {
+	return p.Role == "app_admin"
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// FromRequest returns the principal of an authenticated request, if any
+func FromRequest(r *http.Request) (*Principal, bool) {
+	return FromContext(r.Context())
+}
@@ -0,0 +1,233 @@
+package authz
+
+import (
+	"bytes"
+	"canny-clone/auth"
+	"canny-clone/repositories"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// ErrForbidden is returned when the principal lacks a permission
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when the resource being authorized does not exist
+	ErrNotFound = errors.New("resource not found")
+	// ErrBadRequest is returned when the resource cannot be identified from the request
+	ErrBadRequest = errors.New("invalid resource ID")
+)
+
+// BoardResolver finds the board a request's resource belongs to.
+// It returns 0 for resources that are not scoped to a board.
+type BoardResolver func(r *http.Request) (int, error)
+
+// BoardRole returns the principal's role on a board, or "" if they are not a member
+func BoardRole(p *auth.Principal, boardID int) (string, error) {
+	boardRoles, err := repositories.NewUserRepository().GetUserBoardRoles(p.UserID)
+	if err != nil {
+		return "", err
+	}
+	return boardRoles[boardID], nil
+}
+
+// Authorize checks whether the principal holds the permission on a board's resource
+func Authorize(p *auth.Principal, perm Permission, boardID int) error {
+	if grantedGlobally(perm, p.Role) {
+		return nil
+	}
+	if boardID == 0 {
+		return ErrForbidden
+	}
+
+	boardRole, err := BoardRole(p, boardID)
+	if err != nil {
+		return err
+	}
+	if !Allowed(perm, p.Role, boardRole) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Protect wraps a handler so it only runs when the authenticated principal holds
+// the permission on the board found by resolve. It must run after services.AuthMiddleware.
+func Protect(perm Permission, resolve BoardResolver, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromRequest(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		boardID := 0
+		var err error
+		if !grantedGlobally(perm, principal.Role) {
+			boardID, err = resolve(r)
+		}
+		if err == nil {
+			err = Authorize(principal, perm, boardID)
+		}
+
+		switch {
+		case err == nil:
+			next(w, r)
+		case err == ErrForbidden:
+			http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+		case err == ErrNotFound:
+			http.Error(w, "Not found", http.StatusNotFound)
+		case err == ErrBadRequest:
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+		default:
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+	})
+}
+
+// NoBoard is used for resources that are not scoped to a board
+func NoBoard(r *http.Request) (int, error) {
+	return 0, nil
+}
+
+// BoardVar reads the board ID from a route variable
+func BoardVar(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		return parseID(mux.Vars(r)[name])
+	}
+}
+
+// BoardQuery reads the board ID from a query parameter
+func BoardQuery(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		return parseID(r.URL.Query().Get(name))
+	}
+}
+
+// BoardBody reads the board ID from a field of the JSON request body
+func BoardBody(field string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		return bodyID(r, field)
+	}
+}
+
+// FeedbackVar resolves the board of the feedback in a route variable
+func FeedbackVar(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := parseID(mux.Vars(r)[name])
+		if err != nil {
+			return 0, err
+		}
+		return feedbackBoard(id)
+	}
+}
+
+// FeedbackQuery resolves the board of the feedback in a query parameter
+func FeedbackQuery(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := parseID(r.URL.Query().Get(name))
+		if err != nil {
+			return 0, err
+		}
+		return feedbackBoard(id)
+	}
+}
+
+// FeedbackBody resolves the board of the feedback in a field of the JSON request body
+func FeedbackBody(field string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := bodyID(r, field)
+		if err != nil {
+			return 0, err
+		}
+		return feedbackBoard(id)
+	}
+}
+
+// CommentBody resolves the board of the comment in a field of the JSON request body
+func CommentBody(field string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := bodyID(r, field)
+		if err != nil {
+			return 0, err
+		}
+		return commentBoard(repositories.NewCommentRepository().GetCommentBoardID(id))
+	}
+}
+
+// CommentVar resolves the board of the comment in a route variable
+func CommentVar(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := parseID(mux.Vars(r)[name])
+		if err != nil {
+			return 0, err
+		}
+		return commentBoard(repositories.NewCommentRepository().GetCommentBoardID(id))
+	}
+}
+
+// AttachmentVar resolves the board of the attachment in a route variable
+func AttachmentVar(name string) BoardResolver {
+	return func(r *http.Request) (int, error) {
+		id, err := parseID(mux.Vars(r)[name])
+		if err != nil {
+			return 0, err
+		}
+		boardID, err := repositories.NewAttachmentRepository().GetAttachmentBoardID(id)
+		if err == repositories.ErrAttachmentNotFound {
+			return 0, ErrNotFound
+		}
+		return boardID, err
+	}
+}
+
+func feedbackBoard(feedbackID int) (int, error) {
+	feedback, err := repositories.NewFeedbackRepository().GetFeedbackByID(feedbackID)
+	if err != nil {
+		return 0, err
+	}
+	if feedback == nil {
+		return 0, ErrNotFound
+	}
+	return feedback.BoardID, nil
+}
+
+func commentBoard(boardID int, err error) (int, error) {
+	if err == repositories.ErrCommentNotFound {
+		return 0, ErrNotFound
+	}
+	return boardID, err
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, ErrBadRequest
+	}
+	return id, nil
+}
+
+// bodyID reads an integer field from the JSON request body, leaving the body
+// in place for the handler
+func bodyID(r *http.Request, field string) (int, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, ErrBadRequest
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return 0, ErrBadRequest
+	}
+
+	var id int
+	raw, ok := fields[field]
+	if !ok || json.Unmarshal(raw, &id) != nil || id <= 0 {
+		return 0, ErrBadRequest
+	}
+	return id, nil
+}
@@ -0,0 +1,147 @@
+package authz
+
+// Action is something a user does to a resource
+type Action string
+
+const (
+	ActionView     Action = "view"
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionVote     Action = "vote"
+	ActionModerate Action = "moderate"
+	ActionManage   Action = "manage"
+	ActionRestore  Action = "restore"
+	ActionPin      Action = "pin"
+)
+
+// Resource is a kind of object permissions apply to
+type Resource string
+
+const (
+	ResourceBoard        Resource = "board"
+	ResourceBoardMember  Resource = "board_member"
+	ResourceFeedback     Resource = "feedback"
+	ResourceComment      Resource = "comment"
+	ResourceInternalNote Resource = "internal_note"
+	ResourceCategory     Resource = "category"
+	ResourceUser         Resource = "user"
+	ResourceVoteCount    Resource = "vote_count"
+	ResourceOwnAccount   Resource = "own_account"
+)
+
+// Permission is an action on a resource
+type Permission struct {
+	Action   Action
+	Resource Resource
+}
+
+// Permissions used by the routes
+var (
+	ViewBoard          = Permission{ActionView, ResourceBoard}
+	CreateBoard        = Permission{ActionCreate, ResourceBoard}
+	UpdateBoard        = Permission{ActionUpdate, ResourceBoard}
+	ViewBoardMembers   = Permission{ActionView, ResourceBoardMember}
+	ManageBoardMembers = Permission{ActionManage, ResourceBoardMember}
+	ViewFeedback       = Permission{ActionView, ResourceFeedback}
+	CreateFeedback     = Permission{ActionCreate, ResourceFeedback}
+	VoteFeedback       = Permission{ActionVote, ResourceFeedback}
+	ModerateFeedback   = Permission{ActionModerate, ResourceFeedback}
+	RestoreFeedback    = Permission{ActionRestore, ResourceFeedback}
+	ViewComments       = Permission{ActionView, ResourceComment}
+	CreateComment      = Permission{ActionCreate, ResourceComment}
+	ReactToComment     = Permission{ActionVote, ResourceComment}
+	ModerateComments   = Permission{ActionModerate, ResourceComment}
+	PinComment         = Permission{ActionPin, ResourceComment}
+	ViewInternalNotes  = Permission{ActionView, ResourceInternalNote}
+	CreateInternalNote = Permission{ActionCreate, ResourceInternalNote}
+	ViewCategories     = Permission{ActionView, ResourceCategory}
+	ManageCategories   = Permission{ActionManage, ResourceCategory}
+	ManageUsers        = Permission{ActionManage, ResourceUser}
+	RecountVotes       = Permission{ActionManage, ResourceVoteCount}
+
+	// Self covers routes that only read or change the principal's own data,
+	// such as /me, so every signed-in user holds it
+	Self = Permission{ActionManage, ResourceOwnAccount}
+)
+
+// Global roles
+const (
+	RoleAppAdmin    = "app_admin"
+	RoleStakeholder = "stakeholder"
+	RoleUser        = "user"
+)
+
+// Board roles
+const (
+	BoardRoleStakeholder = "stakeholder"
+	BoardRoleUser        = "user"
+)
+
+// Rule lists the roles that are granted a permission. A global role grants it
+// everywhere; a board role grants it only on that board's resources.
+type Rule struct {
+	GlobalRoles []string
+	BoardRoles  []string
+}
+
+var (
+	signedIn         = Rule{GlobalRoles: []string{RoleAppAdmin, RoleStakeholder, RoleUser}}
+	adminOnly        = Rule{GlobalRoles: []string{RoleAppAdmin}}
+	boardMembers     = Rule{GlobalRoles: []string{RoleAppAdmin}, BoardRoles: []string{BoardRoleStakeholder, BoardRoleUser}}
+	boardStakeholder = Rule{GlobalRoles: []string{RoleAppAdmin}, BoardRoles: []string{BoardRoleStakeholder}}
+)
+
+// Policy maps every permission to the roles granted it.
+// Permissions missing from the policy are denied.
+var Policy = map[Permission]Rule{
+	ViewBoard:          boardMembers,
+	CreateBoard:        adminOnly,
+	UpdateBoard:        boardStakeholder,
+	ViewBoardMembers:   boardStakeholder,
+	ManageBoardMembers: boardStakeholder,
+	ViewFeedback:       boardMembers,
+	CreateFeedback:     boardMembers,
+	VoteFeedback:       boardMembers,
+	ModerateFeedback:   boardStakeholder,
+	RestoreFeedback:    adminOnly,
+	ViewComments:       boardMembers,
+	CreateComment:      boardMembers,
+	ReactToComment:     boardMembers,
+	ModerateComments:   boardStakeholder,
+	PinComment:         boardStakeholder,
+	ViewInternalNotes:  boardStakeholder,
+	CreateInternalNote: boardStakeholder,
+	ViewCategories:     boardMembers,
+	ManageCategories:   boardStakeholder,
+	ManageUsers:        adminOnly,
+	RecountVotes:       adminOnly,
+	Self:               signedIn,
+}
+
+// Allowed reports whether a user with the given global role, and the given role
+// on the resource's board, holds the permission. boardRole is empty for non-members.
+func Allowed(perm Permission, globalRole, boardRole string) bool {
+	rule, ok := Policy[perm]
+	if !ok {
+		return false
+	}
+	if contains(rule.GlobalRoles, globalRole) {
+		return true
+	}
+	return boardRole != "" && contains(rule.BoardRoles, boardRole)
+}
+
+// grantedGlobally reports whether the global role alone grants the permission
+func grantedGlobally(perm Permission, globalRole string) bool {
+	rule, ok := Policy[perm]
+	return ok && contains(rule.GlobalRoles, globalRole)
+}
+
+func contains(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
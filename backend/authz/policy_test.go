@@ -0,0 +1,129 @@
+package authz
+
+import (
+	"canny-clone/auth"
+	"testing"
+)
+
+// grants is who holds a permission: any signed-in user, app admins everywhere,
+// and board stakeholders or board users on their own board
+type grants struct {
+	signedIn, admin, boardStakeholder, boardUser bool
+}
+
+var (
+	anyUser          = grants{signedIn: true}
+	everyone         = grants{admin: true, boardStakeholder: true, boardUser: true}
+	stakeholdersOnly = grants{admin: true, boardStakeholder: true}
+	adminsOnly       = grants{admin: true}
+)
+
+var expectedGrants = map[Permission]grants{
+	ViewBoard:          everyone,
+	CreateBoard:        adminsOnly,
+	UpdateBoard:        stakeholdersOnly,
+	ViewBoardMembers:   stakeholdersOnly,
+	ManageBoardMembers: stakeholdersOnly,
+	ViewFeedback:       everyone,
+	CreateFeedback:     everyone,
+	VoteFeedback:       everyone,
+	ModerateFeedback:   stakeholdersOnly,
+	RestoreFeedback:    adminsOnly,
+	ViewComments:       everyone,
+	CreateComment:      everyone,
+	ReactToComment:     everyone,
+	ModerateComments:   stakeholdersOnly,
+	PinComment:         stakeholdersOnly,
+	ViewInternalNotes:  stakeholdersOnly,
+	CreateInternalNote: stakeholdersOnly,
+	ViewCategories:     everyone,
+	ManageCategories:   stakeholdersOnly,
+	ManageUsers:        adminsOnly,
+	RecountVotes:       adminsOnly,
+	Self:               anyUser,
+}
+
+var (
+	globalRoles = []string{RoleAppAdmin, RoleStakeholder, RoleUser}
+	boardRoles  = []string{"", BoardRoleStakeholder, BoardRoleUser}
+)
+
+func (g grants) allows(globalRole, boardRole string) bool {
+	switch {
+	case g.signedIn:
+		return true
+	case globalRole == RoleAppAdmin && g.admin:
+		return true
+	case boardRole == BoardRoleStakeholder:
+		return g.boardStakeholder
+	case boardRole == BoardRoleUser:
+		return g.boardUser
+	}
+	return false
+}
+
+func TestPolicyCoversEveryPermission(t *testing.T) {
+	for perm := range Policy {
+		if _, ok := expectedGrants[perm]; !ok {
+			t.Errorf("%s %s is in the policy but has no expected grants", perm.Action, perm.Resource)
+		}
+	}
+	for perm := range expectedGrants {
+		if _, ok := Policy[perm]; !ok {
+			t.Errorf("%s %s is missing from the policy", perm.Action, perm.Resource)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	for perm, g := range expectedGrants {
+		for _, globalRole := range globalRoles {
+			for _, boardRole := range boardRoles {
+				want := g.allows(globalRole, boardRole)
+				if got := Allowed(perm, globalRole, boardRole); got != want {
+					t.Errorf("Allowed(%s %s, global %q, board %q) = %v, want %v",
+						perm.Action, perm.Resource, globalRole, boardRole, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAllowedDeniesUnknownPermission(t *testing.T) {
+	perm := Permission{ActionManage, ResourceComment}
+	for _, globalRole := range globalRoles {
+		for _, boardRole := range boardRoles {
+			if Allowed(perm, globalRole, boardRole) {
+				t.Errorf("Allowed(unknown permission, global %q, board %q) = true", globalRole, boardRole)
+			}
+		}
+	}
+}
+
+// Authorize only consults board membership when the global role does not
+// already decide, so these cases need no database
+func TestAuthorizeWithoutBoard(t *testing.T) {
+	for perm, g := range expectedGrants {
+		for _, globalRole := range globalRoles {
+			p := &auth.Principal{UserID: 1, Role: globalRole}
+			err := Authorize(p, perm, 0)
+			if want := g.allows(globalRole, ""); want && err != nil {
+				t.Errorf("Authorize(%s %s, global %q, no board) = %v, want nil", perm.Action, perm.Resource, globalRole, err)
+			} else if !want && err != ErrForbidden {
+				t.Errorf("Authorize(%s %s, global %q, no board) = %v, want ErrForbidden", perm.Action, perm.Resource, globalRole, err)
+			}
+		}
+	}
+}
+
+func TestAuthorizeAdminSkipsMembership(t *testing.T) {
+	p := &auth.Principal{UserID: 1, Role: RoleAppAdmin}
+	for perm, g := range expectedGrants {
+		if !g.admin {
+			continue
+		}
+		if err := Authorize(p, perm, 42); err != nil {
+			t.Errorf("Authorize(%s %s, app admin, board 42) = %v, want nil", perm.Action, perm.Resource, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package events fans out changes on a board to the clients following it.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event is a change on a board
+type Event struct {
+	ID               uint64          `json:"id"` // Assigned when the event is published
+	BoardID          int             `json:"boardId"`
+	Type             string          `json:"type"`
+	Data             json.RawMessage `json:"data"`
+	StakeholdersOnly bool            `json:"stakeholdersOnly,omitempty"` // Only for subscribers who can see internal notes
+}
+
+// Publisher sends events to every subscriber of their board
+type Publisher interface {
+	Publish(e Event) error
+}
+
+// NewEvent builds an event whose data is v encoded as JSON
+func NewEvent(boardID int, eventType string, v interface{}) (Event, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{BoardID: boardID, Type: eventType, Data: data}, nil
+}
+
+// Broker delivers events to the local subscribers of their board and keeps the
+// most recent events of each board so reconnecting clients can catch up.
+// Replay goes by the order events were delivered in, not by ID, so IDs only
+// need to be unique.
+//
+// Delivery never blocks on subscribers: one whose buffer is full is dropped
+// and its channel closed. The client then reconnects and replays what it missed.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	historySize int
+	bufferSize  int
+	history     map[int][]Event // Recent events per board, oldest first
+	subscribers map[int]map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker that keeps historySize events per board and buffers
+// up to bufferSize events for each subscriber
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		// Start local IDs from the current time so IDs from before a restart are not reused
+		lastID:      uint64(time.Now().UnixNano()),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		history:     make(map[int][]Event),
+		subscribers: make(map[int]map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of one board
+type Subscription struct {
+	BoardID int
+	events  chan Event
+	broker  *Broker
+}
+
+// Events returns the subscription's events. The channel is closed when the
+// subscription is closed or dropped for falling behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Publish delivers the event to this process's subscribers only, with an ID
+// assigned by the broker. It is for running a single replica.
+func (b *Broker) Publish(e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	b.deliver(e)
+	return nil
+}
+
+// Deliver records an event whose ID was assigned elsewhere and sends it to the
+// board's subscribers
+func (b *Broker) Deliver(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver(e)
+}
+
+// Reset forgets every event and drops every subscriber. It is for when events
+// may have been lost: clients reconnect, find their last event unknown and reload.
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = make(map[int][]Event)
+	for _, subs := range b.subscribers {
+		for s := range subs {
+			b.remove(s)
+		}
+	}
+}
+
+// deliver records an event and fans it out; b.mu must be held
+func (b *Broker) deliver(e Event) {
+	history := append(b.history[e.BoardID], e)
+	if len(history) > b.historySize {
+		history = history[1:]
+	}
+	b.history[e.BoardID] = history
+
+	for s := range b.subscribers[e.BoardID] {
+		select {
+		case s.events <- e:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe follows a board. When resuming after lastEventID, the events
+// delivered since are returned for replay; complete is false when lastEventID
+// is no longer known, and the client should reload instead.
+func (b *Broker) Subscribe(boardID int, lastEventID uint64, resume bool) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		BoardID: boardID,
+		events:  make(chan Event, b.bufferSize),
+		broker:  b,
+	}
+	if b.subscribers[boardID] == nil {
+		b.subscribers[boardID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[boardID][sub] = struct{}{}
+
+	if !resume {
+		return sub, nil, true
+	}
+
+	history := b.history[boardID]
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == lastEventID {
+			replay = append(replay, history[i+1:]...)
+			return sub, replay, true
+		}
+	}
+	return sub, nil, false
+}
+
+// remove drops a subscription and closes its channel; b.mu must be held
+func (b *Broker) remove(s *Subscription) {
+	subs := b.subscribers[s.BoardID]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(b.subscribers, s.BoardID)
+	}
+	close(s.events)
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+// buffered returns the events waiting on sub without blocking, and whether its
+// channel has been closed
+func buffered(sub *Subscription) (events []Event, closed bool) {
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return events, true
+			}
+			events = append(events, e)
+		default:
+			return events, false
+		}
+	}
+}
+
+func eventIDs(events []Event) []uint64 {
+	ids := []uint64{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+// publish sends n events to the board and returns them as delivered
+func publish(t *testing.T, b *Broker, boardID, n int) []Event {
+	t.Helper()
+	watcher, _, _ := b.Subscribe(boardID, 0, false)
+	defer watcher.Close()
+	for i := 0; i < n; i++ {
+		if err := b.Publish(Event{BoardID: boardID, Type: "feedback_voted"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, _ := buffered(watcher)
+	if len(events) != n {
+		t.Fatalf("published %d events, delivered %d", n, len(events))
+	}
+	return events
+}
+
+func TestBrokerDeliversToBoardSubscribers(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub, _, _ := b.Subscribe(1, 0, false)
+	defer sub.Close()
+	other, _, _ := b.Subscribe(2, 0, false)
+	defer other.Close()
+
+	published := publish(t, b, 1, 3)
+	got, closed := buffered(sub)
+	if closed || !reflect.DeepEqual(got, published) {
+		t.Errorf("subscriber received %v (closed %v), want %v", eventIDs(got), closed, eventIDs(published))
+	}
+	for i := 1; i < len(published); i++ {
+		if published[i].ID <= published[i-1].ID {
+			t.Errorf("IDs not increasing: %v", eventIDs(published))
+		}
+	}
+	if got, _ := buffered(other); len(got) != 0 {
+		t.Errorf("subscriber of another board received %v", eventIDs(got))
+	}
+}
+
+func TestBrokerReplaysAfterLastEventID(t *testing.T) {
+	b := NewBroker(10, 10)
+	published := publish(t, b, 1, 4)
+	publish(t, b, 2, 2)
+
+	tests := []struct {
+		name   string
+		lastID uint64
+		want   []Event
+	}{
+		{"from the first", published[0].ID, published[1:]},
+		{"from the middle", published[2].ID, published[3:]},
+		{"from the latest", published[3].ID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := b.Subscribe(1, tt.lastID, true)
+			defer sub.Close()
+			if !complete || !reflect.DeepEqual(eventIDs(replay), eventIDs(tt.want)) {
+				t.Errorf("Subscribe() replayed %v (complete %v), want %v", eventIDs(replay), complete, eventIDs(tt.want))
+			}
+		})
+	}
+
+	// The subscription goes on receiving live events after the replay
+	sub, _, _ := b.Subscribe(1, published[3].ID, true)
+	defer sub.Close()
+	live := publish(t, b, 1, 1)
+	if got, _ := buffered(sub); !reflect.DeepEqual(got, live) {
+		t.Errorf("after replaying, received %v, want %v", eventIDs(got), eventIDs(live))
+	}
+}
+
+func TestBrokerReplayOfUnknownID(t *testing.T) {
+	b := NewBroker(2, 10)
+	published := publish(t, b, 1, 3)
+	other := publish(t, b, 2, 1)
+
+	tests := []struct {
+		name   string
+		lastID uint64
+	}{
+		{"never published", published[2].ID + 100},
+		{"aged out of the history", published[0].ID},
+		{"from another board", other[0].ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := b.Subscribe(1, tt.lastID, true)
+			defer sub.Close()
+			if complete || len(replay) != 0 {
+				t.Errorf("Subscribe() replayed %v (complete %v), want nothing and incomplete", eventIDs(replay), complete)
+			}
+			// The client is still subscribed while it reloads
+			live := publish(t, b, 1, 1)
+			if got, closed := buffered(sub); closed || !reflect.DeepEqual(got, live) {
+				t.Errorf("received %v (closed %v), want %v", eventIDs(got), closed, eventIDs(live))
+			}
+		})
+	}
+}
+
+// A subscriber that falls behind is dropped rather than holding up delivery
+func TestBrokerDropsFullSubscriber(t *testing.T) {
+	const bufferSize = 2
+	b := NewBroker(10, bufferSize)
+	slow, _, _ := b.Subscribe(1, 0, false)
+	defer slow.Close()
+	fast, _, _ := b.Subscribe(1, 0, false)
+	defer fast.Close()
+
+	var received []Event
+	var published []Event
+	for i := 0; i < bufferSize+3; i++ {
+		published = append(published, publish(t, b, 1, 1)...)
+		got, closed := buffered(fast)
+		if closed {
+			t.Fatal("subscriber that keeps up was dropped")
+		}
+		received = append(received, got...)
+	}
+	if !reflect.DeepEqual(received, published) {
+		t.Errorf("subscriber that keeps up received %v, want %v", eventIDs(received), eventIDs(published))
+	}
+
+	got, closed := buffered(slow)
+	if !closed {
+		t.Fatal("full subscriber was not closed")
+	}
+	if !reflect.DeepEqual(got, published[:bufferSize]) {
+		t.Errorf("full subscriber received %v before closing, want %v", eventIDs(got), eventIDs(published[:bufferSize]))
+	}
+
+	// It can resume from the last event it received
+	resumed, replay, complete := b.Subscribe(1, got[len(got)-1].ID, true)
+	defer resumed.Close()
+	if !complete || !reflect.DeepEqual(replay, published[bufferSize:]) {
+		t.Errorf("resuming replayed %v (complete %v), want %v", eventIDs(replay), complete, eventIDs(published[bufferSize:]))
+	}
+}
+
+func TestBrokerResetClosesSubscribers(t *testing.T) {
+	b := NewBroker(10, 10)
+	published := publish(t, b, 1, 2)
+	var subs []*Subscription
+	for _, boardID := range []int{1, 1, 2} {
+		sub, _, _ := b.Subscribe(boardID, 0, false)
+		subs = append(subs, sub)
+	}
+
+	b.Reset()
+	for i, sub := range subs {
+		if _, closed := buffered(sub); !closed {
+			t.Errorf("subscriber %d was not closed by Reset()", i)
+		}
+		// Closing a dropped subscription is harmless
+		sub.Close()
+	}
+
+	// Events from before the reset can no longer be resumed from
+	sub, replay, complete := b.Subscribe(1, published[0].ID, true)
+	defer sub.Close()
+	if complete || len(replay) != 0 {
+		t.Errorf("resuming after Reset() replayed %v (complete %v), want nothing and incomplete", eventIDs(replay), complete)
+	}
+}
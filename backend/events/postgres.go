@@ -0,0 +1,105 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// notifyChannel is the Postgres channel events travel on
+	notifyChannel = "board_events"
+
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// PostgresBus carries events between replicas with Postgres NOTIFY. Every
+// replica listens on the same channel and hands what it receives to its local
+// broker, so any replica can deliver any event. Postgres delivers notifications
+// to all listeners in the same order, which keeps Last-Event-ID replay
+// consistent across replicas.
+type PostgresBus struct {
+	db       *sql.DB
+	broker   *Broker
+	listener *pq.Listener
+}
+
+// NewPostgresBus publishes through db and listens on a dedicated connection to
+// dsn, since LISTEN needs a connection that is read continuously rather than one
+// borrowed from the pool. It reconnects on its own after the connection drops.
+func NewPostgresBus(db *sql.DB, dsn string, broker *Broker) (*PostgresBus, error) {
+	bus := &PostgresBus{db: db, broker: broker}
+	bus.listener = pq.NewListener(dsn, listenerMinReconnect, listenerMaxReconnect, bus.connectionEvent)
+	if err := bus.listener.Listen(notifyChannel); err != nil {
+		bus.listener.Close()
+		return nil, err
+	}
+	go bus.run()
+	return bus, nil
+}
+
+// Publish sends the event to every replica. Its ID comes from a database
+// sequence so it is unique across replicas.
+func (b *PostgresBus) Publish(e Event) error {
+	data := e.Data
+	if data == nil {
+		data = json.RawMessage("null")
+	}
+	_, err := b.db.Exec(`
+		SELECT pg_notify($1, json_build_object(
+			'id', nextval('board_event_ids'),
+			'boardId', $2::int,
+			'type', $3::text,
+			'data', $4::json,
+			'stakeholdersOnly', $5::boolean
+		)::text)
+	`, notifyChannel, e.BoardID, e.Type, string(data), e.StakeholdersOnly)
+	return err
+}
+
+// Close stops listening
+func (b *PostgresBus) Close() error {
+	return b.listener.Close()
+}
+
+func (b *PostgresBus) run() {
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Sent after reconnecting; anything published meanwhile was missed
+				b.broker.Reset()
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("Ignoring malformed board event: %v", err)
+				continue
+			}
+			b.broker.Deliver(e)
+
+		case <-time.After(listenerPingInterval):
+			// Notice a dead connection even when no events are flowing
+			go b.listener.Ping()
+		}
+	}
+}
+
+func (b *PostgresBus) connectionEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		log.Printf("Board event listener disconnected: %v", err)
+	case pq.ListenerEventReconnected:
+		log.Printf("Board event listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Printf("Board event listener failed to reconnect: %v", err)
+	}
+}
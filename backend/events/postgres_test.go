@@ -0,0 +1,177 @@
+package events
+
+import (
+	"canny-clone/internal/testdb"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// The bus tests run against the database in TEST_DATABASE_URL and are skipped
+// when it is not set. Each uses a board of its own, so other listeners on the
+// channel do not disturb them.
+func openTestBuses(t *testing.T, n int) (*sql.DB, []*PostgresBus) {
+	t.Helper()
+	db := testdb.Open(t)
+	dsn := testdb.URL(t)
+
+	// Each bus stands in for a replica with its own pool and broker
+	buses := make([]*PostgresBus, n)
+	for i := range buses {
+		pool, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus, err := NewPostgresBus(pool, dsn, NewBroker(100, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			bus.Close()
+			pool.Close()
+		})
+		buses[i] = bus
+	}
+	return db, buses
+}
+
+func testBoardID() int {
+	return int(time.Now().UnixNano() % 1000000000)
+}
+
+// receive waits for the next event on sub
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.Events():
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestPostgresBusDeliversAcrossReplicas(t *testing.T) {
+	_, buses := openTestBuses(t, 2)
+	boardID := testBoardID()
+
+	var subs []*Subscription
+	for _, bus := range buses {
+		sub, _, _ := bus.broker.Subscribe(boardID, 0, false)
+		defer sub.Close()
+		subs = append(subs, sub)
+	}
+
+	e, err := NewEvent(boardID, "feedback_created", map[string]int{"feedbackId": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := buses[0].Publish(e); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sub := range subs {
+		got := receive(t, sub)
+		if got.BoardID != boardID || got.Type != "feedback_created" || string(got.Data) != `{"feedbackId":7}` {
+			t.Errorf("bus %d received %+v", i, got)
+		}
+		if got.ID == 0 {
+			t.Errorf("bus %d received an event without an ID", i)
+		}
+	}
+}
+
+// Events published on either replica get distinct IDs, and every replica
+// receives them in the same order
+func TestPostgresBusIDsAreUnique(t *testing.T) {
+	_, buses := openTestBuses(t, 2)
+	boardID := testBoardID()
+
+	var subs []*Subscription
+	for _, bus := range buses {
+		sub, _, _ := bus.broker.Subscribe(boardID, 0, false)
+		defer sub.Close()
+		subs = append(subs, sub)
+	}
+
+	const published = 20
+	for i := 0; i < published; i++ {
+		if err := buses[i%2].Publish(Event{BoardID: boardID, Type: "feedback_voted"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var orders [2][]uint64
+	for i, sub := range subs {
+		seen := map[uint64]bool{}
+		for n := 0; n < published; n++ {
+			e := receive(t, sub)
+			if seen[e.ID] {
+				t.Errorf("bus %d received ID %d twice", i, e.ID)
+			}
+			seen[e.ID] = true
+			orders[i] = append(orders[i], e.ID)
+		}
+	}
+	for n := range orders[0] {
+		if orders[0][n] != orders[1][n] {
+			t.Fatalf("replicas received events in different orders: %v and %v", orders[0], orders[1])
+		}
+	}
+}
+
+// After its listener connection is killed the bus reconnects and resets its
+// broker, since anything published meanwhile was missed
+func TestPostgresBusResetsAfterReconnect(t *testing.T) {
+	db, buses := openTestBuses(t, 1)
+	bus := buses[0]
+	boardID := testBoardID()
+
+	sub, _, _ := bus.broker.Subscribe(boardID, 0, false)
+	if err := bus.Publish(Event{BoardID: boardID, Type: "feedback_created"}); err != nil {
+		t.Fatal(err)
+	}
+	before := receive(t, sub)
+
+	// Kill every connection listening for board events, this bus's included
+	var killed int
+	err := db.QueryRow(`
+		SELECT COUNT(pg_terminate_backend(pid)) FROM pg_stat_activity
+		WHERE datname = current_database() AND pid <> pg_backend_pid() AND query LIKE 'LISTEN %board_events%'
+	`).Scan(&killed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if killed == 0 {
+		t.Fatal("found no listener connection to terminate")
+	}
+
+	// Reset drops the subscriber, closing its channel
+	deadline := time.After(10 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-sub.Events():
+			closed = !ok
+		case <-deadline:
+			t.Fatal("broker was not reset after the listener reconnected")
+		}
+	}
+
+	// Events from before the reset can no longer be resumed from
+	resumed, _, complete := bus.broker.Subscribe(boardID, before.ID, true)
+	defer resumed.Close()
+	if complete {
+		t.Error("resuming from an event before the reset reported complete")
+	}
+
+	// The reconnected listener receives events again
+	if err := bus.Publish(Event{BoardID: boardID, Type: "feedback_updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, resumed); got.Type != "feedback_updated" {
+		t.Errorf("received %+v after reconnecting", got)
+	}
+}
@@ -0,0 +1,13 @@
+module canny-clone
+
+go 1.22
+
+require (
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/gorilla/mux v1.8.1
+	github.com/lib/pq v1.10.9
+	github.com/rs/cors v1.11.1
+	golang.org/x/oauth2 v0.24.0
+)
+
+require cloud.google.com/go/compute/metadata v0.3.0 // indirect
@@ -0,0 +1,98 @@
+// Package mailer renders and sends notification emails.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with plain text and HTML versions of its body
+type Message struct {
+	To             mail.Address
+	Subject        string
+	Text           string
+	HTML           string
+	UnsubscribeURL string // Sent as List-Unsubscribe, with one-click unsubscribe
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(m Message) error
+}
+
+// build encodes the message as MIME, ready for SMTP DATA
+func build(from mail.Address, m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + from.String(),
+		"To: " + m.To.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + messageID(from.Address),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + body.Boundary(),
+	}
+	if m.UnsubscribeURL != "" {
+		headers = append(headers,
+			"List-Unsubscribe: <"+m.UnsubscribeURL+">",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+	for _, h := range headers {
+		if strings.ContainsAny(h, "\r\n") {
+			return nil, fmt.Errorf("invalid header %q", h)
+		}
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	// Plain text first: clients show the last part they can display
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	msg.Write(buf.Bytes())
+	return msg.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
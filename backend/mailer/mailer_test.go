@@ -0,0 +1,192 @@
+package mailer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+var testFrom = mail.Address{Name: "Feedback", Address: "noreply@example.com"}
+
+func testMessage() Message {
+	return Message{
+		To:             mail.Address{Name: "Zoë Example", Address: "zoe@example.com"},
+		Subject:        `Ann replied to your comment on "Dark mode"`,
+		Text:           "Ann replied: " + strings.Repeat("long line ", 20) + "\nwith = signs and ümlauts\n",
+		HTML:           `<p>Ann replied: <a href="https://example.com/feedback/1?a=1&amp;b=2">Dark mode</a></p>`,
+		UnsubscribeURL: "https://example.com/unsubscribe?token=abc",
+	}
+}
+
+// parse reads a built message back, checking that it is well formed
+func parse(t *testing.T, data []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("built message does not parse: %v\n%s", err, data)
+	}
+	return msg
+}
+
+func TestBuildMIMEStructure(t *testing.T) {
+	m := testMessage()
+	data, err := build(testFrom, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := parse(t, data)
+
+	for name, want := range map[string]string{
+		"From":         `"Feedback" <noreply@example.com>`,
+		"MIME-Version": "1.0",
+	} {
+		if got := msg.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 1 || *to[0] != m.To {
+		t.Errorf("To = %v (error %v), want %v", to, err, m.To)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q, want one in the sender's domain", id)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (error %v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		// NextPart decodes quoted-printable parts
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("reading the %s part: %v", want.contentType, err)
+		}
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		// Line breaks are sent as CRLF
+		if got := strings.ReplaceAll(string(content), "\r\n", "\n"); got != want.content {
+			t.Errorf("%s part = %q, want %q", want.contentType, got, want.content)
+		}
+	}
+	if _, err := parts.NextPart(); err == nil {
+		t.Error("message has more than two parts")
+	}
+
+	// Quoted-printable keeps every body line short
+	body := string(data[bytes.Index(data, []byte("\r\n\r\n")):])
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("line longer than 78 characters: %q", line)
+		}
+	}
+}
+
+// Feedback titles end up in subjects. Line breaks in them are encoded rather
+// than starting new headers.
+func TestBuildEncodesSubject(t *testing.T) {
+	tests := []string{
+		`Ann mentioned you on "Dark mode"`,
+		`Ann mentioned you on "Dark mode – and more"`,
+		"Ann mentioned you on \"Dark mode\r\nBcc: everyone@example.com\"",
+		"\"Dark mode\nX-Injected: yes\" was marked completed",
+	}
+	for _, subject := range tests {
+		m := testMessage()
+		m.Subject = subject
+		data, err := build(testFrom, m)
+		if err != nil {
+			t.Fatalf("build() with subject %q: %v", subject, err)
+		}
+		msg := parse(t, data)
+
+		decoded, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil || decoded != subject {
+			t.Errorf("Subject decodes to %q (error %v), want %q", decoded, err, subject)
+		}
+		for _, injected := range []string{"Bcc", "X-Injected"} {
+			if _, ok := msg.Header[injected]; ok {
+				t.Errorf("subject %q added a %s header", subject, injected)
+			}
+		}
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(m *Message)
+	}{
+		{"unsubscribe URL", func(m *Message) { m.UnsubscribeURL = "https://example.com/u\r\nBcc: everyone@example.com" }},
+		{"unsubscribe URL with a bare newline", func(m *Message) { m.UnsubscribeURL = "https://example.com/u\nBcc: everyone@example.com" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMessage()
+			tt.edit(&m)
+			if data, err := build(testFrom, m); err == nil {
+				t.Errorf("build() accepted a header with a line break:\n%s", data)
+			}
+		})
+	}
+
+	// Line breaks in the recipient are encoded or dropped by net/mail; an
+	// address like this is refused at RCPT TO
+	for _, edit := range []func(m *Message){
+		func(m *Message) { m.To.Name = "Zoë\r\nBcc: everyone@example.com" },
+		func(m *Message) { m.To.Address = "zoe@example.com\r\nBcc: everyone@example.com" },
+	} {
+		m := testMessage()
+		edit(&m)
+		data, err := build(testFrom, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg := parse(t, data); msg.Header.Get("Bcc") != "" {
+			t.Errorf("recipient %q added a Bcc header", m.To.String())
+		}
+	}
+}
+
+func TestBuildListUnsubscribe(t *testing.T) {
+	m := testMessage()
+	data, err := build(testFrom, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := parse(t, data)
+	if got, want := msg.Header.Get("List-Unsubscribe"), "<"+m.UnsubscribeURL+">"; got != want {
+		t.Errorf("List-Unsubscribe = %q, want %q", got, want)
+	}
+	if got := msg.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q, want one-click", got)
+	}
+
+	m.UnsubscribeURL = ""
+	data, err = build(testFrom, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = parse(t, data)
+	for _, name := range []string{"List-Unsubscribe", "List-Unsubscribe-Post"} {
+		if _, ok := msg.Header[name]; ok {
+			t.Errorf("%s sent without an unsubscribe URL", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSMTPPort = 587
+	smtpTimeout     = 30 * time.Second
+)
+
+// SMTPConfig is where and how mail is sent
+type SMTPConfig struct {
+	Host        string
+	Port        int // Defaults to 587
+	Username    string
+	Password    string
+	From        string // e.g. "Feedback <noreply@example.com>"
+	ImplicitTLS bool   // TLS from the start, usually on port 465, rather than STARTTLS
+}
+
+// SMTPSender sends each message over its own SMTP connection. STARTTLS is used
+// whenever the server offers it, and credentials are only sent over TLS or to
+// localhost, so a local catcher such as MailHog works without either.
+type SMTPSender struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+// NewSMTPSender returns a sender for config
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	if config.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	if config.Port == 0 {
+		config.Port = defaultSMTPPort
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, err
+	}
+	return &SMTPSender{config: config, from: from}, nil
+}
+
+// Send delivers one message
+func (s *SMTPSender) Send(m Message) error {
+	data, err := build(*s.from, m)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+
+	var conn net.Conn
+	if s.config.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !s.config.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To.Address); err != nil {
+		return &RejectedError{Stage: "RCPT", Err: err}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return &RejectedError{Stage: "DATA", Err: err}
+	}
+	if _, err := w.Write(data); err != nil {
+		return &RejectedError{Stage: "DATA", Err: err}
+	}
+	if err := w.Close(); err != nil {
+		return &RejectedError{Stage: "DATA", Err: err}
+	}
+	return c.Quit()
+}
+
+// RejectedError is a failure to hand over the message itself: the recipient at
+// RCPT TO, or the content at DATA. Failures before that, such as connecting,
+// authenticating or the sender at MAIL FROM, concern every message alike and
+// are returned as they are.
+type RejectedError struct {
+	Stage string // "RCPT" or "DATA"
+	Err   error
+}
+
+func (e *RejectedError) Error() string {
+	return "smtp " + e.Stage + ": " + e.Err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err is the server refusing this message
+// outright, such as an unknown recipient, so that retrying it would not help.
+// Only a RejectedError with a 5xx reply counts; anything else may be the
+// server or the connection, and clears up on its own.
+func IsPermanent(err error) bool {
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		return false
+	}
+	var protoErr *textproto.Error
+	return errors.As(rejected.Err, &protoErr) && protoErr.Code >= 500
+}
@@ -0,0 +1,261 @@
+package mailer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server on a local port that accepts every message
+// unless replies overrides the reply to a command. The end of the message
+// data is the command "." for this purpose.
+type fakeSMTP struct {
+	listener net.Listener
+	replies  map[string]string
+
+	mu       sync.Mutex
+	messages []string
+	commands []string
+}
+
+func newFakeSMTP(t *testing.T, replies map[string]string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{listener: listener, replies: replies}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(command, fallback string) {
+		if override, ok := f.replies[command]; ok {
+			fallback = override
+		}
+		text.PrintfLine("%s", fallback)
+	}
+
+	text.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(line + " ")[0])
+		f.mu.Lock()
+		f.commands = append(f.commands, command)
+		f.mu.Unlock()
+
+		switch command {
+		case "EHLO":
+			text.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+		case "AUTH":
+			reply(command, "235 2.7.0 Authenticated")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply(command, "250 2.0.0 OK")
+		case "DATA":
+			if _, ok := f.replies[command]; ok {
+				reply(command, "")
+				continue
+			}
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, string(data))
+			f.mu.Unlock()
+			reply(".", "250 2.0.0 Queued")
+		case "QUIT":
+			text.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			text.PrintfLine("502 5.5.2 Not implemented")
+		}
+	}
+}
+
+// received returns the messages accepted and the commands seen so far
+func (f *fakeSMTP) received() (messages, commands []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...), append([]string(nil), f.commands...)
+}
+
+func (f *fakeSMTP) sender(t *testing.T, username string) *SMTPSender {
+	t.Helper()
+	host, port, err := net.SplitHostPort(f.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	s, err := NewSMTPSender(SMTPConfig{
+		Host:     host,
+		Port:     portNumber,
+		Username: username,
+		Password: "secret",
+		From:     "Feedback <noreply@example.com>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSMTPSenderDelivers(t *testing.T) {
+	server := newFakeSMTP(t, nil)
+	m := testMessage()
+	if err := server.sender(t, "mailer").Send(m); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages, commands := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	msg := parse(t, []byte(messages[0]))
+	if got := msg.Header.Get("Subject"); got != m.Subject {
+		t.Errorf("Subject = %q, want %q", got, m.Subject)
+	}
+	if got := strings.Join(commands, " "); got != "EHLO AUTH MAIL RCPT DATA QUIT" {
+		t.Errorf("commands = %s", got)
+	}
+}
+
+// Only the server refusing the recipient or the message is permanent;
+// anything before that is retried
+func TestSMTPSenderPermanentFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   map[string]string
+		username  string
+		permanent bool
+	}{
+		{"unknown recipient", map[string]string{"RCPT": "550 5.1.1 No such user"}, "", true},
+		{"message refused", map[string]string{".": "554 5.7.1 Message rejected"}, "", true},
+		{"data refused", map[string]string{"DATA": "554 5.5.1 No valid recipients"}, "", true},
+		{"mailbox busy", map[string]string{"RCPT": "450 4.2.1 Try again later"}, "", false},
+		{"message deferred", map[string]string{".": "451 4.3.0 Try again later"}, "", false},
+		{"sender refused", map[string]string{"MAIL": "550 5.7.1 Sender not allowed"}, "", false},
+		{"bad credentials", map[string]string{"AUTH": "535 5.7.8 Authentication failed"}, "mailer", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTP(t, tt.replies)
+			err := server.sender(t, tt.username).Send(testMessage())
+			if err == nil {
+				t.Fatal("Send() succeeded")
+			}
+			if got := IsPermanent(err); got != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, got, tt.permanent)
+			}
+			var rejected *RejectedError
+			if tt.permanent && !errors.As(err, &rejected) {
+				t.Errorf("Send() error %v is not a RejectedError", err)
+			}
+		})
+	}
+}
+
+func TestSMTPSenderConnectionFailureIsTransient(t *testing.T) {
+	server := newFakeSMTP(t, nil)
+	sender := server.sender(t, "")
+	server.listener.Close()
+
+	err := sender.Send(testMessage())
+	if err == nil {
+		t.Fatal("Send() to a closed port succeeded")
+	}
+	if IsPermanent(err) {
+		t.Errorf("IsPermanent(%v) = true, want false", err)
+	}
+}
+
+// A 5xx reply that did not come from handing over a message is not permanent
+func TestIsPermanentNeedsRejectedError(t *testing.T) {
+	refused := &textproto.Error{Code: 550, Msg: "Sender not allowed"}
+	if IsPermanent(refused) || IsPermanent(fmt.Errorf("sending: %w", refused)) {
+		t.Error("a bare 5xx reply counted as permanent")
+	}
+	if !IsPermanent(fmt.Errorf("sending: %w", &RejectedError{Stage: "RCPT", Err: refused})) {
+		t.Error("a wrapped RejectedError did not count as permanent")
+	}
+}
+
+// TestSMTPSenderMailHog sends a message through the MailHog on the host in
+// TEST_MAILHOG_HOST, such as the one started by `docker-compose --profile
+// mail up`, and reads it back from its API. It is skipped when the variable
+// is not set.
+func TestSMTPSenderMailHog(t *testing.T) {
+	host := os.Getenv("TEST_MAILHOG_HOST")
+	if host == "" {
+		t.Skip("TEST_MAILHOG_HOST is not set")
+	}
+	sender, err := NewSMTPSender(SMTPConfig{Host: host, Port: 1025, From: "Feedback <noreply@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := testMessage()
+	m.To.Address = fmt.Sprintf("test-%d@example.com", time.Now().UnixNano())
+	m.Subject = "\"Dark mode\r\nBcc: everyone@example.com\" was marked completed"
+	if err := sender.Send(m); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	query := url.Values{"kind": {"to"}, "query": {m.To.Address}}
+	resp, err := http.Get("http://" + net.JoinHostPort(host, "8025") + "/api/v2/search?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var found struct {
+		Total int `json:"total"`
+		Items []struct {
+			Raw struct {
+				Data string `json:"Data"`
+			} `json:"Raw"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Total != 1 || len(found.Items) != 1 {
+		t.Fatalf("MailHog holds %d messages to %s, want 1", found.Total, m.To.Address)
+	}
+
+	msg := parse(t, []byte(found.Items[0].Raw.Data))
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != m.To.Address {
+		t.Errorf("To = %v (error %v), want %s", to, err, m.To.Address)
+	}
+	if _, ok := msg.Header["Bcc"]; ok {
+		t.Error("subject added a Bcc header")
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<"+m.UnsubscribeURL+">" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// Each email has a text template "<name>.txt", an HTML template "<name>.html"
+// and a subject defined as "<name>.subject"; *.tmpl files are shared by both.
+//
+//go:embed templates
+var templateFS embed.FS
+
+// Templates renders emails from the embedded templates
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates
+func LoadTemplates() (*Templates, error) {
+	text, err := texttemplate.ParseFS(templateFS, "templates/*.tmpl", "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.ParseFS(templateFS, "templates/*.tmpl", "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	return &Templates{text: text, html: html}, nil
+}
+
+// Render fills in the subject and bodies of m from the templates for name
+func (t *Templates) Render(name string, data interface{}, m *Message) error {
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return err
+	}
+	if err := t.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return err
+	}
+	if err := t.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return err
+	}
+	m.Subject = subject.String()
+	m.Text = text.String()
+	m.HTML = html.String()
+	return nil
+}
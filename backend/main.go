@@ -2,11 +2,16 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"canny-clone/middlewares"
+	"canny-clone/migrate"
+	"canny-clone/migrations"
 	"canny-clone/routes"
 	"canny-clone/services"
 	"canny-clone/utils"
@@ -18,24 +23,64 @@ func main() {
 	config := utils.GetConfig()
 	
 	// Initialize database connection
-	services.InitDB()
+	db := services.InitDB()
+	
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	
+	// "migrate up|down [steps]|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(migrator, os.Args[2:])
+		return
+	}
+	
+	// Apply pending migrations before serving
+	applied, err := migrator.Up()
+	if err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
 	
 	// Initialize authentication service
 	services.InitAuth()
+	
+	// Set up where attachments are stored
+	services.InitStorage()
+	
+	// Share board events with the other replicas
+	services.InitEventBus(db)
+	
+	// Render Markdown stored before its HTML was kept alongside it
+	services.StartMarkdownBackfill()
+	
+	// Keep comment reaction counters in sync with comment_likes
+	services.StartReactionReconciler(config.ReactionReconcileInterval)
+
+	// Email notifications to users, immediately or as daily digests
+	services.StartEmailNotifier()
 
 	// Create router and register routes
 	r := mux.NewRouter()
+	r.Use(middlewares.StripIdentityHeaders)
 	routes.RegisterBoardRoutes(r)
 	routes.RegisterFeedbackRoutes(r)
 	routes.RegisterCategoryRoutes(r)
 	routes.RegisterCommentRoutes(r)
+	routes.RegisterAttachmentRoutes(r)
 	routes.RegisterAuthRoutes(r)
+	routes.RegisterSearchRoutes(r)
+	routes.RegisterMeRoutes(r)
+	routes.RegisterEmailRoutes(r)
 
 	// Setup CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"}, // In production, specify your frontend domain
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "User-ID"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Last-Event-ID"},
 		AllowCredentials: true,
 	})
 	
@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// StripIdentityHeaders removes identity headers a client may send to impersonate
+// another user. The authenticated user is only ever read from the request context.
+func StripIdentityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("User-ID")
+		r.Header.Del("User-Role")
+		r.Header.Del("Board-Role")
+		next.ServeHTTP(w, r)
+	})
+}
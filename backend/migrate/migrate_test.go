@@ -0,0 +1,195 @@
+package migrate_test
+
+import (
+	"canny-clone/internal/testdb"
+	"canny-clone/migrate"
+	"canny-clone/migrations"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int
+		wantErr string
+	}{
+		{"ordered by version", fstest.MapFS{
+			"0010_later.up.sql":   file("up 10"),
+			"0010_later.down.sql": file("down 10"),
+			"0002_first.up.sql":   file("up 2"),
+			"0002_first.down.sql": file("down 2"),
+			"migrations.go":       file("package migrations"),
+		}, []int{2, 10}, ""},
+		{"missing down", fstest.MapFS{
+			"0001_first.up.sql": file("up 1"),
+		}, nil, "needs both up and down files"},
+		{"conflicting names", fstest.MapFS{
+			"0001_first.up.sql":   file("up 1"),
+			"0001_other.down.sql": file("down 1"),
+		}, nil, "conflicting names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := migrate.Load(tt.fsys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int
+			for _, m := range loaded {
+				versions = append(versions, m.Version)
+				if m.Up != fmt.Sprintf("up %d", m.Version) || m.Down != fmt.Sprintf("down %d", m.Version) {
+					t.Errorf("migration %d has up %q and down %q", m.Version, m.Up, m.Down)
+				}
+			}
+			if !reflect.DeepEqual(versions, tt.want) {
+				t.Errorf("Load() versions = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
+
+// openSchema connects to the test database with a new, empty schema as the
+// search path, so migrating down leaves the tables other tests use alone
+func openSchema(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := testdb.URL(t)
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	// lib/pq passes settings it does not know to the server
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// recorded returns the versions and names in schema_migrations, in order
+func recorded(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT version, name FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	entries := []string{}
+	for rows.Next() {
+		var version int
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, fmt.Sprintf("%04d_%s", version, name))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// Every migration applies to an empty database, reverts cleanly and applies
+// again
+func TestUpDownUp(t *testing.T) {
+	db := openSchema(t)
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{}
+	for _, m := range all {
+		want = append(want, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+	}
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := func() {
+		t.Helper()
+		applied, err := migrator.Up()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(applied) != len(all) {
+			t.Errorf("Up() applied %d migrations, want %d", len(applied), len(all))
+		}
+		if got := recorded(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("schema_migrations after Up() = %v, want %v", got, want)
+		}
+	}
+
+	up()
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("second Up() applied %d migrations (error %v), want none", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(len(all))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(all) {
+		t.Fatalf("Down() reverted %d migrations, want %d", len(reverted), len(all))
+	}
+	if last := all[len(all)-1].Version; reverted[0].Version != last {
+		t.Errorf("Down() reverted %d first, want %d", reverted[0].Version, last)
+	}
+	if got := recorded(t, db); len(got) != 0 {
+		t.Errorf("schema_migrations after Down() = %v, want none", got)
+	}
+	var tables []string
+	rows, err := db.Query(`
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	if len(tables) != 0 {
+		t.Errorf("tables left after Down(): %v", tables)
+	}
+
+	up()
+}
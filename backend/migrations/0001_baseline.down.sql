@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS comment_likes;
+DROP TABLE IF EXISTS comment_replies;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS feedback;
+DROP TABLE IF EXISTS board_members;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS boards;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,118 @@
+-- Baseline schema, consolidated from the original create_* and add_* scripts.
+-- Written so it can also adopt a database created by those scripts.
+
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    picture TEXT,
+    provider VARCHAR(50) NOT NULL, -- e.g., "google", "github"
+    role VARCHAR(20) NOT NULL DEFAULT 'user', -- 'app_admin', 'stakeholder' or 'user'
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+-- Create boards table
+CREATE TABLE IF NOT EXISTS boards (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+ALTER TABLE boards ALTER COLUMN description SET DEFAULT '';
+
+-- Create categories table
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+-- Seed default categories on a fresh database
+INSERT INTO categories (name)
+SELECT name FROM (VALUES ('Feature Request'), ('Bug'), ('Improvement')) AS defaults(name)
+WHERE NOT EXISTS (SELECT 1 FROM categories);
+
+-- Create board_members table to track user roles within boards
+CREATE TABLE IF NOT EXISTS board_members (
+    id SERIAL PRIMARY KEY,
+    board_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user', -- 'stakeholder' or 'user'
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(board_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_board_members_board_id ON board_members(board_id);
+CREATE INDEX IF NOT EXISTS idx_board_members_user_id ON board_members(user_id);
+
+-- Create feedback table
+CREATE TABLE IF NOT EXISTS feedback (
+    id SERIAL PRIMARY KEY,
+    board_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    category_id INT NOT NULL,
+    upvotes INT DEFAULT 0,
+    downvotes INT DEFAULT 0,
+    status VARCHAR(50) DEFAULT 'pending',
+    FOREIGN KEY (board_id) REFERENCES boards(id),
+    FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS status VARCHAR(50) DEFAULT 'pending';
+UPDATE feedback SET status = 'pending' WHERE status IS NULL;
+
+-- Create votes table
+CREATE TABLE IF NOT EXISTS votes (
+    id SERIAL PRIMARY KEY,
+    feedback_id INT NOT NULL,
+    user_id INT NOT NULL,
+    vote_type VARCHAR(10) NOT NULL CHECK (vote_type IN ('upvote', 'downvote')),
+    FOREIGN KEY (feedback_id) REFERENCES feedback(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (feedback_id, user_id) -- Ensure a user can only vote once per feedback
+);
+
+-- Create comments table
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    feedback_id INT NOT NULL,
+    user_id INT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    likes INT DEFAULT 0,
+    dislikes INT DEFAULT 0,
+    FOREIGN KEY (feedback_id) REFERENCES feedback(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Create comment_replies table
+CREATE TABLE IF NOT EXISTS comment_replies (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    likes INT DEFAULT 0,
+    dislikes INT DEFAULT 0,
+    FOREIGN KEY (comment_id) REFERENCES comments(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Create comment_likes table to track who liked a comment or reply
+CREATE TABLE IF NOT EXISTS comment_likes (
+    id SERIAL PRIMARY KEY,
+    comment_id INT,
+    reply_id INT,
+    user_id INT NOT NULL,
+    is_like BOOLEAN NOT NULL, -- true for like, false for dislike
+    CHECK ((comment_id IS NULL) != (reply_id IS NULL)), -- Either comment_id or reply_id must be non-null, but not both
+    FOREIGN KEY (comment_id) REFERENCES comments(id),
+    FOREIGN KEY (reply_id) REFERENCES comment_replies(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (comment_id, user_id), -- Ensure a user can only have one reaction per comment
+    UNIQUE (reply_id, user_id)    -- Ensure a user can only have one reaction per reply
+);
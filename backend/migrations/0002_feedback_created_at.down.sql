@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_feedback_board_score;
+DROP INDEX IF EXISTS idx_feedback_board_created;
+ALTER TABLE feedback DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- Add creation timestamp to feedback so it can be sorted by age
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Create indexes for the filtered and sorted board listing
+CREATE INDEX IF NOT EXISTS idx_feedback_board_created ON feedback(board_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_feedback_board_score ON feedback(board_id, (upvotes - downvotes) DESC, id DESC);
@@ -0,0 +1,13 @@
+-- Add full-text search vectors to feedback and comments
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', coalesce(content, ''))) STORED;
+
+-- Create GIN indexes for full-text search
+CREATE INDEX IF NOT EXISTS idx_feedback_search_vector ON feedback USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING GIN(search_vector);
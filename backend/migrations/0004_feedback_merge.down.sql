@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_feedback_merged_into;
+ALTER TABLE feedback DROP COLUMN IF EXISTS merged_into;
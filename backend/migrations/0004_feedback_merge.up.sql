@@ -0,0 +1,4 @@
+-- Track which feedback a duplicate was merged into
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS merged_into INT REFERENCES feedback(id);
+
+CREATE INDEX IF NOT EXISTS idx_feedback_merged_into ON feedback(merged_into);
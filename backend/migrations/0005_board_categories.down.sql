@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_categories_board_id;
+ALTER TABLE categories DROP COLUMN sort_order;
+ALTER TABLE categories DROP COLUMN description;
+ALTER TABLE categories DROP COLUMN color;
+ALTER TABLE categories DROP COLUMN board_id;
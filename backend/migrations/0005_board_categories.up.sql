@@ -0,0 +1,31 @@
+-- Scope categories to boards and add display settings
+ALTER TABLE categories ADD COLUMN board_id INT REFERENCES boards(id) ON DELETE CASCADE;
+ALTER TABLE categories ADD COLUMN color VARCHAR(7) NOT NULL DEFAULT '#6b7280';
+ALTER TABLE categories ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE categories ADD COLUMN sort_order INT NOT NULL DEFAULT 0;
+
+-- Give every board its own copy of the global categories
+INSERT INTO categories (board_id, name, sort_order)
+SELECT b.id, c.name, c.id
+FROM boards b CROSS JOIN categories c
+WHERE c.board_id IS NULL;
+
+-- Point feedback at its board's copy
+UPDATE feedback f SET category_id = bc.id
+FROM categories gc
+JOIN categories bc ON bc.name = gc.name AND bc.sort_order = gc.id
+WHERE f.category_id = gc.id AND gc.board_id IS NULL AND bc.board_id = f.board_id;
+
+DELETE FROM categories WHERE board_id IS NULL;
+
+-- Number each board's categories from zero
+UPDATE categories c SET sort_order = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY board_id ORDER BY sort_order, id) - 1 AS position
+    FROM categories
+) ordered
+WHERE c.id = ordered.id;
+
+ALTER TABLE categories ALTER COLUMN board_id SET NOT NULL;
+
+CREATE INDEX idx_categories_board_id ON categories(board_id, sort_order);
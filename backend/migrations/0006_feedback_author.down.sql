@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_votes_user_id;
+DROP INDEX IF EXISTS idx_feedback_user_created;
+ALTER TABLE feedback DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,6 @@
+-- Record who posted each feedback item; older posts keep a NULL author
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+-- Create indexes for a user's own posts and votes
+CREATE INDEX IF NOT EXISTS idx_feedback_user_created ON feedback(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_votes_user_id ON votes(user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS feedback_revisions;
+ALTER TABLE feedback DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE feedback DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE feedback DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,24 @@
+-- Track edits and soft deletes on feedback
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(id) ON DELETE SET NULL;
+
+-- Create feedback_revisions table holding the content of every version of a post.
+-- category_id has no foreign key so history survives category deletion.
+CREATE TABLE IF NOT EXISTS feedback_revisions (
+    id SERIAL PRIMARY KEY,
+    feedback_id INT NOT NULL REFERENCES feedback(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    category_id INT NOT NULL,
+    editor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (feedback_id, revision)
+);
+
+-- Existing posts start their history with their current content
+INSERT INTO feedback_revisions (feedback_id, revision, title, description, category_id, editor_id, created_at)
+SELECT f.id, 1, f.title, f.description, f.category_id, f.user_id, f.created_at
+FROM feedback f
+WHERE NOT EXISTS (SELECT 1 FROM feedback_revisions r WHERE r.feedback_id = f.id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS comment_edits;
+ALTER TABLE comment_replies DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE comment_replies DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE comment_replies DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS edited_at;
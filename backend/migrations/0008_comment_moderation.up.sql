@@ -0,0 +1,22 @@
+-- Track edits and deletes on comments and replies
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE comment_replies ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE comment_replies ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE comment_replies ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(id) ON DELETE SET NULL;
+
+-- Create comment_edits table keeping the content a comment or reply had before each edit
+CREATE TABLE IF NOT EXISTS comment_edits (
+    id SERIAL PRIMARY KEY,
+    comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
+    reply_id INT REFERENCES comment_replies(id) ON DELETE CASCADE,
+    previous_content TEXT NOT NULL,
+    edited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    edited_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((comment_id IS NULL) != (reply_id IS NULL)) -- Either comment_id or reply_id must be non-null, but not both
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_edits_comment_id ON comment_edits(comment_id);
+CREATE INDEX IF NOT EXISTS idx_comment_edits_reply_id ON comment_edits(reply_id);
@@ -0,0 +1,49 @@
+-- Recreate comment_replies; nested comments are flattened into replies of their thread's root
+CREATE TABLE comment_replies (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    likes INT DEFAULT 0,
+    dislikes INT DEFAULT 0,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INT REFERENCES users(id) ON DELETE SET NULL,
+    legacy_comment_id INT,
+    FOREIGN KEY (comment_id) REFERENCES comments(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+INSERT INTO comment_replies (comment_id, user_id, content, created_at, likes, dislikes,
+                             edited_at, deleted_at, deleted_by, legacy_comment_id)
+SELECT split_part(c.path, '.', 1)::int, c.user_id, c.content, c.created_at, c.likes, c.dislikes,
+       c.edited_at, c.deleted_at, c.deleted_by, c.id
+FROM comments c
+WHERE c.parent_id IS NOT NULL
+ORDER BY c.path;
+
+ALTER TABLE comment_likes ADD COLUMN reply_id INT REFERENCES comment_replies(id);
+ALTER TABLE comment_likes ALTER COLUMN comment_id DROP NOT NULL;
+UPDATE comment_likes cl SET reply_id = cr.id, comment_id = NULL
+FROM comment_replies cr
+WHERE cr.legacy_comment_id = cl.comment_id;
+ALTER TABLE comment_likes ADD CHECK ((comment_id IS NULL) != (reply_id IS NULL));
+ALTER TABLE comment_likes ADD UNIQUE (reply_id, user_id);
+
+ALTER TABLE comment_edits ADD COLUMN reply_id INT REFERENCES comment_replies(id) ON DELETE CASCADE;
+ALTER TABLE comment_edits ALTER COLUMN comment_id DROP NOT NULL;
+UPDATE comment_edits ce SET reply_id = cr.id, comment_id = NULL
+FROM comment_replies cr
+WHERE cr.legacy_comment_id = ce.comment_id;
+ALTER TABLE comment_edits ADD CHECK ((comment_id IS NULL) != (reply_id IS NULL));
+CREATE INDEX IF NOT EXISTS idx_comment_edits_reply_id ON comment_edits(reply_id);
+
+DELETE FROM comments WHERE parent_id IS NOT NULL;
+ALTER TABLE comment_replies DROP COLUMN legacy_comment_id;
+
+DROP INDEX IF EXISTS idx_comments_parent_id;
+DROP INDEX IF EXISTS idx_comments_feedback_path;
+ALTER TABLE comments DROP COLUMN IF EXISTS path;
+ALTER TABLE comments DROP COLUMN IF EXISTS depth;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,43 @@
+-- Thread comments by parent. Replies become comments with a parent_id, and a
+-- materialized path (zero-padded ids joined by dots) keeps each thread in order.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES comments(id);
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS path TEXT;
+ALTER TABLE comments ADD COLUMN legacy_reply_id INT;
+
+-- Move replies into comments under their parent comment
+INSERT INTO comments (feedback_id, parent_id, user_id, content, created_at, likes, dislikes,
+                      edited_at, deleted_at, deleted_by, depth, legacy_reply_id)
+SELECT c.feedback_id, cr.comment_id, cr.user_id, cr.content, cr.created_at, cr.likes, cr.dislikes,
+       cr.edited_at, cr.deleted_at, cr.deleted_by, 1, cr.id
+FROM comment_replies cr
+JOIN comments c ON c.id = cr.comment_id
+ORDER BY cr.id;
+
+-- Point reactions and edit history at the moved replies
+UPDATE comment_likes cl SET comment_id = c.id, reply_id = NULL
+FROM comments c
+WHERE c.legacy_reply_id = cl.reply_id;
+
+UPDATE comment_edits ce SET comment_id = c.id, reply_id = NULL
+FROM comments c
+WHERE c.legacy_reply_id = ce.reply_id;
+
+-- Dropping reply_id also drops the constraints and indexes that use it
+ALTER TABLE comment_likes DROP COLUMN reply_id;
+ALTER TABLE comment_likes ALTER COLUMN comment_id SET NOT NULL;
+ALTER TABLE comment_edits DROP COLUMN reply_id;
+ALTER TABLE comment_edits ALTER COLUMN comment_id SET NOT NULL;
+
+DROP TABLE comment_replies;
+ALTER TABLE comments DROP COLUMN legacy_reply_id;
+
+-- Build paths; existing threads are at most two levels deep
+UPDATE comments SET path = lpad(id::text, 10, '0') WHERE parent_id IS NULL;
+UPDATE comments c SET path = p.path || '.' || lpad(c.id::text, 10, '0')
+FROM comments p
+WHERE p.id = c.parent_id;
+ALTER TABLE comments ALTER COLUMN path SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_feedback_path ON comments(feedback_id, path);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
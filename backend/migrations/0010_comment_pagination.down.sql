@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_comments_roots_likes;
+DROP INDEX IF EXISTS idx_comments_roots_created;
+DROP INDEX IF EXISTS idx_comments_feedback_path_pattern;
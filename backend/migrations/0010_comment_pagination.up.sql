@@ -0,0 +1,7 @@
+-- Subtree lookups match descendants with "path LIKE prefix || '.%'", which only
+-- uses an index built with text_pattern_ops
+CREATE INDEX IF NOT EXISTS idx_comments_feedback_path_pattern ON comments(feedback_id, path text_pattern_ops);
+
+-- Top-level comments are paged by creation time or likes
+CREATE INDEX IF NOT EXISTS idx_comments_roots_created ON comments(feedback_id, created_at, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_roots_likes ON comments(feedback_id, likes, id) WHERE parent_id IS NULL;
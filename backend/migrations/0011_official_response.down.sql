@@ -0,0 +1 @@
+ALTER TABLE feedback DROP COLUMN IF EXISTS official_response_id;
@@ -0,0 +1,2 @@
+-- A stakeholder can pin one comment per feedback item as its official response
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS official_response_id INT REFERENCES comments(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- Internal notes would become public, so remove them first
+DELETE FROM comment_likes WHERE comment_id IN (SELECT id FROM comments WHERE internal);
+DELETE FROM comments WHERE internal;
+ALTER TABLE comments DROP COLUMN IF EXISTS internal;
@@ -0,0 +1,2 @@
+-- Internal notes are comments only board stakeholders and app admins can see
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS internal BOOLEAN NOT NULL DEFAULT FALSE;
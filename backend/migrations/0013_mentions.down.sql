@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS comment_mentions;
+ALTER TABLE boards DROP COLUMN IF EXISTS mention_policy;
@@ -0,0 +1,28 @@
+-- How mentions of users who are not board members are handled: 'plain' leaves
+-- them as text, 'reject' refuses the comment
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS mention_policy VARCHAR(10) NOT NULL DEFAULT 'plain'
+    CHECK (mention_policy IN ('plain', 'reject'));
+
+-- Board members mentioned in a comment, with the mention as it was written
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mention_text TEXT NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user_id ON comment_mentions(user_id);
+
+-- Notifications sent to users, such as being mentioned in a comment
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    feedback_id INT REFERENCES feedback(id) ON DELETE CASCADE,
+    comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC);
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS board_event_ids;
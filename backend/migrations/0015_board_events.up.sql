@@ -0,0 +1,2 @@
+-- IDs of board events published through NOTIFY, unique across replicas
+CREATE SEQUENCE IF NOT EXISTS board_event_ids;
@@ -0,0 +1,4 @@
+-- Earlier versions do not know the completed status
+UPDATE feedback SET status = 'approved' WHERE status = 'completed';
+DROP INDEX IF EXISTS idx_notifications_user_unread;
+ALTER TABLE notifications DROP COLUMN IF EXISTS status;
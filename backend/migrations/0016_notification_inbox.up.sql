@@ -0,0 +1,5 @@
+-- The status a feedback item was moved to, for status change notifications
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS status VARCHAR(50);
+
+-- Unread counts for the notification inbox
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id) WHERE read_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_unemailed;
+ALTER TABLE notifications DROP COLUMN IF EXISTS email_claimed_at;
+ALTER TABLE notifications DROP COLUMN IF EXISTS emailed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS digest_sent_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_delivery;
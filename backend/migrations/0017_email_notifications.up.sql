@@ -0,0 +1,15 @@
+-- How a user receives notifications by email: 'immediate', 'digest' (once a day) or 'off'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_delivery VARCHAR(10) NOT NULL DEFAULT 'immediate'
+    CHECK (email_delivery IN ('immediate', 'digest', 'off'));
+-- When the user's last daily digest was sent
+ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_sent_at TIMESTAMP;
+
+-- When a notification was emailed or passed over for email; NULL while it is still to be sent
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS emailed_at TIMESTAMP;
+-- Notifications from before email delivery are not sent
+UPDATE notifications SET emailed_at = created_at WHERE emailed_at IS NULL;
+-- When a replica claimed a notification to email it. Emails are sent after the
+-- claim is committed; a claim that is never marked emailed or released expires.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS email_claimed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unemailed ON notifications(user_id, id) WHERE emailed_at IS NULL;
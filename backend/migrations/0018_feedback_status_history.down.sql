@@ -0,0 +1 @@
+DROP TABLE IF EXISTS feedback_status_changes;
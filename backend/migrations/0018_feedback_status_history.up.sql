@@ -0,0 +1,12 @@
+-- Create feedback_status_changes table recording every status a feedback item
+-- was moved to. Earlier changes were not recorded, so history starts here.
+CREATE TABLE IF NOT EXISTS feedback_status_changes (
+    id SERIAL PRIMARY KEY,
+    feedback_id INT NOT NULL REFERENCES feedback(id) ON DELETE CASCADE,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    changed_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_feedback_status_changes_feedback ON feedback_status_changes(feedback_id, id);
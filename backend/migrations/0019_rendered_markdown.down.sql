@@ -0,0 +1,2 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS content_html;
+ALTER TABLE feedback DROP COLUMN IF EXISTS description_html;
@@ -0,0 +1,4 @@
+-- Keep the sanitized HTML of descriptions and comments so reads don't render
+-- Markdown. Rows written before this are rendered on startup.
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS description_html TEXT;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_html TEXT;
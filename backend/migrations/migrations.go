@@ -0,0 +1,8 @@
+// Package migrations holds the versioned SQL migrations applied by the migrate package.
+// Each migration is a pair of NNNN_name.up.sql and NNNN_name.down.sql files.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Attachment is a file uploaded to a feedback item or one of its comments
+type Attachment struct {
+	ID          int       `json:"id"`
+	FeedbackID  int       `json:"feedbackId"`
+	CommentID   *int      `json:"commentId,omitempty"` // nil for files attached to the feedback item itself
+	UserID      *int      `json:"userId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	StorageKey string `json:"-"`
+	BoardID    int    `json:"-"`
+	Internal   bool   `json:"-"` // Attached to an internal note
+}
+
+var (
+	// ErrAttachmentNotFound is returned when an attachment does not exist or belongs to deleted content
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrTooManyAttachments is returned when a feedback item or comment already has the most attachments allowed
+	ErrTooManyAttachments = errors.New("too many attachments")
+)
+
+type AttachmentRepository interface {
+	CreateAttachment(a *Attachment, limit int) error
+	GetAttachment(id int) (*Attachment, error)
+	GetAttachmentBoardID(id int) (int, error)
+	GetAttachmentsByFeedbackID(feedbackID int, includeInternal bool) ([]Attachment, error)
+	DeleteAttachment(id int) error
+}
+
+type AttachmentRepositoryImpl struct {
+	db DBTX
+}
+
+func NewAttachmentRepository() AttachmentRepository {
+	return &AttachmentRepositoryImpl{
+		db: GetDB(),
+	}
+}
+
+// attachmentFrom joins an attachment to its feedback and comment, leaving out
+// attachments of deleted feedback items and deleted comments
+const attachmentFrom = `
+	attachments a
+	JOIN feedback f ON f.id = a.feedback_id AND f.deleted_at IS NULL
+	LEFT JOIN comments c ON c.id = a.comment_id
+	WHERE (a.comment_id IS NULL OR c.deleted_at IS NULL)`
+
+const attachmentColumns = `a.id, a.feedback_id, a.comment_id, a.user_id, a.filename, a.content_type,
+	a.size, a.created_at, a.storage_key, f.board_id, COALESCE(c.internal, FALSE)`
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.FeedbackID, &a.CommentID, &a.UserID, &a.Filename, &a.ContentType,
+		&a.Size, &a.CreatedAt, &a.StorageKey, &a.BoardID, &a.Internal)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAttachment records an uploaded file, setting its ID and creation time.
+// It fails with ErrTooManyAttachments if the feedback item, or the comment when
+// CommentID is set, already has limit attachments.
+func (r *AttachmentRepositoryImpl) CreateAttachment(a *Attachment, limit int) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		// Lock the feedback item so concurrent uploads cannot both pass the limit
+		for {
+			var locked int
+			err := tx.QueryRow(`SELECT id FROM feedback WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, a.FeedbackID).Scan(&locked)
+			if err == sql.ErrNoRows {
+				return ErrFeedbackNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if a.CommentID == nil {
+				break
+			}
+
+			// A merge moves comments while holding the lock, so once it is
+			// granted the comment stays put. Follow it if it moved before then.
+			var current int
+			if err := tx.QueryRow(`SELECT feedback_id FROM comments WHERE id = $1`, *a.CommentID).Scan(&current); err != nil {
+				return err
+			}
+			if current == a.FeedbackID {
+				break
+			}
+			a.FeedbackID = current
+		}
+
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM attachments
+			WHERE feedback_id = $1 AND comment_id IS NOT DISTINCT FROM $2
+		`, a.FeedbackID, a.CommentID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count >= limit {
+			return ErrTooManyAttachments
+		}
+
+		return tx.QueryRow(`
+			INSERT INTO attachments (feedback_id, comment_id, user_id, storage_key, filename, content_type, size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at
+		`, a.FeedbackID, a.CommentID, a.UserID, a.StorageKey, a.Filename, a.ContentType, a.Size).Scan(&a.ID, &a.CreatedAt)
+	})
+}
+
+func (r *AttachmentRepositoryImpl) GetAttachment(id int) (*Attachment, error) {
+	a, err := scanAttachment(r.db.QueryRow(`
+		SELECT `+attachmentColumns+`
+		FROM `+attachmentFrom+` AND a.id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentNotFound
+	}
+	return a, err
+}
+
+// GetAttachmentBoardID returns the board an attachment belongs to
+func (r *AttachmentRepositoryImpl) GetAttachmentBoardID(id int) (int, error) {
+	var boardID int
+	err := r.db.QueryRow(`
+		SELECT f.board_id
+		FROM `+attachmentFrom+` AND a.id = $1
+	`, id).Scan(&boardID)
+	if err == sql.ErrNoRows {
+		return 0, ErrAttachmentNotFound
+	}
+	return boardID, err
+}
+
+// GetAttachmentsByFeedbackID lists the files attached to a feedback item and its
+// comments, oldest first. Attachments of internal notes are left out unless includeInternal is set.
+func (r *AttachmentRepositoryImpl) GetAttachmentsByFeedbackID(feedbackID int, includeInternal bool) ([]Attachment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+attachmentColumns+`
+		FROM `+attachmentFrom+`
+			AND a.feedback_id = $1
+			AND ($2 OR NOT COALESCE(c.internal, FALSE))
+		ORDER BY a.created_at, a.id
+	`, feedbackID, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment removes an attachment's record; the caller removes the stored file
+func (r *AttachmentRepositoryImpl) DeleteAttachment(id int) error {
+	result, err := r.db.Exec(`DELETE FROM attachments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrAttachmentNotFound
+	}
+	return err
+}
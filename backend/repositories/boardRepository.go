@@ -6,20 +6,29 @@ import (
 )
 
 type Board struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	MentionPolicy string `json:"mentionPolicy"` // How mentions of non-members are handled
 }
 
+// Mention policies: mentions of users who are not board members are either
+// left as plain text or rejected
+const (
+	MentionPolicyPlain  = "plain"
+	MentionPolicyReject = "reject"
+)
+
 type BoardRepository interface {
 	GetAllBoards() ([]Board, error)
 	GetUserBoards(userID int) ([]Board, error)
 	CreateBoard(name string) (int, error)
 	GetBoardByID(id int) (*Board, error)
-	UpdateBoard(id int, name string) error
+	UpdateBoard(id int, name string, mentionPolicy string) error
+	WithTx(tx *sql.Tx) BoardRepository
 }
 
 type BoardRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewBoardRepository() BoardRepository {
@@ -29,7 +38,7 @@ func NewBoardRepository() BoardRepository {
 }
 
 func (r *BoardRepositoryImpl) GetAllBoards() ([]Board, error) {
-	rows, err := r.db.Query("SELECT id, name FROM boards")
+	rows, err := r.db.Query("SELECT id, name, mention_policy FROM boards")
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +47,7 @@ func (r *BoardRepositoryImpl) GetAllBoards() ([]Board, error) {
 	var boards []Board
 	for rows.Next() {
 		var board Board
-		if err := rows.Scan(&board.ID, &board.Name); err != nil {
+		if err := rows.Scan(&board.ID, &board.Name, &board.MentionPolicy); err != nil {
 			return nil, err
 		}
 		boards = append(boards, board)
@@ -58,7 +67,7 @@ func (r *BoardRepositoryImpl) CreateBoard(name string) (int, error) {
 
 func (r *BoardRepositoryImpl) GetBoardByID(id int) (*Board, error) {
 	var board Board
-	err := r.db.QueryRow("SELECT id, name FROM boards WHERE id = $1", id).Scan(&board.ID, &board.Name)
+	err := r.db.QueryRow("SELECT id, name, mention_policy FROM boards WHERE id = $1", id).Scan(&board.ID, &board.Name, &board.MentionPolicy)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("board not found")
@@ -70,7 +79,7 @@ func (r *BoardRepositoryImpl) GetBoardByID(id int) (*Board, error) {
 
 func (r *BoardRepositoryImpl) GetUserBoards(userID int) ([]Board, error) {
 	rows, err := r.db.Query(`
-		SELECT b.id, b.name 
+		SELECT b.id, b.name, b.mention_policy
 		FROM boards b
 		JOIN board_members bm ON b.id = bm.board_id
 		WHERE bm.user_id = $1
@@ -84,7 +93,7 @@ func (r *BoardRepositoryImpl) GetUserBoards(userID int) ([]Board, error) {
 	var boards []Board
 	for rows.Next() {
 		var board Board
-		if err := rows.Scan(&board.ID, &board.Name); err != nil {
+		if err := rows.Scan(&board.ID, &board.Name, &board.MentionPolicy); err != nil {
 			return nil, err
 		}
 		boards = append(boards, board)
@@ -93,8 +102,12 @@ func (r *BoardRepositoryImpl) GetUserBoards(userID int) ([]Board, error) {
 	return boards, nil
 }
 
-func (r *BoardRepositoryImpl) UpdateBoard(id int, name string) error {
-	result, err := r.db.Exec("UPDATE boards SET name = $1 WHERE id = $2", name, id)
+// UpdateBoard renames a board and, unless mentionPolicy is empty, changes its mention policy
+func (r *BoardRepositoryImpl) UpdateBoard(id int, name string, mentionPolicy string) error {
+	result, err := r.db.Exec(`
+		UPDATE boards SET name = $1, mention_policy = COALESCE(NULLIF($3, ''), mention_policy)
+		WHERE id = $2
+	`, name, id, mentionPolicy)
 	if err != nil {
 		return err
 	}
@@ -110,3 +123,10 @@ func (r *BoardRepositoryImpl) UpdateBoard(id int, name string) error {
 
 	return nil
 }
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *BoardRepositoryImpl) WithTx(tx *sql.Tx) BoardRepository {
+	return &BoardRepositoryImpl{
+		db: tx,
+	}
+}
@@ -2,19 +2,41 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 )
 
 type Category struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID          int    `json:"id"`
+	BoardID     int    `json:"boardId"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sortOrder"`
 }
 
+// DefaultCategories are created on every new board
+var DefaultCategories = []Category{
+	{Name: "Feature Request", Color: "#3b82f6"},
+	{Name: "Bug", Color: "#ef4444"},
+	{Name: "Improvement", Color: "#10b981"},
+}
+
+// ErrCategoryNotFound is returned when a category does not exist on the board
+var ErrCategoryNotFound = errors.New("category not found")
+
 type CategoryRepository interface {
-	GetAllCategories() ([]Category, error)
+	GetCategoriesByBoardID(boardID int) ([]Category, error)
+	GetCategoryByID(id int) (*Category, error)
+	CreateCategory(category *Category) error
+	CreateDefaultCategories(boardID int) error
+	UpdateCategory(category *Category) error
+	ReorderCategories(boardID int, categoryIDs []int) error
+	DeleteCategory(id int, fallbackID int) (int64, error)
+	WithTx(tx *sql.Tx) CategoryRepository
 }
 
 type CategoryRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewCategoryRepository() CategoryRepository {
@@ -23,21 +45,158 @@ func NewCategoryRepository() CategoryRepository {
 	}
 }
 
-func (r *CategoryRepositoryImpl) GetAllCategories() ([]Category, error) {
-	rows, err := r.db.Query("SELECT id, name FROM categories")
+func (r *CategoryRepositoryImpl) GetCategoriesByBoardID(boardID int) ([]Category, error) {
+	rows, err := r.db.Query(`
+		SELECT id, board_id, name, color, description, sort_order
+		FROM categories
+		WHERE board_id = $1
+		ORDER BY sort_order, id
+	`, boardID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []Category
+	categories := []Category{}
 	for rows.Next() {
 		var cat Category
-		if err := rows.Scan(&cat.ID, &cat.Name); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.BoardID, &cat.Name, &cat.Color, &cat.Description, &cat.SortOrder); err != nil {
 			return nil, err
 		}
 		categories = append(categories, cat)
 	}
 
-	return categories, nil
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepositoryImpl) GetCategoryByID(id int) (*Category, error) {
+	var cat Category
+	err := r.db.QueryRow(`
+		SELECT id, board_id, name, color, description, sort_order
+		FROM categories
+		WHERE id = $1
+	`, id).Scan(&cat.ID, &cat.BoardID, &cat.Name, &cat.Color, &cat.Description, &cat.SortOrder)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No record found
+		}
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// CreateCategory adds a category at the end of its board's list
+func (r *CategoryRepositoryImpl) CreateCategory(category *Category) error {
+	return r.db.QueryRow(`
+		INSERT INTO categories (board_id, name, color, description, sort_order)
+		VALUES ($1, $2, $3, $4, (SELECT COALESCE(MAX(sort_order) + 1, 0) FROM categories WHERE board_id = $1))
+		RETURNING id, sort_order
+	`, category.BoardID, category.Name, category.Color, category.Description).Scan(&category.ID, &category.SortOrder)
+}
+
+// CreateDefaultCategories adds the default categories to a new board
+func (r *CategoryRepositoryImpl) CreateDefaultCategories(boardID int) error {
+	for _, def := range DefaultCategories {
+		category := def
+		category.BoardID = boardID
+		if err := r.CreateCategory(&category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CategoryRepositoryImpl) UpdateCategory(category *Category) error {
+	result, err := r.db.Exec(`
+		UPDATE categories SET name = $1, color = $2, description = $3
+		WHERE id = $4 AND board_id = $5
+	`, category.Name, category.Color, category.Description, category.ID, category.BoardID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// ReorderCategories sets a board's category order. categoryIDs must list every
+// category of the board exactly once.
+func (r *CategoryRepositoryImpl) ReorderCategories(boardID int, categoryIDs []int) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`
+			SELECT COUNT(*) FROM categories WHERE board_id = $1
+		`, boardID).Scan(&count); err != nil {
+			return err
+		}
+		if count != len(categoryIDs) {
+			return errors.New("category order must list every category of the board")
+		}
+
+		for position, id := range categoryIDs {
+			result, err := tx.Exec(`
+				UPDATE categories SET sort_order = $1 WHERE id = $2 AND board_id = $3
+			`, position, id, boardID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return ErrCategoryNotFound
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteCategory moves the category's feedback to the fallback category of the
+// same board, deletes the category and returns how many feedback items moved
+func (r *CategoryRepositoryImpl) DeleteCategory(id int, fallbackID int) (int64, error) {
+	var moved int64
+	err := inTx(r.db, func(tx *sql.Tx) error {
+		var sameBoard bool
+		err := tx.QueryRow(`
+			SELECT c.board_id = f.board_id
+			FROM categories c, categories f
+			WHERE c.id = $1 AND f.id = $2
+			FOR UPDATE
+		`, id, fallbackID).Scan(&sameBoard)
+		if err == sql.ErrNoRows || (err == nil && !sameBoard) {
+			return ErrCategoryNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`UPDATE feedback SET category_id = $1 WHERE category_id = $2`, fallbackID, id)
+		if err != nil {
+			return err
+		}
+		if moved, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM categories WHERE id = $1`, id)
+		return err
+	})
+	return moved, err
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *CategoryRepositoryImpl) WithTx(tx *sql.Tx) CategoryRepository {
+	return &CategoryRepositoryImpl{
+		db: tx,
+	}
 }
@@ -1,55 +1,196 @@
 package repositories
 
 import (
+	"canny-clone/utils"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// MaxCommentDepth is the deepest a reply can be nested; top-level comments have depth 0
+	MaxCommentDepth = 8
+	// maxThreadComments caps how many comments are loaded for one page of threads
+	maxThreadComments = 1000
 )
 
+// CommentAuthor is the compact profile shown next to a comment
+type CommentAuthor struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	Badge   string `json:"badge,omitempty"` // "admin" or "stakeholder"; empty for regular members
+}
+
+// CommentMention is a board member mentioned in a comment; Text is how the
+// mention was written, without the @
+type CommentMention struct {
+	UserID int    `json:"userId"`
+	Name   string `json:"name"`
+	Text   string `json:"text"`
+}
+
 type Comment struct {
-	ID        int       `json:"id"`
-	FeedbackID int      `json:"feedbackId"`
-	UserID    int       `json:"userId"`
-	Content   string    `json:"content"`
-	Likes     int       `json:"likes"`
-	Dislikes  int       `json:"dislikes"`
-	CreatedAt time.Time `json:"createdAt"`
-	IsLiked   bool      `json:"isLiked,omitempty"`
-	IsDisliked bool     `json:"isDisliked,omitempty"`
-	Replies   []Reply   `json:"replies,omitempty"`
-}
-
-type Reply struct {
-	ID        int       `json:"id"`
-	CommentID int       `json:"commentId"`
-	UserID    int       `json:"userId"`
-	Content   string    `json:"content"`
-	Likes     int       `json:"likes"`
-	Dislikes  int       `json:"dislikes"`
-	CreatedAt time.Time `json:"createdAt"`
-	IsLiked   bool      `json:"isLiked,omitempty"`
-	IsDisliked bool     `json:"isDisliked,omitempty"`
+	ID          int              `json:"id"`
+	FeedbackID  int              `json:"feedbackId"`
+	ParentID    *int             `json:"parentId"`
+	UserID      int              `json:"userId"`
+	Author      *CommentAuthor   `json:"author"`      // nil for tombstones
+	Content     string           `json:"content"`     // Markdown source
+	ContentHTML string           `json:"contentHtml"` // Content rendered to sanitized HTML
+	Likes       int              `json:"likes"`
+	Dislikes    int              `json:"dislikes"`
+	Depth       int              `json:"depth"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	IsLiked     bool             `json:"isLiked,omitempty"`
+	IsDisliked  bool             `json:"isDisliked,omitempty"`
+	EditedAt    *time.Time       `json:"editedAt,omitempty"` // Set once the comment has been edited
+	Deleted     bool             `json:"deleted,omitempty"`  // Tombstone left for a deleted comment with replies
+	Official    bool             `json:"official,omitempty"` // Pinned as the feedback item's official response
+	Internal    bool             `json:"internal,omitempty"` // Only visible to board stakeholders and admins
+	Mentions    []CommentMention `json:"mentions"`
+	Replies     []*Comment       `json:"replies"`
 }
 
 type CommentLikeInfo struct {
-	ID       int
-	IsLike   bool
+	ID     int
+	IsLike bool
+}
+
+// CommentInfo is what is needed to decide who may change a comment
+type CommentInfo struct {
+	UserID     int
+	FeedbackID int
+	BoardID    int
+	Age        time.Duration // Time since the comment was posted, measured by the database
+	Deleted    bool
+	Internal   bool
+}
+
+// CommentEdit is the content a comment had before one of its edits
+type CommentEdit struct {
+	PreviousContent string    `json:"previousContent"`
+	EditedBy        *int      `json:"editedBy"`
+	EditedAt        time.Time `json:"editedAt"`
 }
 
+// CommentFilter selects one page of top-level threads on a feedback item
+type CommentFilter struct {
+	FeedbackID      int
+	ViewerID        int    // fills in the viewer's own reactions
+	IncludeInternal bool   // include internal notes, for board stakeholders and admins
+	Sort            string // "new", "old" or "top"
+	Cursor          string // opaque cursor from a previous page, empty for the first page
+	Limit           int    // number of top-level comments per page
+}
+
+// CommentPage is one page of threads and the cursor for the next one
+type CommentPage struct {
+	// OfficialResponse is the pinned comment, without its replies, sent with the first page only
+	OfficialResponse *Comment   `json:"officialResponse,omitempty"`
+	Comments         []*Comment `json:"comments"`
+	NextCursor       string     `json:"nextCursor,omitempty"`
+	// Truncated is set when a single thread on the page has more comments than
+	// are loaded at once; its remaining replies are left out
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// commentColumns selects a comment, its author and the viewer's reaction; queries
+// using it must join feedback f, users u, board_members bm and comment_likes cl
+const commentColumns = `c.id, c.feedback_id, c.parent_id, c.user_id, c.content, c.content_html, c.likes, c.dislikes, c.depth,
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL, c.id = f.official_response_id, c.internal, cl.is_like,
+	u.name, u.picture, u.role, bm.role,
+	(SELECT json_agg(json_build_object('userId', m.user_id, 'name', mu.name, 'text', m.mention_text) ORDER BY m.user_id)
+		FROM comment_mentions m JOIN users mu ON mu.id = m.user_id WHERE m.comment_id = c.id)`
+
+// commentJoins are the joins commentColumns needs beyond comments c and feedback f;
+// the viewer's id is the placeholder
+const commentJoins = `
+		LEFT JOIN users u ON u.id = c.user_id
+		LEFT JOIN board_members bm ON bm.board_id = f.board_id AND bm.user_id = c.user_id
+		LEFT JOIN comment_likes cl ON cl.comment_id = c.id AND cl.user_id = $%d`
+
+// scanComment scans a row selected with commentColumns, followed by any extra columns
+func scanComment(row rowScanner, extra ...interface{}) (*Comment, error) {
+	c := &Comment{Replies: []*Comment{}, Mentions: []CommentMention{}}
+	var isLike *bool
+	var contentHTML, authorName, authorPicture, userRole, memberRole sql.NullString
+	var mentions []byte
+	dest := []interface{}{&c.ID, &c.FeedbackID, &c.ParentID, &c.UserID, &c.Content, &contentHTML, &c.Likes, &c.Dislikes, &c.Depth,
+		&c.CreatedAt, &c.EditedAt, &c.Deleted, &c.Official, &c.Internal, &isLike,
+		&authorName, &authorPicture, &userRole, &memberRole, &mentions}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if mentions != nil {
+		if err := json.Unmarshal(mentions, &c.Mentions); err != nil {
+			return nil, err
+		}
+	}
+	c.ContentHTML = storedHTML(contentHTML, c.Content)
+	if isLike != nil {
+		c.IsLiked = *isLike
+		c.IsDisliked = !*isLike
+	}
+	if authorName.Valid {
+		c.Author = &CommentAuthor{
+			ID:      c.UserID,
+			Name:    authorName.String,
+			Picture: authorPicture.String,
+			Badge:   authorBadge(userRole.String, memberRole.String),
+		}
+	}
+	return c, nil
+}
+
+// descendantOf matches the comments of alias below the path parent. It is a
+// range rather than "LIKE parent || '.%'": Postgres cannot take an index prefix
+// from a pattern that is not a constant, but compares a range against the
+// text_pattern_ops index on (feedback_id, path). Paths hold only digits and
+// dots, and '/' is the character after '.'.
+func descendantOf(alias, parent string) string {
+	return fmt.Sprintf("%[1]s.path ~>=~ (%[2]s || '.') AND %[1]s.path ~<~ (%[2]s || '/')", alias, parent)
+}
+
+// commentSortKeys holds the sort key expression, its SQL type and the direction
+// for each sort mode. Only top-level comments are sorted; replies stay oldest first.
+var commentSortKeys = map[string][3]string{
+	"new": {"c.created_at", "timestamp", "DESC"},
+	"old": {"c.created_at", "timestamp", "ASC"},
+	"top": {"c.likes", "int", "DESC"},
+}
+
+var (
+	// ErrCommentNotFound is returned when a comment does not exist
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrCommentTooDeep is returned when replying would nest deeper than MaxCommentDepth
+	ErrCommentTooDeep = errors.New("comment nested too deeply")
+)
+
 type CommentRepository interface {
-	GetCommentsByFeedbackID(feedbackID int, currentUserID int) ([]Comment, error)
-	CreateComment(feedbackID int, userID int, content string) (int, error)
-	CreateReply(commentID int, userID int, content string) (int, error)
+	GetCommentsByFeedbackID(filter CommentFilter) (*CommentPage, error)
+	CountCommentsByFeedbackID(feedbackID int, includeInternal bool) (int, error)
+	GetCommentBoardID(commentID int) (int, error)
+	CreateComment(feedbackID int, parentID int, userID int, content string, internal bool) (int, error)
 	GetCommentLikeInfo(commentID int, userID int) (*CommentLikeInfo, error)
-	GetReplyLikeInfo(replyID int, userID int) (*CommentLikeInfo, error)
-	UpsertCommentLike(commentID int, userID int, isLike bool) error
-	UpsertReplyLike(replyID int, userID int, isLike bool) error
-	DeleteCommentLike(likeID int) error
-	UpdateCommentLikeCount(commentID int, isLike bool, increment bool) error
-	UpdateReplyLikeCount(replyID int, isLike bool, increment bool) error
+	GetCommentReactionCounts(commentID int) (likes int, dislikes int, err error)
+	ToggleReaction(commentID int, userID int, isLike bool) error
+	RecountReactions() (int64, error)
+	RenderMissingContent(limit int) (int, error)
+	GetCommentInfo(commentID int) (*CommentInfo, error)
+	UpdateComment(commentID int, editorID int, content string) error
+	DeleteComment(commentID int, userID int) error
+	GetCommentEdits(commentID int) ([]CommentEdit, error)
+	SetCommentMentions(commentID int, mentions []CommentMention) ([]int, error)
+	WithTx(tx *sql.Tx) CommentRepository
 }
 
 type CommentRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewCommentRepository() CommentRepository {
@@ -58,107 +199,257 @@ func NewCommentRepository() CommentRepository {
 	}
 }
 
-func (r *CommentRepositoryImpl) GetCommentsByFeedbackID(feedbackID int, currentUserID int) ([]Comment, error) {
-	rows, err := r.db.Query(`
-		SELECT c.id, c.feedback_id, c.user_id, c.content, c.likes, c.dislikes, c.created_at
-		FROM comments c 
-		WHERE c.feedback_id = $1
-		ORDER BY c.created_at DESC
-	`, feedbackID)
+// GetCommentsByFeedbackID returns one page of a feedback item's threads: top-level
+// comments in the filter's sort order, each with its replies oldest first at any
+// depth. Deleted comments are kept as tombstones only while they have visible
+// replies. Threads, authors and the viewer's reactions are loaded in a single query.
+// When a page holds more than maxThreadComments comments it ends at the last
+// thread loaded in full, and the next page starts after it.
+func (r *CommentRepositoryImpl) GetCommentsByFeedbackID(filter CommentFilter) (*CommentPage, error) {
+	sortKey, ok := commentSortKeys[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+	keyExpr, keyType, dir := sortKey[0], sortKey[1], sortKey[2]
+
+	args := []interface{}{filter.FeedbackID, filter.Limit, filter.ViewerID, MaxCommentDepth, maxThreadComments, filter.IncludeInternal}
+	cursorCondition := ""
+	if filter.Cursor != "" {
+		cursor, err := decodeFeedbackCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != filter.Sort {
+			return nil, ErrInvalidCursor
+		}
+		op := "<"
+		if dir == "ASC" {
+			op = ">"
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		cursorCondition = fmt.Sprintf("AND (%s, c.id) %s ($%d::%s, $%d)", keyExpr, op, len(args)-1, keyType, len(args))
+	}
+
+	// One extra root is fetched to know whether there is a next page; its
+	// thread is not loaded. Deleted roots with nothing visible beneath them
+	// are skipped so they do not take a slot on the page. Ordering by the
+	// root's position, then the path, lists each thread depth-first so
+	// parents always come before their replies. Replies under an internal note
+	// are internal too, so hiding internal comments never orphans a visible one.
+	// A thread is matched as the path range from its root up to the root's
+	// path followed by '/', as descendantOf does. One row past the cap shows
+	// whether the page was cut short.
+	query := fmt.Sprintf(`
+		WITH roots AS (
+			SELECT c.id, (%[1]s)::text AS sort_key,
+				ROW_NUMBER() OVER (ORDER BY %[1]s %[2]s, c.id %[2]s) AS position
+			FROM comments c
+			WHERE c.feedback_id = $1 AND c.parent_id IS NULL AND ($6 OR NOT c.internal)
+				AND (c.deleted_at IS NULL OR EXISTS (
+					SELECT 1 FROM comments d
+					WHERE d.feedback_id = c.feedback_id AND %[6]s AND d.deleted_at IS NULL
+						AND ($6 OR NOT d.internal)
+				))
+				%[3]s
+			ORDER BY %[1]s %[2]s, c.id %[2]s
+			LIMIT $2 + 1
+		)
+		SELECT %[4]s, roots.sort_key, (SELECT COUNT(*) FROM roots)
+		FROM roots
+		JOIN comments c ON c.feedback_id = $1
+			AND c.path ~>=~ lpad(roots.id::text, 10, '0') AND c.path ~<~ (lpad(roots.id::text, 10, '0') || '/')
+		JOIN feedback f ON f.id = c.feedback_id
+		%[5]s
+		WHERE roots.position <= $2 AND c.depth <= $4 AND ($6 OR NOT c.internal)
+		ORDER BY roots.position, c.path
+		LIMIT $5 + 1
+	`, keyExpr, dir, cursorCondition, commentColumns, fmt.Sprintf(commentJoins, 3), descendantOf("d", "c.path"))
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []Comment
+	page := &CommentPage{Comments: []*Comment{}}
+	byID := make(map[int]*Comment)
+	var keys []string
+	rootCount, loaded, capped := 0, 0, false
 	for rows.Next() {
-		var c Comment
-		if err := rows.Scan(&c.ID, &c.FeedbackID, &c.UserID, &c.Content, &c.Likes, &c.Dislikes, &c.CreatedAt); err != nil {
+		if loaded == maxThreadComments {
+			capped = true
+			break
+		}
+		var key string
+		c, err := scanComment(rows, &key, &rootCount)
+		if err != nil {
 			return nil, err
 		}
-		
-		// Get user reactions
-		if currentUserID > 0 {
-			likeInfo, err := r.GetCommentLikeInfo(c.ID, currentUserID)
-			if err == nil && likeInfo != nil {
-				c.IsLiked = likeInfo.IsLike
-				c.IsDisliked = !likeInfo.IsLike
-			}
+
+		loaded++
+		byID[c.ID] = c
+		if c.ParentID == nil {
+			page.Comments = append(page.Comments, c)
+			keys = append(keys, key)
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// At the cap the last thread may be cut short, so the page ends before it.
+	// A thread too big to load in full on its own is sent truncated.
+	more := rootCount > filter.Limit
+	if capped {
+		if len(page.Comments) > 1 {
+			page.Comments = page.Comments[:len(page.Comments)-1]
+			keys = keys[:len(keys)-1]
+			more = true
+		} else {
+			page.Truncated = true
 		}
-		
-		// Get replies
-		replies, err := r.getRepliesForComment(c.ID, currentUserID)
+	}
+	if more && len(page.Comments) > 0 {
+		page.NextCursor = encodeFeedbackCursor(feedbackCursor{
+			Sort:  filter.Sort,
+			Value: keys[len(keys)-1],
+			ID:    page.Comments[len(page.Comments)-1].ID,
+		})
+	}
+	page.Comments = pruneDeleted(page.Comments)
+
+	if filter.Cursor == "" {
+		page.OfficialResponse, err = r.getOfficialResponse(filter.FeedbackID, filter.ViewerID)
 		if err != nil {
 			return nil, err
 		}
-		c.Replies = replies
-		
-		comments = append(comments, c)
 	}
+	return page, nil
+}
 
-	return comments, nil
+// getOfficialResponse returns the comment pinned on a feedback item, or nil
+func (r *CommentRepositoryImpl) getOfficialResponse(feedbackID int, viewerID int) (*Comment, error) {
+	c, err := scanComment(r.db.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM feedback f
+		JOIN comments c ON c.id = f.official_response_id AND c.deleted_at IS NULL AND NOT c.internal`+
+		fmt.Sprintf(commentJoins, 2)+`
+		WHERE f.id = $1
+	`, feedbackID, viewerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
 }
 
-func (r *CommentRepositoryImpl) getRepliesForComment(commentID int, currentUserID int) ([]Reply, error) {
-	rows, err := r.db.Query(`
-		SELECT r.id, r.comment_id, r.user_id, r.content, r.likes, r.dislikes, r.created_at
-		FROM comment_replies r 
-		WHERE r.comment_id = $1
-		ORDER BY r.created_at ASC
-	`, commentID)
-	if err != nil {
-		return nil, err
+// authorBadge picks the role badge shown next to a comment author. App admins
+// are badged everywhere; the stakeholder badge comes from the author's role on
+// the board itself, not their global role, so it marks the board's own team.
+func authorBadge(userRole, memberRole string) string {
+	switch {
+	case userRole == "app_admin":
+		return "admin"
+	case memberRole == "stakeholder":
+		return "stakeholder"
 	}
-	defer rows.Close()
+	return ""
+}
 
-	var replies []Reply
-	for rows.Next() {
-		var r Reply
-		if err := rows.Scan(&r.ID, &r.CommentID, &r.UserID, &r.Content, &r.Likes, &r.Dislikes, &r.CreatedAt); err != nil {
-			return nil, err
-		}
-		
-		// Get user reactions
-		if currentUserID > 0 {
-			likeInfo, err := r.GetReplyLikeInfo(r.ID, currentUserID)
-			if err == nil && likeInfo != nil {
-				r.IsLiked = likeInfo.IsLike
-				r.IsDisliked = !likeInfo.IsLike
+// pruneDeleted drops deleted comments without visible replies and blanks the
+// rest into tombstones
+func pruneDeleted(comments []*Comment) []*Comment {
+	kept := comments[:0]
+	for _, c := range comments {
+		c.Replies = pruneDeleted(c.Replies)
+		if c.Deleted {
+			if len(c.Replies) == 0 {
+				continue
 			}
+			c.Content = ""
+			c.ContentHTML = ""
+			c.Author = nil
+			c.Mentions = []CommentMention{}
+			c.EditedAt = nil
+			c.IsLiked, c.IsDisliked = false, false
 		}
-		
-		replies = append(replies, r)
+		kept = append(kept, c)
 	}
-
-	return replies, nil
+	return kept
 }
 
-func (r *CommentRepositoryImpl) CreateComment(feedbackID int, userID int, content string) (int, error) {
-	var commentID int
-	err := r.db.QueryRow(
-		"INSERT INTO comments (feedback_id, user_id, content) VALUES ($1, $2, $3) RETURNING id",
-		feedbackID, userID, content,
-	).Scan(&commentID)
-	
+// CountCommentsByFeedbackID counts the visible comments on a feedback item at any
+// depth, counting internal notes only when includeInternal is set
+func (r *CommentRepositoryImpl) CountCommentsByFeedbackID(feedbackID int, includeInternal bool) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM comments WHERE feedback_id = $1 AND deleted_at IS NULL AND ($2 OR NOT internal)
+	`, feedbackID, includeInternal).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
-	
-	return commentID, nil
+	return count, nil
 }
 
-func (r *CommentRepositoryImpl) CreateReply(commentID int, userID int, content string) (int, error) {
-	var replyID int
-	err := r.db.QueryRow(
-		"INSERT INTO comment_replies (comment_id, user_id, content) VALUES ($1, $2, $3) RETURNING id",
-		commentID, userID, content,
-	).Scan(&replyID)
-	
+// GetCommentBoardID returns the board a comment belongs to
+func (r *CommentRepositoryImpl) GetCommentBoardID(commentID int) (int, error) {
+	var boardID int
+	err := r.db.QueryRow(`
+		SELECT f.board_id
+		FROM comments c
+		JOIN feedback f ON f.id = c.feedback_id
+		WHERE c.id = $1
+	`, commentID).Scan(&boardID)
+	if err == sql.ErrNoRows {
+		return 0, ErrCommentNotFound
+	}
+	return boardID, err
+}
+
+// CreateComment adds a comment to a feedback item. A non-zero parentID makes it a
+// reply, which fails with ErrCommentNotFound if the parent is missing, deleted or
+// on another feedback item, and with ErrCommentTooDeep past MaxCommentDepth.
+// Replies to an internal note are always internal.
+func (r *CommentRepositoryImpl) CreateComment(feedbackID int, parentID int, userID int, content string, internal bool) (int, error) {
+	var parent interface{}
+	depth, pathPrefix := 0, ""
+
+	if parentID != 0 {
+		var parentDepth int
+		var parentPath string
+		var parentInternal bool
+		err := r.db.QueryRow(`
+			SELECT depth, path, internal FROM comments
+			WHERE id = $1 AND feedback_id = $2 AND deleted_at IS NULL
+		`, parentID, feedbackID).Scan(&parentDepth, &parentPath, &parentInternal)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, ErrCommentNotFound
+			}
+			return 0, err
+		}
+		if parentDepth >= MaxCommentDepth {
+			return 0, ErrCommentTooDeep
+		}
+		parent, depth, pathPrefix = parentID, parentDepth+1, parentPath+"."
+		internal = internal || parentInternal
+	}
+
+	// The id is drawn up front so the path can include it
+	var commentID int
+	err := r.db.QueryRow(`
+		WITH next AS (SELECT nextval(pg_get_serial_sequence('comments', 'id')) AS id)
+		INSERT INTO comments (id, feedback_id, parent_id, user_id, content, content_html, depth, path, internal)
+		SELECT id, $1, $2, $3, $4, $8, $5, $6::text || lpad(id::text, 10, '0'), $7 FROM next
+		RETURNING id
+	`, feedbackID, parent, userID, content, depth, pathPrefix, internal, utils.RenderMarkdown(content)).Scan(&commentID)
+
 	if err != nil {
 		return 0, err
 	}
-	
-	return replyID, nil
+
+	return commentID, nil
 }
 
 func (r *CommentRepositoryImpl) GetCommentLikeInfo(commentID int, userID int) (*CommentLikeInfo, error) {
@@ -167,93 +458,279 @@ func (r *CommentRepositoryImpl) GetCommentLikeInfo(commentID int, userID int) (*
 		"SELECT id, is_like FROM comment_likes WHERE comment_id = $1 AND user_id = $2",
 		commentID, userID,
 	).Scan(&info.ID, &info.IsLike)
-	
+
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No reaction found
 		}
 		return nil, err
 	}
-	
+
 	return &info, nil
 }
 
-func (r *CommentRepositoryImpl) GetReplyLikeInfo(replyID int, userID int) (*CommentLikeInfo, error) {
-	var info CommentLikeInfo
-	err := r.db.QueryRow(
-		"SELECT id, is_like FROM comment_likes WHERE reply_id = $1 AND user_id = $2",
-		replyID, userID,
-	).Scan(&info.ID, &info.IsLike)
-	
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No reaction found
-		}
-		return nil, err
+// GetCommentReactionCounts returns a comment's like and dislike counters
+func (r *CommentRepositoryImpl) GetCommentReactionCounts(commentID int) (likes int, dislikes int, err error) {
+	err = r.db.QueryRow(`SELECT COALESCE(likes, 0), COALESCE(dislikes, 0) FROM comments WHERE id = $1`, commentID).Scan(&likes, &dislikes)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrCommentNotFound
 	}
-	
-	return &info, nil
+	return likes, dislikes, err
 }
 
-func (r *CommentRepositoryImpl) UpsertCommentLike(commentID int, userID int, isLike bool) error {
-	_, err := r.db.Exec(
-		"INSERT INTO comment_likes (comment_id, user_id, is_like) VALUES ($1, $2, $3) ON CONFLICT (comment_id, user_id) DO UPDATE SET is_like = $3",
-		commentID, userID, isLike,
-	)
-	
-	return err
+// ToggleReaction applies a like or dislike to a comment in one transaction.
+// Repeating the same reaction removes it, the opposite reaction replaces it, and
+// the comment's counters are rebuilt from comment_likes.
+func (r *CommentRepositoryImpl) ToggleReaction(commentID int, userID int, isLike bool) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		// Lock the comment row so concurrent reactions on it are applied one at a time
+		var lockedID int
+		err := tx.QueryRow("SELECT id FROM comments WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", commentID).Scan(&lockedID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrCommentNotFound
+			}
+			return err
+		}
+
+		var likeID int
+		var existing bool
+		err = tx.QueryRow(
+			"SELECT id, is_like FROM comment_likes WHERE comment_id = $1 AND user_id = $2",
+			commentID, userID,
+		).Scan(&likeID, &existing)
+
+		switch {
+		case err == sql.ErrNoRows:
+			// New reaction
+			_, err = tx.Exec(
+				"INSERT INTO comment_likes (comment_id, user_id, is_like) VALUES ($1, $2, $3)",
+				commentID, userID, isLike,
+			)
+		case err != nil:
+			return err
+		case existing == isLike:
+			// Same reaction - remove it (toggle off)
+			_, err = tx.Exec("DELETE FROM comment_likes WHERE id = $1", likeID)
+		default:
+			// Different reaction - switch it
+			_, err = tx.Exec("UPDATE comment_likes SET is_like = $1 WHERE id = $2", isLike, likeID)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(recountReactionsQuery+` WHERE c.id = $1`, commentID)
+		return err
+	})
 }
 
-func (r *CommentRepositoryImpl) UpsertReplyLike(replyID int, userID int, isLike bool) error {
-	_, err := r.db.Exec(
-		"INSERT INTO comment_likes (reply_id, user_id, is_like) VALUES ($1, $2, $3) ON CONFLICT (reply_id, user_id) DO UPDATE SET is_like = $3",
-		replyID, userID, isLike,
-	)
-	
-	return err
+// recountReactionsQuery rebuilds reaction counters from comment_likes
+const recountReactionsQuery = `
+	UPDATE comments c SET
+		likes = (SELECT COUNT(*) FROM comment_likes cl WHERE cl.comment_id = c.id AND cl.is_like),
+		dislikes = (SELECT COUNT(*) FROM comment_likes cl WHERE cl.comment_id = c.id AND NOT cl.is_like)`
+
+// reactionsDrifted matches comments whose counters disagree with comment_likes
+const reactionsDrifted = `
+	(c.likes IS DISTINCT FROM (SELECT COUNT(*) FROM comment_likes cl WHERE cl.comment_id = c.id AND cl.is_like)
+	 OR c.dislikes IS DISTINCT FROM (SELECT COUNT(*) FROM comment_likes cl WHERE cl.comment_id = c.id AND NOT cl.is_like))`
+
+// RenderMissingContent stores the rendered HTML of up to limit comments
+// written before it was kept, and returns how many it rendered
+func (r *CommentRepositoryImpl) RenderMissingContent(limit int) (int, error) {
+	return renderMissingHTML(r.db, "comments", "content", "content_html", limit)
 }
 
-func (r *CommentRepositoryImpl) DeleteCommentLike(likeID int) error {
-	_, err := r.db.Exec("DELETE FROM comment_likes WHERE id = $1", likeID)
-	return err
+// RecountReactions rebuilds likes and dislikes on every comment from
+// comment_likes and returns the number of comments that were out of sync.
+// Reactions change comment_likes while holding the comment row lock, so the
+// drifted comments are locked first and recounted in a later statement, whose
+// snapshot sees every reaction made before the locks were granted.
+func (r *CommentRepositoryImpl) RecountReactions() (int64, error) {
+	var updated int64
+	err := inTx(r.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT c.id FROM comments c WHERE` + reactionsDrifted + ` ORDER BY c.id FOR UPDATE`)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		result, err := tx.Exec(recountReactionsQuery+` WHERE c.id = ANY($1) AND`+reactionsDrifted, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		updated, err = result.RowsAffected()
+		return err
+	})
+	return updated, err
 }
 
-func (r *CommentRepositoryImpl) UpdateCommentLikeCount(commentID int, isLike bool, increment bool) error {
-	var query string
-	if isLike {
-		if increment {
-			query = "UPDATE comments SET likes = likes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE comments SET likes = likes - 1 WHERE id = $1"
+// GetCommentInfo returns the author, board and state of a comment
+func (r *CommentRepositoryImpl) GetCommentInfo(commentID int) (*CommentInfo, error) {
+	var info CommentInfo
+	var ageSeconds float64
+	err := r.db.QueryRow(`
+		SELECT c.user_id, c.feedback_id, f.board_id, EXTRACT(EPOCH FROM (LOCALTIMESTAMP - c.created_at)), c.deleted_at IS NOT NULL, c.internal
+		FROM comments c
+		JOIN feedback f ON f.id = c.feedback_id
+		WHERE c.id = $1
+	`, commentID).Scan(&info.UserID, &info.FeedbackID, &info.BoardID, &ageSeconds, &info.Deleted, &info.Internal)
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Age = time.Duration(ageSeconds * float64(time.Second))
+	return &info, nil
+}
+
+// UpdateComment replaces the content of a comment, keeping the previous
+// content in comment_edits
+func (r *CommentRepositoryImpl) UpdateComment(commentID int, editorID int, content string) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		var previous string
+		err := tx.QueryRow("SELECT content FROM comments WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", commentID).Scan(&previous)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrCommentNotFound
+			}
+			return err
 		}
-	} else {
-		if increment {
-			query = "UPDATE comments SET dislikes = dislikes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE comments SET dislikes = dislikes - 1 WHERE id = $1"
+		if previous == content {
+			return nil
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO comment_edits (comment_id, previous_content, edited_by) VALUES ($1, $2, $3)",
+			commentID, previous, editorID,
+		)
+		if err != nil {
+			return err
 		}
+
+		_, err = tx.Exec("UPDATE comments SET content = $1, content_html = $2, edited_at = NOW() WHERE id = $3",
+			content, utils.RenderMarkdown(content), commentID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// DeleteComment soft-deletes a comment. Listings show deleted comments that
+// still have replies as tombstones and hide the rest.
+func (r *CommentRepositoryImpl) DeleteComment(commentID int, userID int) error {
+	result, err := r.db.Exec(`
+		UPDATE comments SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, commentID, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// GetCommentEdits returns the earlier versions of a comment, newest first
+func (r *CommentRepositoryImpl) GetCommentEdits(commentID int) ([]CommentEdit, error) {
+	rows, err := r.db.Query(`
+		SELECT previous_content, edited_by, edited_at
+		FROM comment_edits
+		WHERE comment_id = $1
+		ORDER BY edited_at DESC, id DESC
+	`, commentID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	_, err := r.db.Exec(query, commentID)
-	return err
+	edits := []CommentEdit{}
+	for rows.Next() {
+		var edit CommentEdit
+		if err := rows.Scan(&edit.PreviousContent, &edit.EditedBy, &edit.EditedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
 }
 
-func (r *CommentRepositoryImpl) UpdateReplyLikeCount(replyID int, isLike bool, increment bool) error {
-	var query string
-	if isLike {
-		if increment {
-			query = "UPDATE comment_replies SET likes = likes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE comment_replies SET likes = likes - 1 WHERE id = $1"
+// SetCommentMentions replaces the users mentioned in a comment and returns the
+// ids of those who were not mentioned in it before
+func (r *CommentRepositoryImpl) SetCommentMentions(commentID int, mentions []CommentMention) ([]int, error) {
+	userIDs := make([]int, len(mentions))
+	texts := make([]string, len(mentions))
+	for i, m := range mentions {
+		userIDs[i], texts[i] = m.UserID, m.Text
+	}
+
+	added := []int{}
+	err := inTx(r.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			DELETE FROM comment_mentions WHERE comment_id = $1 AND NOT (user_id = ANY($2::int[]))
+		`, commentID, pq.Array(userIDs))
+		if err != nil {
+			return err
 		}
-	} else {
-		if increment {
-			query = "UPDATE comment_replies SET dislikes = dislikes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE comment_replies SET dislikes = dislikes - 1 WHERE id = $1"
+
+		// xmax is 0 on rows the upsert inserted rather than updated
+		rows, err := tx.Query(`
+			INSERT INTO comment_mentions (comment_id, user_id, mention_text)
+			SELECT $1, m.user_id, m.mention_text
+			FROM unnest($2::int[], $3::text[]) AS m(user_id, mention_text)
+			ON CONFLICT (comment_id, user_id) DO UPDATE SET mention_text = EXCLUDED.mention_text
+			RETURNING user_id, xmax = 0
+		`, commentID, pq.Array(userIDs), pq.Array(texts))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID int
+			var inserted bool
+			if err := rows.Scan(&userID, &inserted); err != nil {
+				return err
+			}
+			if inserted {
+				added = append(added, userID)
+			}
 		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
+	return added, nil
+}
 
-	_, err := r.db.Exec(query, replyID)
-	return err
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *CommentRepositoryImpl) WithTx(tx *sql.Tx) CommentRepository {
+	return &CommentRepositoryImpl{
+		db: tx,
+	}
 }
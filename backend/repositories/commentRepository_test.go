@@ -0,0 +1,339 @@
+package repositories
+
+import (
+	"canny-clone/internal/testdb"
+	"canny-clone/utils"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// countingConn counts the statements sent over a database connection
+type countingConn struct {
+	driver.Conn
+	queries *int64
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(c.queries, 1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	atomic.AddInt64(c.queries, 1)
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+// countingConnector opens Postgres connections that count their statements
+type countingConnector struct {
+	driver.Connector
+	queries int64
+}
+
+func (c *countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{Conn: conn, queries: &c.queries}, nil
+}
+
+// openCountingDB opens a pool on the test database that counts the statements
+// run through it
+func openCountingDB(tb testing.TB) (*sql.DB, *countingConnector) {
+	tb.Helper()
+	connector, err := pq.NewConnector(testdb.URL(tb))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	counter := &countingConnector{Connector: connector}
+	conn := sql.OpenDB(counter)
+	tb.Cleanup(func() { conn.Close() })
+	return conn, counter
+}
+
+// seedThread adds roots top-level comments with two nested replies each. The
+// viewer reacts to every other comment.
+func seedThread(tb testing.TB, db *sql.DB, roots int) (feedbackID, viewerID int) {
+	tb.Helper()
+	authorID := testdb.SeedUser(tb, db, "user")
+	viewerID = testdb.SeedUser(tb, db, "user")
+	feedbackID = testdb.SeedFeedback(tb, db, authorID, "Threads", "Seeded for a test").ID
+
+	comments := NewCommentRepository()
+	for i := 0; i < roots; i++ {
+		parentID := 0
+		for depth := 0; depth < 3; depth++ {
+			id, err := comments.CreateComment(feedbackID, parentID, authorID, fmt.Sprintf("Comment %d.%d", i, depth), false)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			if (i+depth)%2 == 0 {
+				if err := comments.ToggleReaction(id, viewerID, true); err != nil {
+					tb.Fatal(err)
+				}
+			}
+			parentID = id
+		}
+	}
+	return feedbackID, viewerID
+}
+
+// loadThreadPerComment runs the statements GetCommentsByFeedbackID ran before
+// the viewer's reactions were joined into the thread query: the thread query
+// as it was then, and one reaction lookup per comment. It is the benchmark's
+// baseline and returns the number of comments loaded.
+func loadThreadPerComment(conn *sql.DB, feedbackID, viewerID int) (int, error) {
+	repo := &CommentRepositoryImpl{db: conn}
+	rows, err := conn.Query(`
+		SELECT c.id, c.feedback_id, c.parent_id, c.user_id, c.content, c.likes, c.dislikes, c.depth,
+			c.created_at, c.edited_at, c.deleted_at IS NOT NULL
+		FROM comments c
+		WHERE c.feedback_id = $1 AND c.depth <= $2
+		ORDER BY split_part(c.path, '.', 1) DESC, c.path
+		LIMIT $3
+	`, feedbackID, MaxCommentDepth, maxThreadComments)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	loaded := 0
+	for rows.Next() {
+		c := &Comment{}
+		if err := rows.Scan(&c.ID, &c.FeedbackID, &c.ParentID, &c.UserID, &c.Content, &c.Likes, &c.Dislikes, &c.Depth,
+			&c.CreatedAt, &c.EditedAt, &c.Deleted); err != nil {
+			return 0, err
+		}
+		if _, err := repo.GetCommentLikeInfo(c.ID, viewerID); err != nil {
+			return 0, err
+		}
+		loaded++
+	}
+	return loaded, rows.Err()
+}
+
+// BenchmarkCommentThreadQueries reports the time and statements needed to load
+// threads with the viewer's reactions, against the per-comment lookups the
+// thread loader used to make
+func BenchmarkCommentThreadQueries(b *testing.B) {
+	db := openTestDB(b)
+
+	for _, roots := range []int{10, 50} {
+		feedbackID, viewerID := seedThread(b, db, roots)
+		filter := CommentFilter{FeedbackID: feedbackID, ViewerID: viewerID, Sort: "new", Limit: roots}
+
+		b.Run(fmt.Sprintf("joined/%d-threads", roots), func(b *testing.B) {
+			conn, counter := openCountingDB(b)
+			repo := &CommentRepositoryImpl{db: conn}
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetCommentsByFeedbackID(filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(counter.queries)/float64(b.N), "queries/op")
+		})
+
+		b.Run(fmt.Sprintf("per-comment/%d-threads", roots), func(b *testing.B) {
+			conn, counter := openCountingDB(b)
+			for i := 0; i < b.N; i++ {
+				if _, err := loadThreadPerComment(conn, feedbackID, viewerID); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(counter.queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+// The thread loader must not issue more statements as a thread grows
+func TestCommentThreadQueryCount(t *testing.T) {
+	db := openTestDB(t)
+
+	counts := map[int]int64{}
+	for _, roots := range []int{1, 20} {
+		feedbackID, viewerID := seedThread(t, db, roots)
+		conn, counter := openCountingDB(t)
+		repo := &CommentRepositoryImpl{db: conn}
+
+		page, err := repo.GetCommentsByFeedbackID(CommentFilter{FeedbackID: feedbackID, ViewerID: viewerID, Sort: "new", Limit: roots})
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, liked := 0, 0
+		visitComments(page.Comments, func(c *Comment) error {
+			loaded++
+			if c.IsLiked {
+				liked++
+			}
+			return nil
+		})
+		if loaded != roots*3 {
+			t.Errorf("loaded %d comments, want %d", loaded, roots*3)
+		}
+		if want := (roots*3 + 1) / 2; liked != want {
+			t.Errorf("%d comments show the viewer's like, want %d", liked, want)
+		}
+		counts[roots] = counter.queries
+	}
+
+	if counts[20] != counts[1] {
+		t.Errorf("loading 20 threads took %d queries, 1 thread took %d", counts[20], counts[1])
+	}
+}
+
+func visitComments(comments []*Comment, fn func(c *Comment) error) error {
+	for _, c := range comments {
+		if err := fn(c); err != nil {
+			return err
+		}
+		if err := visitComments(c.Replies, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStoredCommentHTML(t *testing.T) {
+	db := openTestDB(t)
+	authorID := testdb.SeedUser(t, db, "user")
+	feedbackID := testdb.SeedFeedback(t, db, authorID, "Dark mode", "Seeded for a test").ID
+	comments := NewCommentRepository()
+
+	id, err := comments.CreateComment(feedbackID, 0, authorID, "First <b>draft</b>", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedContentHTML(t, db, id, utils.RenderMarkdown("First <b>draft</b>"))
+
+	content := "[Spec](https://example.com/spec) and [x](javascript:alert(1))"
+	if err := comments.UpdateComment(id, authorID, content); err != nil {
+		t.Fatal(err)
+	}
+	storedContentHTML(t, db, id, utils.RenderMarkdown(content))
+
+	if _, err := db.Exec("UPDATE comments SET content_html = NULL WHERE id = $1", id); err != nil {
+		t.Fatal(err)
+	}
+	page, err := comments.GetCommentsByFeedbackID(CommentFilter{FeedbackID: feedbackID, Sort: "new", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Comments) != 1 || page.Comments[0].ContentHTML != utils.RenderMarkdown(content) {
+		t.Errorf("comments without stored HTML = %+v, want one rendering %q", page.Comments, content)
+	}
+
+	for {
+		n, err := comments.RenderMissingContent(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 100 {
+			break
+		}
+	}
+	storedContentHTML(t, db, id, utils.RenderMarkdown(content))
+}
+
+func storedContentHTML(t *testing.T, db *sql.DB, commentID int, want string) {
+	t.Helper()
+	var stored sql.NullString
+	if err := db.QueryRow("SELECT content_html FROM comments WHERE id = $1", commentID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Valid || stored.String != want {
+		t.Errorf("stored content_html = %q (valid %v), want %q", stored.String, stored.Valid, want)
+	}
+}
+
+// A page that reaches maxThreadComments ends at the last complete thread, and
+// the following pages pick up from there
+func TestCommentPageEndsAtCompleteThread(t *testing.T) {
+	db := openTestDB(t)
+	roots := maxThreadComments/3 + 10
+	feedbackID, viewerID := seedThread(t, db, roots)
+	repo := NewCommentRepository()
+
+	filter := CommentFilter{FeedbackID: feedbackID, ViewerID: viewerID, Sort: "old", Limit: roots}
+	threads, pages := 0, 0
+	for {
+		page, err := repo.GetCommentsByFeedbackID(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, c := range page.Comments {
+			threads++
+			if len(c.Replies) != 1 || len(c.Replies[0].Replies) != 1 {
+				t.Fatalf("thread %d was loaded incomplete", c.ID)
+			}
+		}
+		if page.Truncated {
+			t.Error("page of small threads was marked truncated")
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if threads != roots || pages < 2 {
+		t.Errorf("loaded %d threads over %d pages, want %d over at least 2", threads, pages, roots)
+	}
+}
+
+// A single thread longer than maxThreadComments is sent cut short and flagged
+func TestCommentPageTruncatesLongThread(t *testing.T) {
+	db := openTestDB(t)
+	authorID := testdb.SeedUser(t, db, "user")
+	feedbackID := testdb.SeedFeedback(t, db, authorID, "Long thread", "Seeded for a test").ID
+	repo := NewCommentRepository()
+
+	rootID, err := repo.CreateComment(feedbackID, 0, authorID, "Root", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxThreadComments; i++ {
+		if _, err := repo.CreateComment(feedbackID, rootID, authorID, fmt.Sprintf("Reply %d", i), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := repo.GetCommentsByFeedbackID(CommentFilter{FeedbackID: feedbackID, Sort: "new", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Comments) != 1 || !page.Truncated || page.NextCursor != "" {
+		t.Fatalf("got %d threads, truncated %v, cursor %q; want the one thread, truncated, without a cursor",
+			len(page.Comments), page.Truncated, page.NextCursor)
+	}
+	if got := len(page.Comments[0].Replies); got != maxThreadComments-1 {
+		t.Errorf("loaded %d replies, want %d", got, maxThreadComments-1)
+	}
+}
+
+func TestAuthorBadge(t *testing.T) {
+	tests := []struct {
+		userRole, memberRole string
+		want                 string
+	}{
+		{"app_admin", "", "admin"},
+		{"app_admin", "user", "admin"},
+		{"user", "stakeholder", "stakeholder"},
+		{"stakeholder", "stakeholder", "stakeholder"},
+		// A global stakeholder is not badged on boards they are not a stakeholder of
+		{"stakeholder", "", ""},
+		{"stakeholder", "user", ""},
+		{"user", "user", ""},
+		{"user", "", ""},
+	}
+	for _, tt := range tests {
+		if got := authorBadge(tt.userRole, tt.memberRole); got != tt.want {
+			t.Errorf("authorBadge(%q, %q) = %q, want %q", tt.userRole, tt.memberRole, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,13 @@ import (
 
 var db *sql.DB
 
+// DBTX is the subset of *sql.DB and *sql.Tx used by the repositories
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func SetDB(database *sql.DB) {
 	db = database
 }
@@ -14,3 +21,27 @@ func SetDB(database *sql.DB) {
 func GetDB() *sql.DB {
 	return db
 }
+
+// RunInTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func RunInTx(fn func(tx *sql.Tx) error) error {
+	return inTx(db, fn)
+}
+
+// inTx runs fn in a transaction on conn. If conn is already a transaction, fn joins it
+// and the caller stays responsible for committing.
+func inTx(conn DBTX, fn func(tx *sql.Tx) error) error {
+	if tx, ok := conn.(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := conn.(*sql.DB).Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"canny-clone/internal/testdb"
+	"database/sql"
+	"testing"
+)
+
+// openTestDB points the repositories at the test database
+func openTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	conn := testdb.Open(tb)
+	SetDB(conn)
+	return conn
+}
@@ -1,30 +1,239 @@
 package repositories
 
 import (
+	"canny-clone/utils"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
 type Feedback struct {
-	ID          int     `json:"id"`
-	BoardID     int     `json:"boardId"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	CategoryID  int     `json:"categoryId"`
-	Upvotes     int     `json:"upvotes"`
-	Downvotes   int     `json:"downvotes"`
-	Status      string  `json:"status"`
+	ID          int             `json:"id"`
+	BoardID     int             `json:"boardId"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"` // Markdown source
+	CategoryID  int             `json:"categoryId"`
+	Upvotes     int             `json:"upvotes"`
+	Downvotes   int             `json:"downvotes"`
+	Status      string          `json:"status"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	MergedInto  *int            `json:"mergedInto,omitempty"` // Set once merged into another feedback
+	UpdatedAt   *time.Time      `json:"updatedAt,omitempty"`  // Set once the post has been edited
+	DeletedAt   *time.Time      `json:"deletedAt,omitempty"`  // Set while the post is soft-deleted
+	UserID      *int            `json:"userId"`               // Author, nil for posts that predate authorship
+	Author      *FeedbackAuthor `json:"author"`
+	UserVote    *string         `json:"userVote,omitempty"` // The viewer's vote, when the query knows the viewer
+
+	DescriptionHTML  string            `json:"descriptionHtml"`            // Description rendered to sanitized HTML
+	OfficialResponse *OfficialResponse `json:"officialResponse,omitempty"` // Comment pinned by a stakeholder
+}
+
+// OfficialResponse is the comment a stakeholder pinned as the answer to a feedback item
+type OfficialResponse struct {
+	CommentID   int            `json:"commentId"`
+	Content     string         `json:"content"`
+	ContentHTML string         `json:"contentHtml"`
+	Author      *CommentAuthor `json:"author"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	EditedAt    *time.Time     `json:"editedAt,omitempty"`
+}
+
+// FeedbackAuthor is the public profile of the user who posted a feedback item
+type FeedbackAuthor struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture,omitempty"`
+}
+
+// FeedbackRevision is the content of one version of a feedback item
+type FeedbackRevision struct {
+	ID          int             `json:"id"`
+	FeedbackID  int             `json:"feedbackId"`
+	Revision    int             `json:"revision"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	CategoryID  int             `json:"categoryId"`
+	Editor      *FeedbackAuthor `json:"editor"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// FeedbackStatuses lists the statuses a feedback item can have, in the order
+// items usually move through them
+var FeedbackStatuses = []string{"pending", "reviewing", "approved", "declined", "completed"}
+
+// IsFeedbackStatus reports whether status is one of FeedbackStatuses
+func IsFeedbackStatus(status string) bool {
+	for _, s := range FeedbackStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedbackStatusChange records a feedback item being moved from one status to another
+type FeedbackStatusChange struct {
+	ID         int             `json:"id"`
+	FromStatus string          `json:"fromStatus"`
+	ToStatus   string          `json:"toStatus"`
+	ChangedBy  *FeedbackAuthor `json:"changedBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// FeedbackFilter selects and orders a page of feedback
+type FeedbackFilter struct {
+	BoardID    int    // 0 for any board
+	MemberID   int    // if set, only boards this user is a member of
+	AuthorID   int    // if set, only feedback posted by this user
+	VoterID    int    // if set, only feedback this user voted on, with their vote filled in
+	Merged     bool   // include feedback merged into another item
+	Deleted    bool   // list soft-deleted feedback instead of live feedback
+	Status     string // empty for any status
+	CategoryID int    // 0 for any category
+	Sort       string // "top", "new" or "trending"
+	Cursor     string // opaque cursor from a previous page, empty for the first page
+	Limit      int
+}
+
+// FeedbackPage is one page of feedback and the cursor for the next one
+type FeedbackPage struct {
+	Feedbacks  []Feedback `json:"feedbacks"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrFeedbackMerged is returned when changing feedback that was merged into another
+	ErrFeedbackMerged = errors.New("feedback has been merged")
+	// ErrFeedbackNotFound is returned when feedback does not exist or is in the wrong state
+	ErrFeedbackNotFound = errors.New("feedback not found")
+)
+
+// feedbackCursor is the decoded form of a pagination cursor
+type feedbackCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"` // sort key of the last item, as text
+	ID    int       `json:"id"`
+	AsOf  time.Time `json:"t"` // reference time for trending scores
+}
+
+// feedbackSortKeys holds the sort key expression and its SQL type for each sort mode.
+// The trending key takes the reference time as its only placeholder.
+var feedbackSortKeys = map[string][2]string{
+	"top":      {"(f.upvotes - f.downvotes)", "int"},
+	"new":      {"f.created_at", "timestamp"},
+	"trending": {"((f.upvotes - f.downvotes)::float8 / POWER(GREATEST(EXTRACT(EPOCH FROM ($%d::timestamp - f.created_at)), 0) / 3600 + 2, 1.5))", "float8"},
+}
+
+// feedbackColumns selects a feedback row, its author and its official response;
+// queries using it must read from feedbackFrom
+const feedbackColumns = `f.id, f.board_id, f.title, f.description, f.description_html, f.category_id, f.upvotes, f.downvotes,
+	COALESCE(f.status, 'pending'), f.created_at, f.merged_into, f.updated_at, f.deleted_at, f.user_id, u.name, u.picture,
+	oc.id, oc.content, oc.content_html, oc.created_at, oc.edited_at, oc.user_id, ou.name, ou.picture, ou.role, obm.role`
+
+// feedbackFrom joins feedback to the rows feedbackColumns reads. A pinned
+// comment that has since been deleted is left out.
+const feedbackFrom = `feedback f
+	LEFT JOIN users u ON u.id = f.user_id
+	LEFT JOIN comments oc ON oc.id = f.official_response_id AND oc.deleted_at IS NULL AND NOT oc.internal
+	LEFT JOIN users ou ON ou.id = oc.user_id
+	LEFT JOIN board_members obm ON obm.board_id = f.board_id AND obm.user_id = oc.user_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// storedHTML returns the HTML rendered when a description or comment was
+// written, rendering src for rows the startup backfill has not reached yet
+func storedHTML(rendered sql.NullString, src string) string {
+	if rendered.Valid {
+		return rendered.String
+	}
+	return utils.RenderMarkdown(src)
+}
+
+// scanFeedback scans a row selected with feedbackColumns, followed by any extra columns
+func scanFeedback(row rowScanner, extra ...interface{}) (*Feedback, error) {
+	var fb Feedback
+	var descriptionHTML, authorName, authorPicture sql.NullString
+	var response OfficialResponse
+	var responseID, responseUserID *int
+	var responseContent, responseContentHTML, responseAuthorName, responseAuthorPicture, responseUserRole, responseMemberRole sql.NullString
+	var responseCreatedAt *time.Time
+	dest := []interface{}{&fb.ID, &fb.BoardID, &fb.Title, &fb.Description, &descriptionHTML, &fb.CategoryID, &fb.Upvotes, &fb.Downvotes,
+		&fb.Status, &fb.CreatedAt, &fb.MergedInto, &fb.UpdatedAt, &fb.DeletedAt, &fb.UserID, &authorName, &authorPicture,
+		&responseID, &responseContent, &responseContentHTML, &responseCreatedAt, &response.EditedAt, &responseUserID,
+		&responseAuthorName, &responseAuthorPicture, &responseUserRole, &responseMemberRole}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	fb.DescriptionHTML = storedHTML(descriptionHTML, fb.Description)
+	if fb.UserID != nil && authorName.Valid {
+		fb.Author = &FeedbackAuthor{ID: *fb.UserID, Name: authorName.String, Picture: authorPicture.String}
+	}
+	if responseID != nil {
+		response.CommentID = *responseID
+		response.Content = responseContent.String
+		response.ContentHTML = storedHTML(responseContentHTML, response.Content)
+		response.CreatedAt = *responseCreatedAt
+		if responseUserID != nil && responseAuthorName.Valid {
+			response.Author = &CommentAuthor{
+				ID:      *responseUserID,
+				Name:    responseAuthorName.String,
+				Picture: responseAuthorPicture.String,
+				Badge:   authorBadge(responseUserRole.String, responseMemberRole.String),
+			}
+		}
+		fb.OfficialResponse = &response
+	}
+	return &fb, nil
+}
+
+func encodeFeedbackCursor(c feedbackCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFeedbackCursor(s string) (*feedbackCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c feedbackCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
 }
 
 type FeedbackRepository interface {
-	GetFeedbacksByBoardID(boardID int) ([]Feedback, error)
+	GetFeedbacks(filter FeedbackFilter) (*FeedbackPage, error)
 	CreateFeedback(feedback *Feedback) error
-	UpdateFeedbackVote(id int, isUpvote bool, increment bool) error
 	GetFeedbackByID(id int) (*Feedback, error)
-	UpdateFeedbackStatus(id int, status string) error
+	GetFeedbackByIDForUpdate(id int) (*Feedback, error)
+	GetFeedbackByIDForShare(id int) (*Feedback, error)
+	UpdateFeedbackStatus(id int, status string, changedBy int) (changed bool, err error)
+	GetStatusHistory(feedbackID int) ([]FeedbackStatusChange, error)
+	UpdateFeedback(feedback *Feedback, editorID int) error
+	GetFeedbackRevisions(feedbackID int) ([]FeedbackRevision, error)
+	DeleteFeedback(id, userID int) error
+	RestoreFeedback(id int) error
+	SetOfficialResponse(feedbackID int, commentID *int) error
+	MergeFeedback(sourceID, targetID int) ([]int, error)
+	GetFeedbackVoterIDs(id int) ([]int, error)
+	RecountFeedbackVotes(id int) error
+	RecountAllFeedbackVotes() (int64, error)
+	RenderMissingDescriptions(limit int) (int, error)
+	WithTx(tx *sql.Tx) FeedbackRepository
 }
 
 type FeedbackRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewFeedbackRepository() FeedbackRepository {
@@ -33,65 +242,157 @@ func NewFeedbackRepository() FeedbackRepository {
 	}
 }
 
-func (r *FeedbackRepositoryImpl) GetFeedbacksByBoardID(boardID int) ([]Feedback, error) {
-	rows, err := r.db.Query(`
-		SELECT id, board_id, title, description, category_id, upvotes, downvotes, COALESCE(status, 'pending')
-		FROM feedback 
-		WHERE board_id = $1
-	`, boardID)
+// GetFeedbacks returns one page of feedback matching the filter
+func (r *FeedbackRepositoryImpl) GetFeedbacks(filter FeedbackFilter) (*FeedbackPage, error) {
+	sortKey, ok := feedbackSortKeys[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	asOf := time.Now().UTC()
+	var cursor *feedbackCursor
+	if filter.Cursor != "" {
+		c, err := decodeFeedbackCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != filter.Sort {
+			return nil, ErrInvalidCursor
+		}
+		cursor = c
+		asOf = c.AsOf
+	}
+
+	args := []interface{}{}
+	conditions := []string{"TRUE"}
+	from := feedbackFrom
+	voteColumn := "NULL::varchar"
+
+	keyExpr := sortKey[0]
+	if filter.Sort == "trending" {
+		args = append(args, asOf)
+		keyExpr = fmt.Sprintf(keyExpr, len(args))
+	}
+
+	if filter.BoardID > 0 {
+		args = append(args, filter.BoardID)
+		conditions = append(conditions, fmt.Sprintf("f.board_id = $%d", len(args)))
+	}
+	if filter.MemberID > 0 {
+		args = append(args, filter.MemberID)
+		conditions = append(conditions, fmt.Sprintf("f.board_id IN (SELECT board_id FROM board_members WHERE user_id = $%d)", len(args)))
+	}
+	if filter.AuthorID > 0 {
+		args = append(args, filter.AuthorID)
+		conditions = append(conditions, fmt.Sprintf("f.user_id = $%d", len(args)))
+	}
+	if filter.VoterID > 0 {
+		args = append(args, filter.VoterID)
+		from += fmt.Sprintf(" JOIN votes v ON v.feedback_id = f.id AND v.user_id = $%d", len(args))
+		voteColumn = "v.vote_type"
+	}
+	if !filter.Merged {
+		conditions = append(conditions, "f.merged_into IS NULL")
+	}
+	if filter.Deleted {
+		conditions = append(conditions, "f.deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "f.deleted_at IS NULL")
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(f.status, 'pending') = $%d", len(args)))
+	}
+	if filter.CategoryID > 0 {
+		args = append(args, filter.CategoryID)
+		conditions = append(conditions, fmt.Sprintf("f.category_id = $%d", len(args)))
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, f.id) < ($%d::%s, $%d)", keyExpr, len(args)-1, sortKey[1], len(args)))
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s, %s, (%s)::text
+		FROM %s
+		WHERE %s
+		ORDER BY %s DESC, f.id DESC
+		LIMIT $%d
+	`, feedbackColumns, voteColumn, keyExpr, from, strings.Join(conditions, " AND "), keyExpr, len(args))
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feedbacks []Feedback
+	page := &FeedbackPage{Feedbacks: []Feedback{}}
+	var lastKey string
 	for rows.Next() {
-		var fb Feedback
-		if err := rows.Scan(&fb.ID, &fb.BoardID, &fb.Title, &fb.Description, &fb.CategoryID, &fb.Upvotes, &fb.Downvotes, &fb.Status); err != nil {
+		var key string
+		var userVote *string
+		fb, err := scanFeedback(rows, &userVote, &key)
+		if err != nil {
 			return nil, err
 		}
-		feedbacks = append(feedbacks, fb)
+		fb.UserVote = userVote
+		if len(page.Feedbacks) == filter.Limit {
+			last := page.Feedbacks[len(page.Feedbacks)-1]
+			page.NextCursor = encodeFeedbackCursor(feedbackCursor{
+				Sort:  filter.Sort,
+				Value: lastKey,
+				ID:    last.ID,
+				AsOf:  asOf,
+			})
+			break
+		}
+		page.Feedbacks = append(page.Feedbacks, *fb)
+		lastKey = key
 	}
 
-	return feedbacks, nil
+	return page, rows.Err()
 }
 
+// CreateFeedback inserts a feedback item along with its first revision
 func (r *FeedbackRepositoryImpl) CreateFeedback(feedback *Feedback) error {
-	_, err := r.db.Exec(`
-		INSERT INTO feedback (board_id, title, description, category_id, upvotes, downvotes, status) 
-		VALUES ($1, $2, $3, $4, 0, 0, 'pending')
-	`, feedback.BoardID, feedback.Title, feedback.Description, feedback.CategoryID)
-	
-	return err
+	feedback.DescriptionHTML = utils.RenderMarkdown(feedback.Description)
+	return r.db.QueryRow(`
+		WITH created AS (
+			INSERT INTO feedback (board_id, title, description, description_html, category_id, upvotes, downvotes, status, user_id) 
+			VALUES ($1, $2, $3, $6, $4, 0, 0, 'pending', $5)
+			RETURNING id, title, description, category_id, user_id, created_at
+		)
+		INSERT INTO feedback_revisions (feedback_id, revision, title, description, category_id, editor_id, created_at)
+		SELECT id, 1, title, description, category_id, user_id, created_at FROM created
+		RETURNING feedback_id, created_at
+	`, feedback.BoardID, feedback.Title, feedback.Description, feedback.CategoryID, feedback.UserID,
+		feedback.DescriptionHTML).Scan(&feedback.ID, &feedback.CreatedAt)
 }
 
-func (r *FeedbackRepositoryImpl) UpdateFeedbackVote(id int, isUpvote bool, increment bool) error {
-	var query string
-	if isUpvote {
-		if increment {
-			query = "UPDATE feedback SET upvotes = upvotes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE feedback SET upvotes = upvotes - 1 WHERE id = $1"
-		}
-	} else {
-		if increment {
-			query = "UPDATE feedback SET downvotes = downvotes + 1 WHERE id = $1"
-		} else {
-			query = "UPDATE feedback SET downvotes = downvotes - 1 WHERE id = $1"
-		}
-	}
+func (r *FeedbackRepositoryImpl) GetFeedbackByID(id int) (*Feedback, error) {
+	return r.getFeedbackByID(id, "")
+}
 
-	_, err := r.db.Exec(query, id)
-	return err
+// GetFeedbackByIDForUpdate reads a feedback item and locks its row until the
+// surrounding transaction ends
+func (r *FeedbackRepositoryImpl) GetFeedbackByIDForUpdate(id int) (*Feedback, error) {
+	return r.getFeedbackByID(id, "FOR UPDATE OF f")
 }
 
-func (r *FeedbackRepositoryImpl) GetFeedbackByID(id int) (*Feedback, error) {
-	var fb Feedback
-	err := r.db.QueryRow(`
-		SELECT id, board_id, title, description, category_id, upvotes, downvotes, COALESCE(status, 'pending') 
-		FROM feedback 
-		WHERE id = $1
-	`, id).Scan(&fb.ID, &fb.BoardID, &fb.Title, &fb.Description, &fb.CategoryID, &fb.Upvotes, &fb.Downvotes, &fb.Status)
+// GetFeedbackByIDForShare reads a feedback item and keeps its row from being
+// changed, or merged, until the surrounding transaction ends
+func (r *FeedbackRepositoryImpl) GetFeedbackByIDForShare(id int) (*Feedback, error) {
+	return r.getFeedbackByID(id, "FOR SHARE OF f")
+}
+
+func (r *FeedbackRepositoryImpl) getFeedbackByID(id int, lock string) (*Feedback, error) {
+	fb, err := scanFeedback(r.db.QueryRow(`
+		SELECT `+feedbackColumns+`
+		FROM `+feedbackFrom+`
+		WHERE f.id = $1 AND f.deleted_at IS NULL
+	`+lock, id))
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -100,15 +401,378 @@ func (r *FeedbackRepositoryImpl) GetFeedbackByID(id int) (*Feedback, error) {
 		return nil, err
 	}
 	
-	return &fb, nil
+	return fb, nil
 }
 
-func (r *FeedbackRepositoryImpl) UpdateFeedbackStatus(id int, status string) error {
-	_, err := r.db.Exec(`
-		UPDATE feedback 
-		SET status = $1
-		WHERE id = $2
-	`, status, id)
-	
+// UpdateFeedbackStatus sets a feedback item's status and, when it changed,
+// records the change by changedBy in the status history. changed reports
+// whether the status was different before; the previous status is read under
+// a row lock, so of two concurrent updates to the same status only one changes it.
+func (r *FeedbackRepositoryImpl) UpdateFeedbackStatus(id int, status string, changedBy int) (changed bool, err error) {
+	err = inTx(r.db, func(tx *sql.Tx) error {
+		var previous string
+		err := tx.QueryRow(`
+			SELECT COALESCE(status, 'pending') FROM feedback WHERE id = $1 FOR UPDATE
+		`, id).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return ErrFeedbackNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if previous == status {
+			return nil
+		}
+
+		if _, err := tx.Exec(`UPDATE feedback SET status = $1 WHERE id = $2`, status, id); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO feedback_status_changes (feedback_id, from_status, to_status, changed_by)
+			VALUES ($1, $2, $3, $4)
+		`, id, previous, status, changedBy)
+		if err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// GetStatusHistory returns a feedback item's status changes, oldest first
+func (r *FeedbackRepositoryImpl) GetStatusHistory(feedbackID int) ([]FeedbackStatusChange, error) {
+	rows, err := r.db.Query(`
+		SELECT sc.id, sc.from_status, sc.to_status, sc.created_at, sc.changed_by, u.name, u.picture
+		FROM feedback_status_changes sc
+		LEFT JOIN users u ON u.id = sc.changed_by
+		WHERE sc.feedback_id = $1
+		ORDER BY sc.id
+	`, feedbackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []FeedbackStatusChange{}
+	for rows.Next() {
+		var change FeedbackStatusChange
+		var changedBy *int
+		var changedByName, changedByPicture sql.NullString
+		if err := rows.Scan(&change.ID, &change.FromStatus, &change.ToStatus, &change.CreatedAt,
+			&changedBy, &changedByName, &changedByPicture); err != nil {
+			return nil, err
+		}
+		if changedBy != nil && changedByName.Valid {
+			change.ChangedBy = &FeedbackAuthor{ID: *changedBy, Name: changedByName.String, Picture: changedByPicture.String}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// UpdateFeedback changes a feedback item's title, description and category
+// and records the new content as a revision by editorID
+func (r *FeedbackRepositoryImpl) UpdateFeedback(feedback *Feedback, editorID int) error {
+	feedback.DescriptionHTML = utils.RenderMarkdown(feedback.Description)
+	return inTx(r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			UPDATE feedback
+			SET title = $1, description = $2, description_html = $3, category_id = $4, updated_at = NOW()
+			WHERE id = $5 AND deleted_at IS NULL
+			RETURNING updated_at
+		`, feedback.Title, feedback.Description, feedback.DescriptionHTML, feedback.CategoryID, feedback.ID).Scan(&feedback.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return ErrFeedbackNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// The update holds the feedback row lock, so revision numbers cannot collide
+		_, err = tx.Exec(`
+			INSERT INTO feedback_revisions (feedback_id, revision, title, description, category_id, editor_id, created_at)
+			SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6
+			FROM feedback_revisions
+			WHERE feedback_id = $1
+		`, feedback.ID, feedback.Title, feedback.Description, feedback.CategoryID, editorID, feedback.UpdatedAt)
+		return err
+	})
+}
+
+// GetFeedbackRevisions returns every revision of a feedback item, oldest first
+func (r *FeedbackRepositoryImpl) GetFeedbackRevisions(feedbackID int) ([]FeedbackRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT fr.id, fr.feedback_id, fr.revision, fr.title, fr.description, fr.category_id, fr.created_at,
+			fr.editor_id, u.name, u.picture
+		FROM feedback_revisions fr
+		LEFT JOIN users u ON u.id = fr.editor_id
+		WHERE fr.feedback_id = $1
+		ORDER BY fr.revision
+	`, feedbackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []FeedbackRevision{}
+	for rows.Next() {
+		var rev FeedbackRevision
+		var editorID *int
+		var editorName, editorPicture sql.NullString
+		if err := rows.Scan(&rev.ID, &rev.FeedbackID, &rev.Revision, &rev.Title, &rev.Description, &rev.CategoryID, &rev.CreatedAt,
+			&editorID, &editorName, &editorPicture); err != nil {
+			return nil, err
+		}
+		if editorID != nil && editorName.Valid {
+			rev.Editor = &FeedbackAuthor{ID: *editorID, Name: editorName.String, Picture: editorPicture.String}
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// DeleteFeedback soft-deletes a feedback item, hiding it everywhere until restored
+func (r *FeedbackRepositoryImpl) DeleteFeedback(id, userID int) error {
+	result, err := r.db.Exec(`
+		UPDATE feedback
+		SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// RestoreFeedback brings back a soft-deleted feedback item
+func (r *FeedbackRepositoryImpl) RestoreFeedback(id int) error {
+	result, err := r.db.Exec(`
+		UPDATE feedback
+		SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// SetOfficialResponse pins one of the feedback item's live comments as its
+// official response, replacing any previous one, or unpins it when commentID is nil.
+// Internal notes cannot be pinned.
+func (r *FeedbackRepositoryImpl) SetOfficialResponse(feedbackID int, commentID *int) error {
+	if commentID == nil {
+		result, err := r.db.Exec(`
+			UPDATE feedback SET official_response_id = NULL WHERE id = $1 AND deleted_at IS NULL
+		`, feedbackID)
+		if err != nil {
+			return err
+		}
+		return expectRow(result)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE feedback f
+		SET official_response_id = c.id
+		FROM comments c
+		WHERE f.id = $1 AND f.deleted_at IS NULL
+			AND c.id = $2 AND c.feedback_id = f.id AND c.deleted_at IS NULL AND NOT c.internal
+	`, feedbackID, *commentID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// expectRow returns ErrFeedbackNotFound when an update matched no feedback
+func expectRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrFeedbackNotFound
+	}
+	return nil
+}
+
+// MergeFeedback merges a duplicate feedback item into a target on the same board.
+// Votes move to the target, keeping the target vote for users who voted on both,
+// comments are re-parented and both vote counters are recomputed. It returns the
+// users who had voted on the duplicate.
+func (r *FeedbackRepositoryImpl) MergeFeedback(sourceID, targetID int) ([]int, error) {
+	var voterIDs []int
+	err := inTx(r.db, func(tx *sql.Tx) error {
+		var err error
+		voterIDs, err = mergeFeedback(tx, sourceID, targetID)
+		return err
+	})
+	return voterIDs, err
+}
+
+func mergeFeedback(tx *sql.Tx, sourceID, targetID int) ([]int, error) {
+	// Lock both rows in a consistent order so concurrent merges cannot deadlock
+	rows, err := tx.Query(`
+		SELECT id, merged_into FROM feedback WHERE id IN ($1, $2) AND deleted_at IS NULL ORDER BY id FOR UPDATE
+	`, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	locked := 0
+	for rows.Next() {
+		var id int
+		var mergedInto *int
+		if err := rows.Scan(&id, &mergedInto); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if mergedInto != nil {
+			rows.Close()
+			return nil, ErrFeedbackMerged
+		}
+		locked++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if locked != 2 {
+		return nil, ErrFeedbackNotFound
+	}
+
+	// Votes are only cast with the feedback row locked, so none can be missed
+	voterIDs, err := feedbackVoterIDs(tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []string{
+		// Users who voted on both keep their vote on the target
+		`DELETE FROM votes s USING votes t
+		 WHERE s.feedback_id = $1 AND t.feedback_id = $2 AND s.user_id = t.user_id`,
+		`UPDATE votes SET feedback_id = $2 WHERE feedback_id = $1`,
+		`UPDATE comments SET feedback_id = $2 WHERE feedback_id = $1`,
+		// Files attached to those comments follow them; the source's own files stay with it
+		`UPDATE attachments SET feedback_id = $2 WHERE feedback_id = $1 AND comment_id IS NOT NULL`,
+		// Anything previously merged into the source now points at the target
+		`UPDATE feedback SET merged_into = $2 WHERE merged_into = $1`,
+		// The source's official response becomes a regular comment on the target
+		`UPDATE feedback SET merged_into = $2, official_response_id = NULL WHERE id = $1`,
+		recountVotesQuery + ` WHERE f.id IN ($1, $2)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, sourceID, targetID); err != nil {
+			return nil, err
+		}
+	}
+
+	return voterIDs, nil
+}
+
+// GetFeedbackVoterIDs returns the users who voted on a feedback item
+func (r *FeedbackRepositoryImpl) GetFeedbackVoterIDs(id int) ([]int, error) {
+	return feedbackVoterIDs(r.db, id)
+}
+
+func feedbackVoterIDs(conn DBTX, feedbackID int) ([]int, error) {
+	rows, err := conn.Query(`SELECT user_id FROM votes WHERE feedback_id = $1 ORDER BY user_id`, feedbackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// recountVotesQuery rebuilds vote counters from the votes table
+const recountVotesQuery = `
+	UPDATE feedback f SET
+		upvotes = (SELECT COUNT(*) FROM votes v WHERE v.feedback_id = f.id AND v.vote_type = 'upvote'),
+		downvotes = (SELECT COUNT(*) FROM votes v WHERE v.feedback_id = f.id AND v.vote_type = 'downvote')`
+
+// RecountFeedbackVotes rebuilds a feedback item's vote counters from the votes table
+func (r *FeedbackRepositoryImpl) RecountFeedbackVotes(id int) error {
+	_, err := r.db.Exec(recountVotesQuery+` WHERE f.id = $1`, id)
 	return err
 }
+
+// RecountAllFeedbackVotes rebuilds every vote counter from the votes table
+// and returns the number of feedback items whose counters were wrong
+func (r *FeedbackRepositoryImpl) RecountAllFeedbackVotes() (int64, error) {
+	result, err := r.db.Exec(recountVotesQuery + `
+		WHERE f.upvotes IS DISTINCT FROM (SELECT COUNT(*) FROM votes v WHERE v.feedback_id = f.id AND v.vote_type = 'upvote')
+		   OR f.downvotes IS DISTINCT FROM (SELECT COUNT(*) FROM votes v WHERE v.feedback_id = f.id AND v.vote_type = 'downvote')`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RenderMissingDescriptions stores the rendered HTML of up to limit
+// descriptions written before it was kept, and returns how many it rendered
+func (r *FeedbackRepositoryImpl) RenderMissingDescriptions(limit int) (int, error) {
+	return renderMissingHTML(r.db, "feedback", "description", "description_html", limit)
+}
+
+// renderMissingHTML renders the Markdown in column src of up to limit rows of
+// table whose HTML column dst is NULL. A row edited in the meantime already
+// has its HTML and is left alone.
+func renderMissingHTML(db DBTX, table, src, dst string, limit int) (int, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT id, %s FROM %s WHERE %s IS NULL ORDER BY id LIMIT $1`, src, table, dst), limit)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id     int
+		source string
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.source); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE id = $2 AND %s = $3 AND %s IS NULL`, table, dst, src, dst)
+	for _, p := range todo {
+		if _, err := db.Exec(update, utils.RenderMarkdown(p.source), p.id, p.source); err != nil {
+			return 0, err
+		}
+	}
+	return len(todo), nil
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *FeedbackRepositoryImpl) WithTx(tx *sql.Tx) FeedbackRepository {
+	return &FeedbackRepositoryImpl{
+		db: tx,
+	}
+}
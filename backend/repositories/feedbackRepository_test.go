@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"canny-clone/internal/testdb"
+	"canny-clone/utils"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Descriptions keep their rendered HTML; rows from before it was stored are
+// rendered on read until the backfill stores it
+func TestStoredDescriptionHTML(t *testing.T) {
+	db := openTestDB(t)
+	authorID := testdb.SeedUser(t, db, "user")
+	boardID, categoryID := testdb.SeedBoard(t, db)
+	fb := &Feedback{
+		BoardID:     boardID,
+		Title:       "Dark mode",
+		Description: "Please add a _dark_ theme",
+		CategoryID:  categoryID,
+		UserID:      &authorID,
+	}
+	repo := NewFeedbackRepository()
+	if err := repo.CreateFeedback(fb); err != nil {
+		t.Fatal(err)
+	}
+	storedDescriptionHTML(t, db, fb.ID, utils.RenderMarkdown(fb.Description))
+
+	fb.Description = "**Dark mode** <script>alert(1)</script>"
+	want := utils.RenderMarkdown(fb.Description)
+	if err := repo.UpdateFeedback(fb, authorID); err != nil {
+		t.Fatal(err)
+	}
+	storedDescriptionHTML(t, db, fb.ID, want)
+
+	if _, err := db.Exec("UPDATE feedback SET description_html = NULL WHERE id = $1", fb.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetFeedbackByID(fb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DescriptionHTML != want {
+		t.Errorf("DescriptionHTML without stored HTML = %q, want %q", got.DescriptionHTML, want)
+	}
+
+	for {
+		n, err := repo.RenderMissingDescriptions(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 100 {
+			break
+		}
+	}
+	storedDescriptionHTML(t, db, fb.ID, want)
+}
+
+func storedDescriptionHTML(t *testing.T, db *sql.DB, feedbackID int, want string) {
+	t.Helper()
+	var stored sql.NullString
+	if err := db.QueryRow("SELECT description_html FROM feedback WHERE id = $1", feedbackID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Valid || stored.String != want {
+		t.Errorf("stored description_html = %q (valid %v), want %q", stored.String, stored.Valid, want)
+	}
+}
+
+// Comment attachments move with their comments when feedback is merged; files
+// attached to the source itself stay with it
+func TestMergeFeedbackMovesCommentAttachments(t *testing.T) {
+	db := openTestDB(t)
+	authorID := testdb.SeedUser(t, db, "user")
+	source := testdb.SeedFeedback(t, db, authorID, "Export to CSV", "Seeded for a test")
+	target := testdb.SeedFeedback(t, db, authorID, "Export data", "Seeded for a test")
+
+	commentID, err := NewCommentRepository().CreateComment(source.ID, 0, authorID, "Screenshot attached", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments := NewAttachmentRepository()
+	attach := func(commentID *int) int {
+		a := &Attachment{
+			FeedbackID:  source.ID,
+			CommentID:   commentID,
+			UserID:      &authorID,
+			StorageKey:  fmt.Sprintf("test/%d/%d", source.ID, time.Now().UnixNano()),
+			Filename:    "screenshot.png",
+			ContentType: "image/png",
+			Size:        1,
+		}
+		if err := attachments.CreateAttachment(a, 10); err != nil {
+			t.Fatal(err)
+		}
+		return a.ID
+	}
+	ownID := attach(nil)
+	commentFileID := attach(&commentID)
+
+	if _, err := NewFeedbackRepository().MergeFeedback(source.ID, target.ID); err != nil {
+		t.Fatal(err)
+	}
+	// An upload that looked the comment up before the merge follows it
+	lateFileID := attach(&commentID)
+
+	attachmentIDs := func(feedbackID int) []int {
+		list, err := attachments.GetAttachmentsByFeedbackID(feedbackID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, a := range list {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+	if got, want := attachmentIDs(source.ID), []int{ownID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("source attachments = %v, want %v", got, want)
+	}
+	if got, want := attachmentIDs(target.ID), []int{commentFileID, lateFileID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("target attachments = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateFeedbackStatusReportsChange(t *testing.T) {
+	db := openTestDB(t)
+	authorID := testdb.SeedUser(t, db, "user")
+	moderatorID := testdb.SeedUser(t, db, "app_admin")
+	feedbackID := testdb.SeedFeedback(t, db, authorID, "Dark mode", "Seeded for a test").ID
+	repo := NewFeedbackRepository()
+
+	for _, step := range []struct {
+		status  string
+		changed bool
+	}{
+		{"pending", false},
+		{"reviewing", true},
+		{"reviewing", false},
+		{"completed", true},
+	} {
+		changed, err := repo.UpdateFeedbackStatus(feedbackID, step.status, moderatorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != step.changed {
+			t.Errorf("UpdateFeedbackStatus(%q) changed = %v, want %v", step.status, changed, step.changed)
+		}
+	}
+
+	history, err := repo.GetStatusHistory(feedbackID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ToStatus != "reviewing" || history[1].FromStatus != "reviewing" {
+		t.Errorf("status history = %+v, want pending to reviewing to completed", history)
+	}
+
+	if _, err := repo.UpdateFeedbackStatus(-1, "reviewing", moderatorID); err != ErrFeedbackNotFound {
+		t.Errorf("UpdateFeedbackStatus() of a missing item error = %v, want ErrFeedbackNotFound", err)
+	}
+}
@@ -0,0 +1,299 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Notification types
+const (
+	NotificationMention      = "mention"
+	NotificationReply        = "reply"         // Someone replied to the user's comment
+	NotificationStatusChange = "status_change" // The user's feedback changed status
+	NotificationMerged       = "merged"        // Feedback the user voted on was merged into another
+	NotificationCompleted    = "completed"     // Feedback the user voted on was completed
+)
+
+// Notification tells a user about something that happened on a board
+type Notification struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	Type       string     `json:"type"`
+	ActorID    *int       `json:"actorId"`
+	FeedbackID *int       `json:"feedbackId,omitempty"`
+	CommentID  *int       `json:"commentId,omitempty"`
+	Status     *string    `json:"status,omitempty"` // The new status, for status changes
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReadAt     *time.Time `json:"readAt,omitempty"`
+
+	// Filled in when listing notifications
+	ActorName     *string `json:"actorName,omitempty"`
+	BoardID       *int    `json:"boardId,omitempty"`
+	FeedbackTitle *string `json:"feedbackTitle,omitempty"`
+	MergedInto    *int    `json:"mergedInto,omitempty"` // Where merged feedback went
+}
+
+// NotificationPage is one page of a user's notifications, newest first
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unreadCount"`
+	NextCursor    string         `json:"nextCursor,omitempty"`
+}
+
+// ErrNotificationNotFound is returned when a notification does not exist or belongs to another user
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// notificationCursorSort tags notification cursors so feedback cursors are not accepted
+const notificationCursorSort = "notifications"
+
+// notificationVisible is true for a notification n, joined to its feedback f,
+// unless the feedback was deleted or is on a board its recipient has left
+const notificationVisible = `(n.feedback_id IS NULL OR (f.deleted_at IS NULL
+	AND f.board_id IN (SELECT board_id FROM board_members WHERE user_id = n.user_id)))`
+
+// visibleNotifications selects the visible notifications of the user whose id
+// is $1 from notifications n joined to their feedback f
+const visibleNotifications = `
+	notifications n
+	LEFT JOIN feedback f ON f.id = n.feedback_id
+	WHERE n.user_id = $1 AND ` + notificationVisible
+
+// PendingEmail is a notification waiting to be emailed, with its recipient
+type PendingEmail struct {
+	Notification
+	Email    string
+	Name     string
+	Delivery string // The recipient's email delivery
+	Skip     bool   // Not to be sent: too old, no longer visible, or email is off
+}
+
+type NotificationRepository interface {
+	CreateNotifications(userIDs []int, n Notification) error
+	GetNotifications(userID int, cursor string, limit int) (*NotificationPage, error)
+	CountUnread(userID int) (int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) (int64, error)
+	ClaimPendingEmails(digestHour int, maxAge, claimTimeout time.Duration, limit int) ([]PendingEmail, error)
+	ReleaseEmails(ids []int) error
+	MarkEmailed(ids []int) error
+	MarkDigestSent(userIDs []int) error
+	WithTx(tx *sql.Tx) NotificationRepository
+}
+
+type NotificationRepositoryImpl struct {
+	db DBTX
+}
+
+func NewNotificationRepository() NotificationRepository {
+	return &NotificationRepositoryImpl{
+		db: GetDB(),
+	}
+}
+
+// CreateNotifications sends the same notification to each of the users
+func (r *NotificationRepositoryImpl) CreateNotifications(userIDs []int, n Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO notifications (user_id, type, actor_id, feedback_id, comment_id, status)
+		SELECT unnest($1::int[]), $2, $3, $4, $5, $6
+	`, pq.Array(userIDs), n.Type, n.ActorID, n.FeedbackID, n.CommentID, n.Status)
+	return err
+}
+
+// GetNotifications returns a page of the user's notifications, newest first,
+// with the number still unread
+func (r *NotificationRepositoryImpl) GetNotifications(userID int, cursor string, limit int) (*NotificationPage, error) {
+	afterID := 0
+	if cursor != "" {
+		c, err := decodeFeedbackCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != notificationCursorSort {
+			return nil, ErrInvalidCursor
+		}
+		afterID = c.ID
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	rows, err := r.db.Query(`
+		SELECT n.id, n.user_id, n.type, n.actor_id, n.feedback_id, n.comment_id, n.status,
+			n.created_at, n.read_at, (SELECT name FROM users WHERE id = n.actor_id), f.board_id, f.title, f.merged_into
+		FROM `+visibleNotifications+`
+		  AND ($2 = 0 OR n.id < $2)
+		ORDER BY n.id DESC
+		LIMIT $3
+	`, userID, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &NotificationPage{Notifications: []Notification{}}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.FeedbackID, &n.CommentID, &n.Status,
+			&n.CreatedAt, &n.ReadAt, &n.ActorName, &n.BoardID, &n.FeedbackTitle, &n.MergedInto)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Notifications) == limit {
+			page.NextCursor = encodeFeedbackCursor(feedbackCursor{
+				Sort: notificationCursorSort,
+				ID:   page.Notifications[len(page.Notifications)-1].ID,
+			})
+			break
+		}
+		page.Notifications = append(page.Notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page.UnreadCount, err = r.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// CountUnread returns how many of the user's visible notifications are unread
+func (r *NotificationRepositoryImpl) CountUnread(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM `+visibleNotifications+` AND n.read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one of the user's notifications as read. Marking a read
+// notification again keeps the time it was first read.
+func (r *NotificationRepositoryImpl) MarkRead(userID, id int) error {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotificationNotFound
+	}
+	return err
+}
+
+// MarkAllRead marks all of the user's unread notifications as read and returns how many there were
+func (r *NotificationRepositoryImpl) MarkAllRead(userID int) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND read_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// digestBoundary is the most recent time a daily digest was due, with the
+// digest hour as $1
+const digestBoundary = `(date_trunc('day', LOCALTIMESTAMP - make_interval(hours => $1)) + make_interval(hours => $1))`
+
+// ClaimPendingEmails claims and returns notifications that are due to be
+// emailed, grouped by recipient: all of those for immediate delivery, and
+// those of users whose daily digest, sent at digestHour in the database's time
+// zone, is due. The claim is committed before it returns, so the emails can be
+// sent without holding locks; other replicas pass claimed notifications over
+// until they are marked emailed, released, or the claim is older than
+// claimTimeout.
+func (r *NotificationRepositoryImpl) ClaimPendingEmails(digestHour int, maxAge, claimTimeout time.Duration, limit int) ([]PendingEmail, error) {
+	// A digest user who has never had one waits for the next digest like
+	// everyone else, rather than being sent everything at once
+	_, err := r.db.Exec(`
+		UPDATE users SET digest_sent_at = `+digestBoundary+`
+		WHERE email_delivery = 'digest' AND digest_sent_at IS NULL
+	`, digestHour)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		WITH due AS (
+			SELECT n.id
+			FROM notifications n
+			JOIN users u ON u.id = n.user_id
+			WHERE n.emailed_at IS NULL
+			  AND (n.email_claimed_at IS NULL OR n.email_claimed_at < LOCALTIMESTAMP - make_interval(secs => $4))
+			  AND (u.email_delivery <> 'digest' OR u.digest_sent_at < `+digestBoundary+`)
+			ORDER BY n.user_id, n.id
+			LIMIT $3
+			FOR UPDATE OF n SKIP LOCKED
+		), claimed AS (
+			UPDATE notifications n SET email_claimed_at = LOCALTIMESTAMP
+			FROM due WHERE n.id = due.id
+			RETURNING n.*
+		)
+		SELECT n.id, n.user_id, n.type, n.actor_id, n.feedback_id, n.comment_id, n.status, n.created_at,
+			(SELECT name FROM users WHERE id = n.actor_id), f.board_id, f.title, f.merged_into,
+			u.email, u.name, u.email_delivery,
+			u.email_delivery = 'off' OR n.created_at < LOCALTIMESTAMP - make_interval(secs => $2) OR NOT `+notificationVisible+`
+		FROM claimed n
+		JOIN users u ON u.id = n.user_id
+		LEFT JOIN feedback f ON f.id = n.feedback_id
+		ORDER BY n.user_id, n.id
+	`, digestHour, maxAge.Seconds(), limit, claimTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := []PendingEmail{}
+	for rows.Next() {
+		var p PendingEmail
+		n := &p.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.FeedbackID, &n.CommentID, &n.Status, &n.CreatedAt,
+			&n.ActorName, &n.BoardID, &n.FeedbackTitle, &n.MergedInto, &p.Email, &p.Name, &p.Delivery, &p.Skip)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// ReleaseEmails gives up the claim on notifications that could not be emailed
+// yet, so the next run tries them again
+func (r *NotificationRepositoryImpl) ReleaseEmails(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`UPDATE notifications SET email_claimed_at = NULL WHERE id = ANY($1) AND emailed_at IS NULL`, pq.Array(ids))
+	return err
+}
+
+// MarkEmailed records that notifications were emailed or passed over
+func (r *NotificationRepositoryImpl) MarkEmailed(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`UPDATE notifications SET emailed_at = LOCALTIMESTAMP WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// MarkDigestSent records that the users were sent their daily digest
+func (r *NotificationRepositoryImpl) MarkDigestSent(userIDs []int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`UPDATE users SET digest_sent_at = LOCALTIMESTAMP WHERE id = ANY($1)`, pq.Array(userIDs))
+	return err
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *NotificationRepositoryImpl) WithTx(tx *sql.Tx) NotificationRepository {
+	return &NotificationRepositoryImpl{
+		db: tx,
+	}
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+// SearchResult is a feedback item matching a search query
+type SearchResult struct {
+	FeedbackID     int     `json:"feedbackId"`
+	BoardID        int     `json:"boardId"`
+	Title          string  `json:"title"`
+	Status         string  `json:"status"`
+	Upvotes        int     `json:"upvotes"`
+	Downvotes      int     `json:"downvotes"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"titleHighlight"`
+	Snippet        string  `json:"snippet"`
+	CommentSnippet string  `json:"commentSnippet,omitempty"`
+}
+
+type SearchRepository interface {
+	SearchFeedback(boardID int, query string, limit int, includeInternal bool) ([]SearchResult, error)
+}
+
+type SearchRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewSearchRepository() SearchRepository {
+	return &SearchRepositoryImpl{
+		db: GetDB(),
+	}
+}
+
+// SearchFeedback ranks a board's feedback by how well its title, description
+// and comments match the query. Matched terms are wrapped in <mark> tags. Internal
+// notes are only searched when includeInternal is set.
+func (r *SearchRepositoryImpl) SearchFeedback(boardID int, query string, limit int, includeInternal bool) ([]SearchResult, error) {
+	rows, err := r.db.Query(`
+		WITH q AS (
+			SELECT websearch_to_tsquery('english', $2) AS query
+		),
+		comment_matches AS (
+			SELECT DISTINCT ON (c.feedback_id) c.feedback_id, c.content, ts_rank(c.search_vector, q.query) AS rank
+			FROM comments c
+			JOIN feedback f ON f.id = c.feedback_id
+			CROSS JOIN q
+			WHERE f.board_id = $1 AND f.deleted_at IS NULL AND c.deleted_at IS NULL AND c.search_vector @@ q.query
+				AND ($4 OR NOT c.internal)
+			ORDER BY c.feedback_id, rank DESC
+		)
+		SELECT f.id, f.board_id, f.title, COALESCE(f.status, 'pending'), f.upvotes, f.downvotes,
+			ts_rank(f.search_vector, q.query) + COALESCE(cm.rank, 0) * 0.5 AS rank,
+			ts_headline('english', f.title, q.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('english', f.description, q.query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2'),
+			COALESCE(ts_headline('english', cm.content, q.query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=1'), '')
+		FROM feedback f
+		CROSS JOIN q
+		LEFT JOIN comment_matches cm ON cm.feedback_id = f.id
+		WHERE f.board_id = $1 AND f.deleted_at IS NULL AND (f.search_vector @@ q.query OR cm.feedback_id IS NOT NULL)
+		ORDER BY rank DESC, f.id DESC
+		LIMIT $3
+	`, boardID, query, limit, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		if err := rows.Scan(&res.FeedbackID, &res.BoardID, &res.Title, &res.Status, &res.Upvotes, &res.Downvotes,
+			&res.Rank, &res.TitleHighlight, &res.Snippet, &res.CommentSnippet); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
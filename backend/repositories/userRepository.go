@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 )
 
@@ -15,6 +16,16 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// How users receive notifications by email
+const (
+	EmailDeliveryImmediate = "immediate" // One email per notification
+	EmailDeliveryDigest    = "digest"    // One email a day listing them
+	EmailDeliveryOff       = "off"
+)
+
+// ErrUserNotFound is returned when a user does not exist
+var ErrUserNotFound = errors.New("user not found")
+
 type UserRepository interface {
 	FindUserByEmail(email string) (*User, error)
 	CreateUser(user *User) error
@@ -24,10 +35,13 @@ type UserRepository interface {
 	AddUserToBoard(userID, boardID int, role string) error
 	RemoveUserFromBoard(userID, boardID int) error
 	GetBoardMembers(boardID int) ([]*User, error)
+	GetEmailDelivery(userID int) (string, error)
+	SetEmailDelivery(userID int, delivery string) error
+	WithTx(tx *sql.Tx) UserRepository
 }
 
 type UserRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewUserRepository() UserRepository {
@@ -162,3 +176,48 @@ func (r *UserRepositoryImpl) GetBoardMembers(boardID int) ([]*User, error) {
 
 	return users, rows.Err()
 }
+
+// GetEmailDelivery returns how the user receives notifications by email
+func (r *UserRepositoryImpl) GetEmailDelivery(userID int) (string, error) {
+	var delivery string
+	err := r.db.QueryRow(`SELECT email_delivery FROM users WHERE id = $1`, userID).Scan(&delivery)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return delivery, err
+}
+
+// SetEmailDelivery changes how the user receives notifications by email.
+// Switching to digests starts the day's wait from now, and turning email off
+// drops the notifications still waiting to be sent.
+func (r *UserRepositoryImpl) SetEmailDelivery(userID int, delivery string) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE users SET email_delivery = $1,
+				digest_sent_at = CASE WHEN $1 = 'digest' AND email_delivery <> 'digest' THEN LOCALTIMESTAMP ELSE digest_sent_at END
+			WHERE id = $2
+		`, delivery, userID)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if delivery != EmailDeliveryOff {
+			return nil
+		}
+		_, err = tx.Exec(`
+			UPDATE notifications SET emailed_at = LOCALTIMESTAMP WHERE user_id = $1 AND emailed_at IS NULL
+		`, userID)
+		return err
+	})
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *UserRepositoryImpl) WithTx(tx *sql.Tx) UserRepository {
+	return &UserRepositoryImpl{
+		db: tx,
+	}
+}
@@ -16,10 +16,11 @@ type VoteRepository interface {
 	CreateVote(vote *Vote) error
 	UpdateVote(id int, voteType string) error
 	DeleteVote(id int) error
+	WithTx(tx *sql.Tx) VoteRepository
 }
 
 type VoteRepositoryImpl struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewVoteRepository() VoteRepository {
@@ -65,3 +66,10 @@ func (r *VoteRepositoryImpl) DeleteVote(id int) error {
 	_, err := r.db.Exec("DELETE FROM votes WHERE id = $1", id)
 	return err
 }
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *VoteRepositoryImpl) WithTx(tx *sql.Tx) VoteRepository {
+	return &VoteRepositoryImpl{
+		db: tx,
+	}
+}
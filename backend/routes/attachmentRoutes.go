@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"canny-clone/authz"
+	"canny-clone/services"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterAttachmentRoutes(r *mux.Router) {
+	// Authentication required for all attachment routes
+	attachmentRouter := r.PathPrefix("/").Subrouter()
+	attachmentRouter.Use(services.AuthMiddleware)
+
+	// Uploads; the handlers check that the caller may edit the feedback item or comment
+	attachmentRouter.Handle("/feedback/{id}/attachments", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.UploadFeedbackAttachment)).Methods("POST")
+	attachmentRouter.Handle("/comments/{id}/attachments", authz.Protect(authz.CreateComment, authz.CommentVar("id"), services.UploadCommentAttachment)).Methods("POST")
+	attachmentRouter.Handle("/feedback/{id}/attachments", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.GetFeedbackAttachments)).Methods("GET")
+
+	// Downloads need the same board membership as viewing the board
+	attachmentRouter.Handle("/attachments/{id}", authz.Protect(authz.ViewBoard, authz.AttachmentVar("id"), services.DownloadAttachment)).Methods("GET")
+	attachmentRouter.Handle("/attachments/{id}", authz.Protect(authz.ViewBoard, authz.AttachmentVar("id"), services.DeleteAttachment)).Methods("DELETE")
+}
@@ -5,7 +5,8 @@ import (
 	"net/http"
 	"strconv"
 
-	"canny-clone/middlewares"
+	"canny-clone/auth"
+	"canny-clone/authz"
 	"canny-clone/services"
 
 	"github.com/gorilla/mux"
@@ -20,14 +21,19 @@ func RegisterAuthRoutes(r *mux.Router) {
 	authRouter.Use(services.AuthMiddleware)
 	
 	// Profile endpoint
-	authRouter.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+	authRouter.Handle("/profile", authz.Protect(authz.Self, authz.NoBoard, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		userID, _ := strconv.Atoi(r.Header.Get("User-ID"))
+		principal, ok := auth.FromRequest(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID := principal.UserID
 		
 		userRepo := services.GetUserRepository()
 		user, err := userRepo.GetUserByID(userID)
 		
-		if err != nil {
+		if err != nil || user == nil {
 			http.Error(w, "Failed to fetch user profile", http.StatusInternalServerError)
 			return
 		}
@@ -59,14 +65,14 @@ func RegisterAuthRoutes(r *mux.Router) {
 		}
 		
 		json.NewEncoder(w).Encode(profile)
-	}).Methods("GET")
+	})).Methods("GET")
 	
 	// Admin routes
 	adminRouter := r.PathPrefix("/admin").Subrouter()
-	adminRouter.Use(middlewares.RoleRequired("app_admin"))
+	adminRouter.Use(services.AuthMiddleware)
 	
 	// Update user role - admin only
-	adminRouter.HandleFunc("/users/{id}/role", func(w http.ResponseWriter, r *http.Request) {
+	adminRouter.Handle("/users/{id}/role", authz.Protect(authz.ManageUsers, authz.NoBoard, func(w http.ResponseWriter, r *http.Request) {
 		var roleRequest struct {
 			Role string `json:"role"`
 		}
@@ -99,5 +105,5 @@ func RegisterAuthRoutes(r *mux.Router) {
 		
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "User role updated successfully"})
-	}).Methods("PUT")
+	})).Methods("PUT")
 }
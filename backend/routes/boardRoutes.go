@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
-	"canny-clone/middlewares"
+	"canny-clone/authz"
 	"canny-clone/services"
 
 	"github.com/gorilla/mux"
@@ -17,76 +17,26 @@ func RegisterBoardRoutes(r *mux.Router) {
 	boardRouter.Use(services.AuthMiddleware)
 	
 	// Get boards the user has access to
-	boardRouter.HandleFunc("/boards", services.GetUserBoards).Methods("GET")
+	boardRouter.Handle("/boards", authz.Protect(authz.Self, authz.NoBoard, services.GetUserBoards)).Methods("GET")
 	
 	// Get single board if user has access
-	boardRouter.HandleFunc("/boards/{id}", services.GetBoard).Methods("GET")
+	boardRouter.Handle("/boards/{id}", authz.Protect(authz.ViewBoard, authz.BoardVar("id"), services.GetBoard)).Methods("GET")
 	
-	// Admin and stakeholder routes
-	adminBoardRouter := r.PathPrefix("/").Subrouter()
-	adminBoardRouter.Use(middlewares.RoleRequired("app_admin", "stakeholder"))
+	// Stream of the board's changes as Server-Sent Events
+	boardRouter.Handle("/boards/{id}/events", authz.Protect(authz.ViewBoard, authz.BoardVar("id"), services.BoardEvents)).Methods("GET")
 	
 	// Create board - only app_admin can create new boards
-	adminBoardRouter.HandleFunc("/boards", func(w http.ResponseWriter, r *http.Request) {
-		userID, role, err := middlewares.GetUserFromRequest(r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		
-		// Only app_admin can create boards
-		if role != "app_admin" {
-			http.Error(w, "Forbidden: Only administrators can create boards", http.StatusForbidden)
-			return
-		}
-		
-		services.CreateBoard(w, r)
-	}).Methods("POST")
+	boardRouter.Handle("/boards", authz.Protect(authz.CreateBoard, authz.NoBoard, services.CreateBoard)).Methods("POST")
 	
 	// Update board - only app_admin and board stakeholders can update
-	adminBoardRouter.HandleFunc("/boards/{id}", func(w http.ResponseWriter, r *http.Request) {
-		userID, role, err := middlewares.GetUserFromRequest(r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		
-		vars := mux.Vars(r)
-		boardID, err := strconv.Atoi(vars["id"])
-		if err != nil {
-			http.Error(w, "Invalid board ID", http.StatusBadRequest)
-			return
-		}
-		
-		// App admin can update any board
-		if role == "app_admin" {
-			services.UpdateBoard(w, r)
-			return
-		}
-		
-		// Check if user is a stakeholder for this board
-		userRepo := services.GetUserRepository()
-		boardRoles, err := userRepo.GetUserBoardRoles(userID)
-		if err != nil {
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
-		
-		boardRole, exists := boardRoles[boardID]
-		if !exists || boardRole != "stakeholder" {
-			http.Error(w, "Forbidden: Insufficient permissions for this board", http.StatusForbidden)
-			return
-		}
-		
-		services.UpdateBoard(w, r)
-	}).Methods("PUT")
+	boardRouter.Handle("/boards/{id}", authz.Protect(authz.UpdateBoard, authz.BoardVar("id"), services.UpdateBoard)).Methods("PUT")
 	
-	// Board member management - Admin and stakeholders only
+	// Board member management - Admin and board stakeholders only
 	boardMemberRouter := r.PathPrefix("/boards/{id}/members").Subrouter()
-	boardMemberRouter.Use(middlewares.RoleRequired("app_admin", "stakeholder"))
+	boardMemberRouter.Use(services.AuthMiddleware)
 	
 	// Add member to board
-	boardMemberRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+	boardMemberRouter.Handle("", authz.Protect(authz.ManageBoardMembers, authz.BoardVar("id"), func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		boardID, err := strconv.Atoi(vars["id"])
 		if err != nil {
@@ -126,10 +76,10 @@ func RegisterBoardRoutes(r *mux.Router) {
 		
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "User added to board successfully"})
-	}).Methods("POST")
+	})).Methods("POST")
 	
 	// Remove member from board
-	boardMemberRouter.HandleFunc("/{userID}", func(w http.ResponseWriter, r *http.Request) {
+	boardMemberRouter.Handle("/{userID}", authz.Protect(authz.ManageBoardMembers, authz.BoardVar("id"), func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		boardID, err := strconv.Atoi(vars["id"])
 		if err != nil {
@@ -152,10 +102,10 @@ func RegisterBoardRoutes(r *mux.Router) {
 		
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "User removed from board successfully"})
-	}).Methods("DELETE")
+	})).Methods("DELETE")
 	
 	// List board members
-	boardMemberRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+	boardMemberRouter.Handle("", authz.Protect(authz.ViewBoardMembers, authz.BoardVar("id"), func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		boardID, err := strconv.Atoi(vars["id"])
 		if err != nil {
@@ -172,5 +122,5 @@ func RegisterBoardRoutes(r *mux.Router) {
 		
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(members)
-	}).Methods("GET")
+	})).Methods("GET")
 }
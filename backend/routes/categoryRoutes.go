@@ -2,10 +2,20 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
+	"canny-clone/authz"
 	"canny-clone/services"
-	"net/http"
 )
 
 func RegisterCategoryRoutes(r *mux.Router) {
-	r.HandleFunc("/categories", services.GetCategories).Methods("GET")
+	// Authentication required for all category routes
+	categoryRouter := r.PathPrefix("/boards/{id}/categories").Subrouter()
+	categoryRouter.Use(services.AuthMiddleware)
+
+	categoryRouter.Handle("", authz.Protect(authz.ViewCategories, authz.BoardVar("id"), services.GetCategories)).Methods("GET")
+
+	// Category management - Admin and board stakeholders only
+	categoryRouter.Handle("", authz.Protect(authz.ManageCategories, authz.BoardVar("id"), services.CreateCategory)).Methods("POST")
+	categoryRouter.Handle("/order", authz.Protect(authz.ManageCategories, authz.BoardVar("id"), services.ReorderCategories)).Methods("PUT")
+	categoryRouter.Handle("/{categoryId:[0-9]+}", authz.Protect(authz.ManageCategories, authz.BoardVar("id"), services.UpdateCategory)).Methods("PUT")
+	categoryRouter.Handle("/{categoryId:[0-9]+}", authz.Protect(authz.ManageCategories, authz.BoardVar("id"), services.DeleteCategory)).Methods("DELETE")
 }
@@ -2,13 +2,21 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
+	"canny-clone/authz"
 	"canny-clone/services"
-	"net/http"
 )
 
 func RegisterCommentRoutes(r *mux.Router) {
-	r.HandleFunc("/comments", services.GetComments).Methods("GET")
-	r.HandleFunc("/comment", services.AddComment).Methods("POST")
-	r.HandleFunc("/reply", services.AddReply).Methods("POST")
-	r.HandleFunc("/comment-like", services.LikeComment).Methods("POST")
+	// Authentication required for all comment routes
+	commentRouter := r.PathPrefix("/").Subrouter()
+	commentRouter.Use(services.AuthMiddleware)
+
+	commentRouter.Handle("/comments", authz.Protect(authz.ViewComments, authz.FeedbackQuery("feedbackId"), services.GetComments)).Methods("GET")
+	commentRouter.Handle("/comment", authz.Protect(authz.CreateComment, authz.FeedbackBody("feedbackId"), services.AddComment)).Methods("POST")
+	commentRouter.Handle("/comment-like", authz.Protect(authz.ReactToComment, authz.CommentBody("commentId"), services.LikeComment)).Methods("POST")
+
+	// Authors edit within the edit window; authors and stakeholders can delete
+	commentRouter.Handle("/comments/{id}", authz.Protect(authz.CreateComment, authz.CommentVar("id"), services.UpdateComment)).Methods("PUT")
+	commentRouter.Handle("/comments/{id}", authz.Protect(authz.ViewComments, authz.CommentVar("id"), services.DeleteComment)).Methods("DELETE")
+	commentRouter.Handle("/comments/{id}/history", authz.Protect(authz.ViewComments, authz.CommentVar("id"), services.GetCommentHistory)).Methods("GET")
 }
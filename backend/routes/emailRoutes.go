@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"canny-clone/services"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterEmailRoutes registers the unsubscribe link sent in emails. It is
+// authorized by the signed token in the link rather than a session.
+func RegisterEmailRoutes(r *mux.Router) {
+	r.HandleFunc("/unsubscribe", services.UnsubscribePage).Methods("GET")
+	r.HandleFunc("/unsubscribe", services.Unsubscribe).Methods("POST")
+}
@@ -2,8 +2,11 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
+	"canny-clone/auth"
+	"canny-clone/authz"
 	"canny-clone/services"
-	"canny-clone/middlewares"
+	"canny-clone/repositories"
+	"database/sql"
 	"net/http"
 	"encoding/json"
 	"strconv"
@@ -14,17 +17,32 @@ func RegisterFeedbackRoutes(r *mux.Router) {
 	feedbackRouter := r.PathPrefix("/").Subrouter()
 	feedbackRouter.Use(services.AuthMiddleware)
 	
-	feedbackRouter.HandleFunc("/feedbacks", services.GetFeedbacks).Methods("GET")
-	feedbackRouter.HandleFunc("/feedback", services.AddFeedback).Methods("POST")
-	feedbackRouter.HandleFunc("/vote", services.VoteFeedback).Methods("POST")
+	feedbackRouter.Handle("/feedbacks", authz.Protect(authz.ViewFeedback, authz.BoardQuery("boardId"), services.GetFeedbacks)).Methods("GET")
+	feedbackRouter.Handle("/feedback", authz.Protect(authz.CreateFeedback, authz.BoardBody("boardId"), services.AddFeedback)).Methods("POST")
+	feedbackRouter.Handle("/feedback/{id}", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.GetFeedback)).Methods("GET")
+	feedbackRouter.Handle("/feedback/{id}", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.UpdateFeedback)).Methods("PUT")
+	feedbackRouter.Handle("/feedback/{id}", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.DeleteFeedback)).Methods("DELETE")
+	feedbackRouter.Handle("/feedback/{id}/revisions", authz.Protect(authz.ViewFeedback, authz.FeedbackVar("id"), services.GetFeedbackRevisions)).Methods("GET")
+	feedbackRouter.Handle("/feedback/{id}/official-response", authz.Protect(authz.PinComment, authz.FeedbackVar("id"), services.PinOfficialResponse)).Methods("PUT")
+	feedbackRouter.Handle("/feedback/{id}/official-response", authz.Protect(authz.PinComment, authz.FeedbackVar("id"), services.UnpinOfficialResponse)).Methods("DELETE")
+	feedbackRouter.Handle("/vote", authz.Protect(authz.VoteFeedback, authz.FeedbackBody("feedbackId"), services.VoteFeedback)).Methods("POST")
+	
+	// Admin only routes
+	adminRouter := r.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(services.AuthMiddleware)
+	
+	// Rebuild vote counters from the votes table
+	adminRouter.Handle("/recount-votes", authz.Protect(authz.RecountVotes, authz.NoBoard, services.RecountVotes)).Methods("POST")
+	
+	// Restore soft-deleted feedback
+	adminRouter.Handle("/feedback/{id}/restore", authz.Protect(authz.RestoreFeedback, authz.NoBoard, services.RestoreFeedback)).Methods("POST")
 	
 	// Stakeholder/admin only routes
 	stakeholderRouter := r.PathPrefix("/feedbacks").Subrouter()
 	stakeholderRouter.Use(services.AuthMiddleware)
-	stakeholderRouter.Use(middlewares.RoleRequired("app_admin", "stakeholder"))
 	
 	// Update feedback status
-	stakeholderRouter.HandleFunc("/{id}/status", func(w http.ResponseWriter, r *http.Request) {
+	stakeholderRouter.Handle("/{id}/status", authz.Protect(authz.ModerateFeedback, authz.FeedbackVar("id"), func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		feedbackID, err := strconv.Atoi(vars["id"])
 		if err != nil {
@@ -33,7 +51,7 @@ func RegisterFeedbackRoutes(r *mux.Router) {
 		}
 		
 		var statusUpdate struct {
-			Status string `json:"status"` // "pending", "reviewing", "approved", "declined"
+			Status string `json:"status"` // One of repositories.FeedbackStatuses
 		}
 		
 		if err := json.NewDecoder(r.Body).Decode(&statusUpdate); err != nil {
@@ -41,21 +59,11 @@ func RegisterFeedbackRoutes(r *mux.Router) {
 			return
 		}
 		
-		// Validate status
-		validStatuses := map[string]bool{
-			"pending":   true,
-			"reviewing": true,
-			"approved":  true,
-			"declined":  true,
-		}
-		
-		if !validStatuses[statusUpdate.Status] {
+		if !repositories.IsFeedbackStatus(statusUpdate.Status) {
 			http.Error(w, "Invalid status value", http.StatusBadRequest)
 			return
 		}
 		
-		// Check if user has permission for this feedback's board
-		userID, role, _ := middlewares.GetUserFromRequest(r)
 		feedbackRepo := services.GetFeedbackRepository()
 		feedback, err := feedbackRepo.GetFeedbackByID(feedbackID)
 		
@@ -64,34 +72,116 @@ func RegisterFeedbackRoutes(r *mux.Router) {
 			return
 		}
 		
-		// App admin can update any feedback
-		if role != "app_admin" {
-			// Check if user is stakeholder for this board
-			userRepo := services.GetUserRepository()
-			boardRoles, err := userRepo.GetUserBoardRoles(userID)
-			
-			if err != nil {
-				http.Error(w, "Server error", http.StatusInternalServerError)
-				return
-			}
-			
-			boardRole, exists := boardRoles[feedback.BoardID]
-			if !exists || boardRole != "stakeholder" {
-				http.Error(w, "Forbidden: Insufficient permissions for this feedback", http.StatusForbidden)
-				return
-			}
+		if feedback.MergedInto != nil {
+			http.Error(w, "Feedback has been merged", http.StatusConflict)
+			return
+		}
+		
+		principal, ok := auth.FromRequest(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 		
-		// Update feedback status
-		if err := feedbackRepo.UpdateFeedbackStatus(feedbackID, statusUpdate.Status); err != nil {
+		// Update feedback status and notify the people following it together.
+		// Followers hear only of an actual change, as decided under the row
+		// lock, not by comparing with the status read above.
+		var changed bool
+		err = repositories.RunInTx(func(tx *sql.Tx) error {
+			var err error
+			changed, err = feedbackRepo.WithTx(tx).UpdateFeedbackStatus(feedbackID, statusUpdate.Status, principal.UserID)
+			if err != nil || !changed {
+				return err
+			}
+			return services.NotifyStatusChange(tx, feedback, principal.UserID, statusUpdate.Status)
+		})
+		if err != nil {
 			http.Error(w, "Failed to update feedback status", http.StatusInternalServerError)
 			return
 		}
 		
+		if changed {
+			services.PublishBoardEvent(feedback.BoardID, services.EventFeedbackStatus, map[string]interface{}{
+				"feedbackId": feedbackID,
+				"status":     statusUpdate.Status,
+			})
+		}
+		
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{
 			"id":     strconv.Itoa(feedbackID),
 			"status": statusUpdate.Status,
 		})
-	}).Methods("PUT")
+	})).Methods("PUT")
+	
+	// Merge a duplicate feedback into another
+	stakeholderRouter.Handle("/{id}/merge", authz.Protect(authz.ModerateFeedback, authz.FeedbackVar("id"), func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		feedbackID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+			return
+		}
+		
+		var mergeRequest struct {
+			TargetID int `json:"targetId"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&mergeRequest); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		if mergeRequest.TargetID == feedbackID {
+			http.Error(w, "Cannot merge feedback into itself", http.StatusBadRequest)
+			return
+		}
+		
+		feedbackRepo := services.GetFeedbackRepository()
+		feedback, err := feedbackRepo.GetFeedbackByID(feedbackID)
+		if err != nil || feedback == nil {
+			http.Error(w, "Feedback not found", http.StatusNotFound)
+			return
+		}
+		
+		target, err := feedbackRepo.GetFeedbackByID(mergeRequest.TargetID)
+		if err != nil || target == nil {
+			http.Error(w, "Target feedback not found", http.StatusNotFound)
+			return
+		}
+		
+		if target.BoardID != feedback.BoardID {
+			http.Error(w, "Feedback must be on the same board", http.StatusBadRequest)
+			return
+		}
+		
+		principal, ok := auth.FromRequest(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		
+		// Voters of the duplicate hear where their votes went
+		err = repositories.RunInTx(func(tx *sql.Tx) error {
+			voterIDs, err := feedbackRepo.WithTx(tx).MergeFeedback(feedbackID, mergeRequest.TargetID)
+			if err != nil {
+				return err
+			}
+			return services.NotifyMerged(tx, voterIDs, principal.UserID, feedbackID)
+		})
+		if err != nil {
+			if err == repositories.ErrFeedbackMerged {
+				http.Error(w, "Feedback has already been merged", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to merge feedback", http.StatusInternalServerError)
+			return
+		}
+		
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{
+			"id":         feedbackID,
+			"mergedInto": mergeRequest.TargetID,
+		})
+	})).Methods("POST")
 }
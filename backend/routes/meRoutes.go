@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"canny-clone/authz"
+	"canny-clone/services"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterMeRoutes registers the current user's own feedback, votes, notifications
+// and email preferences. Handlers only act on the principal's own data.
+func RegisterMeRoutes(r *mux.Router) {
+	meRouter := r.PathPrefix("/me").Subrouter()
+	meRouter.Use(services.AuthMiddleware)
+
+	meRouter.Handle("/feedback", authz.Protect(authz.Self, authz.NoBoard, services.GetMyFeedback)).Methods("GET")
+	meRouter.Handle("/votes", authz.Protect(authz.Self, authz.NoBoard, services.GetMyVotes)).Methods("GET")
+	meRouter.Handle("/notifications", authz.Protect(authz.Self, authz.NoBoard, services.GetMyNotifications)).Methods("GET")
+	meRouter.Handle("/notifications/read-all", authz.Protect(authz.Self, authz.NoBoard, services.MarkAllNotificationsRead)).Methods("POST")
+	meRouter.Handle("/notifications/{id}/read", authz.Protect(authz.Self, authz.NoBoard, services.MarkNotificationRead)).Methods("POST")
+	meRouter.Handle("/email-preferences", authz.Protect(authz.Self, authz.NoBoard, services.GetEmailPreferences)).Methods("GET")
+	meRouter.Handle("/email-preferences", authz.Protect(authz.Self, authz.NoBoard, services.UpdateEmailPreferences)).Methods("PUT")
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"canny-clone/authz"
+	"canny-clone/services"
+)
+
+func RegisterSearchRoutes(r *mux.Router) {
+	searchRouter := r.PathPrefix("/").Subrouter()
+	searchRouter.Use(services.AuthMiddleware)
+
+	searchRouter.Handle("/search", authz.Protect(authz.ViewFeedback, authz.BoardQuery("boardId"), services.SearchFeedback)).Methods("GET")
+}
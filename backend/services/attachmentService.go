@@ -0,0 +1,361 @@
+package services
+
+import (
+	"canny-clone/auth"
+	"canny-clone/authz"
+	"canny-clone/repositories"
+	"canny-clone/storage"
+	"canny-clone/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// multipartMemory is how much of an upload is kept in memory; the rest goes to a temporary file
+const multipartMemory = 1 << 20
+
+var attachmentStorage storage.Storage
+
+// InitStorage sets up the attachment store chosen in the configuration
+func InitStorage() {
+	config := utils.GetConfig()
+
+	var err error
+	switch config.AttachmentStorage {
+	case "", "local":
+		dir := config.AttachmentDir
+		if dir == "" {
+			dir = "uploads"
+		}
+		attachmentStorage, err = storage.NewLocalStorage(dir)
+	case "s3":
+		attachmentStorage, err = storage.NewS3Storage(storage.S3Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			PathStyle: config.S3PathStyle,
+		})
+	default:
+		err = fmt.Errorf("unknown attachment storage %q", config.AttachmentStorage)
+	}
+	if err != nil {
+		log.Fatalf("Failed to set up attachment storage: %v", err)
+	}
+}
+
+// UploadFeedbackAttachment attaches a file to a feedback item. The same people
+// who can edit the item can attach files to it.
+func UploadFeedbackAttachment(w http.ResponseWriter, r *http.Request) {
+	principal, feedback, ok := feedbackForChange(w, r)
+	if !ok {
+		return
+	}
+
+	if feedback.MergedInto != nil {
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	}
+
+	storeAttachment(w, r, &repositories.Attachment{
+		FeedbackID: feedback.ID,
+		UserID:     &principal.UserID,
+	})
+}
+
+// UploadCommentAttachment lets authors attach a file to their comment within the edit window
+func UploadCommentAttachment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := routeCommentID(w, r)
+	if !ok {
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	info, ok := liveCommentInfo(w, repositories.NewCommentRepository(), principal, commentID)
+	if !ok {
+		return
+	}
+
+	if info.UserID != principal.UserID {
+		http.Error(w, "Forbidden: Only the author can attach files to a comment", http.StatusForbidden)
+		return
+	}
+	if window := commentEditWindow(); window > 0 && info.Age > window {
+		http.Error(w, "Forbidden: The edit window for this comment has passed", http.StatusForbidden)
+		return
+	}
+
+	storeAttachment(w, r, &repositories.Attachment{
+		FeedbackID: info.FeedbackID,
+		CommentID:  &commentID,
+		UserID:     &principal.UserID,
+	})
+}
+
+// storeAttachment reads the "file" field of a multipart upload, checks it against
+// the attachment limits, stores it and records it as attachment a
+func storeAttachment(w http.ResponseWriter, r *http.Request, a *repositories.Attachment) {
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxAttachmentSize+multipartMemory)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid upload; files cannot exceed %d MB", utils.MaxAttachmentSize>>20), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Trust the file's content rather than the type the client claims
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+
+	filename := attachmentFilename(header.Filename)
+	if err := utils.ValidateAttachment(filename, contentType, header.Size); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := newAttachmentKey(a.FeedbackID)
+	if err != nil {
+		http.Error(w, "Error storing file", http.StatusInternalServerError)
+		return
+	}
+	if err := attachmentStorage.Put(key, file, header.Size, contentType); err != nil {
+		log.Printf("Failed to store attachment %s: %v", key, err)
+		http.Error(w, "Error storing file", http.StatusInternalServerError)
+		return
+	}
+
+	a.StorageKey = key
+	a.Filename = filename
+	a.ContentType = contentType
+	a.Size = header.Size
+	if err := repositories.NewAttachmentRepository().CreateAttachment(a, utils.MaxAttachments); err != nil {
+		if err := attachmentStorage.Delete(key); err != nil {
+			log.Printf("Failed to remove unused attachment %s: %v", key, err)
+		}
+		switch err {
+		case repositories.ErrTooManyAttachments:
+			http.Error(w, fmt.Sprintf("Cannot attach more than %d files", utils.MaxAttachments), http.StatusBadRequest)
+		case repositories.ErrFeedbackNotFound:
+			http.Error(w, "Feedback not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Error saving attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+// GetFeedbackAttachments lists the files attached to a feedback item and its
+// comments. Files on internal notes are only listed for stakeholders and admins.
+func GetFeedbackAttachments(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	feedback, err := repositories.NewFeedbackRepository().GetFeedbackByID(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching feedback", http.StatusInternalServerError)
+		return
+	}
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+
+	includeInternal, err := canSeeInternalNotes(principal, feedback.BoardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	attachments, err := repositories.NewAttachmentRepository().GetAttachmentsByFeedbackID(feedbackID, includeInternal)
+	if err != nil {
+		http.Error(w, "Error fetching attachments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// DownloadAttachment sends an attachment's content. Images are shown inline and
+// other files are downloaded; neither may run scripts.
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	a, ok := visibleAttachment(w, r, principal)
+	if !ok {
+		return
+	}
+
+	content, err := attachmentStorage.Open(a.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to open attachment %s: %v", a.StorageKey, err)
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(a.ContentType, "image/") {
+		disposition = "inline"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}); header != "" {
+		disposition = header
+	}
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	io.Copy(w, content)
+}
+
+// DeleteAttachment lets uploaders remove their files and board stakeholders remove any
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	a, ok := visibleAttachment(w, r, principal)
+	if !ok {
+		return
+	}
+
+	if a.UserID == nil || *a.UserID != principal.UserID {
+		if err := authz.Authorize(principal, authz.ModerateFeedback, a.BoardID); err != nil {
+			if err == authz.ErrForbidden {
+				http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := repositories.NewAttachmentRepository().DeleteAttachment(a.ID); err != nil {
+		if err == repositories.ErrAttachmentNotFound {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting attachment", http.StatusInternalServerError)
+		return
+	}
+	if err := attachmentStorage.Delete(a.StorageKey); err != nil {
+		log.Printf("Failed to remove attachment %s: %v", a.StorageKey, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attachment deleted successfully"})
+}
+
+// visibleAttachment fetches the attachment in the "id" route variable, writing
+// a 404 if it does not exist or is on an internal note the principal cannot see
+func visibleAttachment(w http.ResponseWriter, r *http.Request, principal *auth.Principal) (*repositories.Attachment, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	a, err := repositories.NewAttachmentRepository().GetAttachment(id)
+	if err == repositories.ErrAttachmentNotFound {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Error fetching attachment", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if a.Internal {
+		visible, err := canSeeInternalNotes(principal, a.BoardID)
+		if err != nil {
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !visible {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return nil, false
+		}
+	}
+	return a, true
+}
+
+// newAttachmentKey returns a fresh storage key for a file attached to a feedback item
+func newAttachmentKey(feedbackID int) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("feedback/%d/%s", feedbackID, hex.EncodeToString(buf)), nil
+}
+
+// attachmentFilename reduces an uploaded file's name to its base name without
+// control characters, at most 255 bytes long
+func attachmentFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -1,11 +1,14 @@
 package services
 
 import (
+	"canny-clone/auth"
 	"canny-clone/repositories"
 	"canny-clone/utils"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -148,19 +151,6 @@ func HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 func generateJWT(user *repositories.User) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
 	
-	// Get user's board roles if needed for enhanced token
-	userRepo := repositories.NewUserRepository()
-	boardRoles, err := userRepo.GetUserBoardRoles(user.ID)
-	if err != nil {
-		return "", err
-	}
-	
-	// Convert board roles to JSON string
-	boardRolesJSON, err := json.Marshal(boardRoles)
-	if err != nil {
-		return "", err
-	}
-	
 	claims := &TokenClaims{
 		UserID: user.ID,
 		Email:  user.Email,
@@ -180,34 +170,64 @@ func generateJWT(user *repositories.User) (string, error) {
 	return tokenString, nil
 }
 
-// AuthMiddleware checks if the request has a valid JWT token
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// parseToken validates a bearer token and returns its claims
+func parseToken(tokenString string) (*TokenClaims, error) {
+	// Remove "Bearer " prefix if present
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+
+	claims := &TokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// AuthMiddleware checks if the request has a valid JWT token and stores the
+// authenticated principal in the request context for downstream handlers
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := r.Header.Get("Authorization")
 		if tokenString == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Remove "Bearer " prefix if present
-		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-			tokenString = tokenString[7:]
-		}
-
-		claims := &TokenClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := parseToken(tokenString)
+		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Add user ID to request context or headers for downstream handlers
-		r.Header.Set("User-ID", string(claims.UserID))
-		next(w, r)
+		principal := &auth.Principal{
+			UserID: claims.UserID,
+			Email:  claims.Email,
+			Name:   claims.Name,
+			Role:   claims.Role,
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// requirePrincipal returns the authenticated user of the request, writing a
+// 401 response if there is none
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	principal, ok := auth.FromRequest(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
 	}
+	return principal, true
 }
 
 // GetUserRepository returns an instance of the user repository
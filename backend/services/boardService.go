@@ -3,6 +3,7 @@ package services
 import (
 	"canny-clone/repositories"
 	"canny-clone/utils"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -12,31 +13,23 @@ import (
 
 // GetUserBoards returns boards the user has access to based on their role
 func GetUserBoards(w http.ResponseWriter, r *http.Request) {
-	// Extract user ID from request headers (set by auth middleware)
-	userIDStr := r.Header.Get("User-ID")
-	userRole := r.Header.Get("User-Role")
-	
-	if userIDStr == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	// Get the authenticated user (set by auth middleware)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
 	
 	boardRepo := repositories.NewBoardRepository()
 	
 	var boards []repositories.Board
+	var err error
 	
 	// Admin sees all boards
-	if userRole == "app_admin" {
+	if principal.IsAdmin() {
 		boards, err = boardRepo.GetAllBoards()
 	} else {
 		// Other users only see boards they have access to
-		boards, err = boardRepo.GetUserBoards(userID)
+		boards, err = boardRepo.GetUserBoards(principal.UserID)
 	}
 	
 	if err != nil {
@@ -48,7 +41,7 @@ func GetUserBoards(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(boards)
 }
 
-// GetBoard returns a specific board if the user has access to it
+// GetBoard returns a specific board (access checked by the route policy)
 func GetBoard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	boardID, err := strconv.Atoi(vars["id"])
@@ -57,38 +50,8 @@ func GetBoard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	userIDStr := r.Header.Get("User-ID")
-	userRole := r.Header.Get("User-Role")
-	
-	if userIDStr == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-	
 	boardRepo := repositories.NewBoardRepository()
 	
-	// Check if user has access to this board
-	if userRole != "app_admin" {
-		userRepo := GetUserRepository()
-		boardRoles, err := userRepo.GetUserBoardRoles(userID)
-		if err != nil {
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
-		
-		_, hasAccess := boardRoles[boardID]
-		if !hasAccess {
-			http.Error(w, "Forbidden: No access to this board", http.StatusForbidden)
-			return
-		}
-	}
-	
 	board, err := boardRepo.GetBoardByID(boardID)
 	if err != nil {
 		http.Error(w, "Error fetching board", http.StatusInternalServerError)
@@ -119,24 +82,31 @@ func CreateBoard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Get user ID (role check already done by middleware)
-	userIDStr := r.Header.Get("User-ID")
-	userID, _ := strconv.Atoi(userIDStr)
+	// Get user (role check already done by middleware)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
 
-	boardRepo := repositories.NewBoardRepository()
-	boardID, err := boardRepo.CreateBoard(body.Name)
-	
+	// Create the board with its stakeholder and default categories in one
+	// transaction, so a failure part way leaves no half-made board behind
+	var boardID int
+	err := repositories.RunInTx(func(tx *sql.Tx) error {
+		var err error
+		boardID, err = repositories.NewBoardRepository().WithTx(tx).CreateBoard(body.Name)
+		if err != nil {
+			return err
+		}
+		// Make the admin user a stakeholder of the new board
+		if err := GetUserRepository().WithTx(tx).AddUserToBoard(principal.UserID, boardID, "stakeholder"); err != nil {
+			return err
+		}
+		return repositories.NewCategoryRepository().WithTx(tx).CreateDefaultCategories(boardID)
+	})
 	if err != nil {
 		http.Error(w, "Error creating board", http.StatusInternalServerError)
 		return
 	}
-	
-	// Make the admin user a stakeholder of the new board
-	userRepo := GetUserRepository()
-	if err := userRepo.AddUserToBoard(userID, boardID, "stakeholder"); err != nil {
-		http.Error(w, "Error assigning user to board", http.StatusInternalServerError)
-		return
-	}
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]int{"id": boardID})
@@ -152,7 +122,8 @@ func UpdateBoard(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	var body struct {
-		Name string `json:"name"`
+		Name          string `json:"name"`
+		MentionPolicy string `json:"mentionPolicy"` // optional: "plain" or "reject"
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -165,8 +136,13 @@ func UpdateBoard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if body.MentionPolicy != "" && body.MentionPolicy != repositories.MentionPolicyPlain && body.MentionPolicy != repositories.MentionPolicyReject {
+		http.Error(w, "Invalid mention policy", http.StatusBadRequest)
+		return
+	}
+	
 	boardRepo := repositories.NewBoardRepository()
-	if err := boardRepo.UpdateBoard(boardID, body.Name); err != nil {
+	if err := boardRepo.UpdateBoard(boardID, body.Name, body.MentionPolicy); err != nil {
 		http.Error(w, "Error updating board", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"canny-clone/auth"
+	"canny-clone/repositories"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createBoard(p *auth.Principal, name string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/boards", strings.NewReader(fmt.Sprintf(`{"name":%q}`, name)))
+	return serveAs(p, CreateBoard, r)
+}
+
+func TestCreateBoard(t *testing.T) {
+	db := openTestDB(t)
+	admin := seedPrincipal(t, db, "app_admin")
+	name := fmt.Sprintf("Roadmap %d", time.Now().UnixNano())
+
+	if w := createBoard(admin, name); w.Code != http.StatusCreated {
+		t.Fatalf("CreateBoard() status = %d: %s", w.Code, w.Body)
+	}
+
+	var boardID, categories int
+	var role string
+	err := db.QueryRow(`
+		SELECT b.id, (SELECT COUNT(*) FROM categories WHERE board_id = b.id), m.role
+		FROM boards b JOIN board_members m ON m.board_id = b.id AND m.user_id = $2
+		WHERE b.name = $1
+	`, name, admin.UserID).Scan(&boardID, &categories, &role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if categories != len(repositories.DefaultCategories) || role != "stakeholder" {
+		t.Errorf("board has %d categories and its creator is a %q, want %d and stakeholder",
+			categories, role, len(repositories.DefaultCategories))
+	}
+}
+
+// A board whose creator cannot be made a member is not left behind
+func TestCreateBoardRollsBack(t *testing.T) {
+	db := openTestDB(t)
+	admin := seedPrincipal(t, db, "app_admin")
+	admin.UserID = -1
+	name := fmt.Sprintf("Roadmap %d", time.Now().UnixNano())
+
+	if w := createBoard(admin, name); w.Code != http.StatusInternalServerError {
+		t.Fatalf("CreateBoard() for a missing user status = %d, want 500", w.Code)
+	}
+	var id int
+	if err := db.QueryRow(`SELECT id FROM boards WHERE name = $1`, name).Scan(&id); err != sql.ErrNoRows {
+		t.Errorf("board %d was left behind (error %v)", id, err)
+	}
+}
@@ -2,13 +2,55 @@ package services
 
 import (
 	"canny-clone/repositories"
+	"canny-clone/utils"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
 )
 
+const defaultCategoryColor = "#6b7280"
+
+// categoryRequest is the body of a category create or update
+type categoryRequest struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// decodeCategoryRequest reads and validates a category body
+func decodeCategoryRequest(w http.ResponseWriter, r *http.Request) (*categoryRequest, bool) {
+	var body categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	body.Description = strings.TrimSpace(body.Description)
+	if body.Color == "" {
+		body.Color = defaultCategoryColor
+	}
+
+	if err := utils.ValidateCategory(body.Name, body.Color, body.Description); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &body, true
+}
+
+// GetCategories returns a board's categories in display order
 func GetCategories(w http.ResponseWriter, r *http.Request) {
+	boardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+
 	repo := repositories.NewCategoryRepository()
-	categories, err := repo.GetAllCategories()
+	categories, err := repo.GetCategoriesByBoardID(boardID)
 	if err != nil {
 		http.Error(w, "Error fetching categories", http.StatusInternalServerError)
 		return
@@ -17,3 +59,159 @@ func GetCategories(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(categories)
 }
+
+// CreateCategory adds a category to a board
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	boardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := decodeCategoryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	category := &repositories.Category{
+		BoardID:     boardID,
+		Name:        body.Name,
+		Color:       body.Color,
+		Description: body.Description,
+	}
+
+	repo := repositories.NewCategoryRepository()
+	if err := repo.CreateCategory(category); err != nil {
+		http.Error(w, "Error creating category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory renames a category or changes its colour or description
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	boardID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+	categoryID, err := strconv.Atoi(vars["categoryId"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := decodeCategoryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	category := &repositories.Category{
+		ID:          categoryID,
+		BoardID:     boardID,
+		Name:        body.Name,
+		Color:       body.Color,
+		Description: body.Description,
+	}
+
+	repo := repositories.NewCategoryRepository()
+	if err := repo.UpdateCategory(category); err != nil {
+		if err == repositories.ErrCategoryNotFound {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating category", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Category updated successfully"})
+}
+
+// ReorderCategories sets the display order of a board's categories
+func ReorderCategories(w http.ResponseWriter, r *http.Request) {
+	boardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		CategoryIDs []int `json:"categoryIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[int]bool)
+	for _, id := range body.CategoryIDs {
+		if seen[id] {
+			http.Error(w, "Category order cannot repeat a category", http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+	}
+
+	repo := repositories.NewCategoryRepository()
+	if err := repo.ReorderCategories(boardID, body.CategoryIDs); err != nil {
+		if err == repositories.ErrCategoryNotFound {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Categories reordered successfully"})
+}
+
+// DeleteCategory deletes a category, moving its feedback to the fallback
+// category given in the fallbackCategoryId query parameter
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	boardID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+	categoryID, err := strconv.Atoi(vars["categoryId"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+	fallbackID, err := strconv.Atoi(r.URL.Query().Get("fallbackCategoryId"))
+	if err != nil || fallbackID == categoryID {
+		http.Error(w, "A different fallback category is required", http.StatusBadRequest)
+		return
+	}
+
+	repo := repositories.NewCategoryRepository()
+	category, err := repo.GetCategoryByID(categoryID)
+	if err != nil {
+		http.Error(w, "Error fetching category", http.StatusInternalServerError)
+		return
+	}
+	if category == nil || category.BoardID != boardID {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	moved, err := repo.DeleteCategory(categoryID, fallbackID)
+	if err != nil {
+		if err == repositories.ErrCategoryNotFound {
+			http.Error(w, "Fallback category not found on this board", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error deleting category", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"reassigned": moved})
+}
@@ -1,79 +1,112 @@
 package services
 
 import (
+	"canny-clone/auth"
+	"canny-clone/authz"
 	"canny-clone/repositories"
 	"canny-clone/utils"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
-// Comment represents a comment on feedback
-type Comment struct {
-	ID        int    `json:"id"`
-	FeedbackID int   `json:"feedbackId"`
-	UserID    int    `json:"userId"`
-	Content   string `json:"content"`
-	Likes     int    `json:"likes"`
-	Dislikes  int    `json:"dislikes"`
-	CreatedAt string `json:"createdAt"`
-	IsLiked   bool   `json:"isLiked,omitempty"`  // Whether current user liked this
-	IsDisliked bool  `json:"isDisliked,omitempty"` // Whether current user disliked this
-	Replies   []Reply `json:"replies,omitempty"`
-}
+const (
+	defaultReactionReconcileInterval = 10 * time.Minute
+	defaultCommentEditWindow         = 15 * time.Minute
+	defaultCommentPageSize           = 20
+	maxCommentPageSize               = 100
+)
 
-// Reply represents a reply to a comment
-type Reply struct {
-	ID        int    `json:"id"`
-	CommentID int    `json:"commentId"`
-	UserID    int    `json:"userId"`
-	Content   string `json:"content"`
-	Likes     int    `json:"likes"`
-	Dislikes  int    `json:"dislikes"`
-	CreatedAt string `json:"createdAt"`
-	IsLiked   bool   `json:"isLiked,omitempty"`  
-	IsDisliked bool  `json:"isDisliked,omitempty"`
+// canSeeInternalNotes reports whether the principal may read internal notes on a board
+func canSeeInternalNotes(principal *auth.Principal, boardID int) (bool, error) {
+	err := authz.Authorize(principal, authz.ViewInternalNotes, boardID)
+	if err == authz.ErrForbidden {
+		return false, nil
+	}
+	return err == nil, err
 }
 
-// GetComments retrieves all comments for a feedback item
+// GetComments retrieves one page of a feedback item's comment threads. Internal
+// notes are left out unless the caller is a stakeholder of the board or an admin.
 func GetComments(w http.ResponseWriter, r *http.Request) {
-	feedbackIDStr := r.URL.Query().Get("feedbackId")
-	feedbackID, err := strconv.Atoi(feedbackIDStr)
+	query := r.URL.Query()
+	feedbackID, err := strconv.Atoi(query.Get("feedbackId"))
 	if err != nil {
 		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
 		return
 	}
-	
-	userID := getUserIDFromRequest(r)
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	feedback, err := GetFeedbackRepository().GetFeedbackByID(feedbackID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	includeInternal, err := canSeeInternalNotes(principal, feedback.BoardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	filter := repositories.CommentFilter{
+		FeedbackID:      feedbackID,
+		ViewerID:        principal.UserID,
+		IncludeInternal: includeInternal,
+		Sort:            query.Get("sort"),
+		Cursor:          query.Get("cursor"),
+		Limit:           defaultCommentPageSize,
+	}
+	if filter.Sort == "" {
+		filter.Sort = "new"
+	}
+	if filter.Sort != "new" && filter.Sort != "old" && filter.Sort != "top" {
+		http.Error(w, "Invalid sort value", http.StatusBadRequest)
+		return
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		filter.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxCommentPageSize {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
 
 	repo := repositories.NewCommentRepository()
-	comments, err := repo.GetCommentsByFeedbackID(feedbackID, userID)
+	page, err := repo.GetCommentsByFeedbackID(filter)
+	if err == repositories.ErrInvalidCursor {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error fetching comments", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	json.NewEncoder(w).Encode(page)
 }
 
-// Helper to get user ID from request (in a real app, this would come from auth middleware)
-func getUserIDFromRequest(r *http.Request) int {
-	// This is a placeholder - in a real app, you would get this from your auth system
-	// For now, we'll use a dummy user ID for testing
-	userIDStr := r.Header.Get("User-ID")
-	if userIDStr == "" {
-		return 1 // Default test user
-	}
-	userID, _ := strconv.Atoi(userIDStr)
-	return userID
-}
-
-// AddComment adds a new comment to a feedback
+// AddComment adds a comment to a feedback, or a reply when parentId is set
 func AddComment(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		FeedbackID int    `json:"feedbackId"`
+		ParentID   int    `json:"parentId"`
 		Content    string `json:"content"`
+		Internal   bool   `json:"internal"` // stakeholder-only note
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -86,177 +119,460 @@ func AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := getUserIDFromRequest(r)
+	// Merged feedback is read-only
+	feedback, err := GetFeedbackRepository().GetFeedbackByID(body.FeedbackID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	if feedback.MergedInto != nil {
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if body.Internal {
+		if err := authz.Authorize(principal, authz.CreateInternalNote, feedback.BoardID); err != nil {
+			if err == authz.ErrForbidden {
+				http.Error(w, "Forbidden: Only stakeholders can add internal notes", http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return
+		}
+	}
+	canSeeInternal, err := canSeeInternalNotes(principal, feedback.BoardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
 
 	repo := repositories.NewCommentRepository()
-	commentID, err := repo.CreateComment(body.FeedbackID, userID, body.Content)
+
+	// Replies to an internal note become internal, so others must not reach one
+	internal := body.Internal
+	var parent *repositories.CommentInfo
+	if body.ParentID != 0 {
+		parent, err = repo.GetCommentInfo(body.ParentID)
+		if err != nil && err != repositories.ErrCommentNotFound {
+			http.Error(w, "Error fetching comment", http.StatusInternalServerError)
+			return
+		}
+		if err == repositories.ErrCommentNotFound || (parent.Internal && !canSeeInternal) {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		}
+		internal = internal || parent.Internal
+	}
+
+	mentions, ok := resolveMentions(w, feedback.BoardID, body.Content)
+	if !ok {
+		return
+	}
+
+	var commentID int
+	err = repositories.RunInTx(func(tx *sql.Tx) error {
+		// Hold the feedback row so it cannot be merged while the comment is
+		// added, and check it was not merged since it was read above
+		locked, err := GetFeedbackRepository().WithTx(tx).GetFeedbackByIDForShare(body.FeedbackID)
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			return errFeedbackNotFound
+		}
+		if locked.MergedInto != nil {
+			return repositories.ErrFeedbackMerged
+		}
+
+		txRepo := repo.WithTx(tx)
+		commentID, err = txRepo.CreateComment(body.FeedbackID, body.ParentID, principal.UserID, body.Content, internal)
+		if err != nil {
+			return err
+		}
+		mentioned, err := txRepo.SetCommentMentions(commentID, mentions)
+		if err != nil {
+			return err
+		}
+		if err := notifyMentions(tx, mentioned, principal.UserID, feedback.BoardID, feedback.ID, commentID, internal); err != nil {
+			return err
+		}
+		if parent == nil || parent.Deleted {
+			return nil
+		}
+		return notifyReply(tx, parent.UserID, mentioned, principal.UserID, feedback.BoardID, feedback.ID, commentID, internal)
+	})
+	if err == errFeedbackNotFound {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	if err == repositories.ErrFeedbackMerged {
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	}
+	if err == repositories.ErrCommentNotFound {
+		http.Error(w, "Parent comment not found", http.StatusNotFound)
+		return
+	}
+	if err == repositories.ErrCommentTooDeep {
+		http.Error(w, fmt.Sprintf("Replies cannot be nested more than %d levels deep", repositories.MaxCommentDepth), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error adding comment", http.StatusInternalServerError)
 		return
 	}
 
+	publishBoardEvent(feedback.BoardID, EventCommentCreated, map[string]int{
+		"feedbackId": feedback.ID,
+		"commentId":  commentID,
+		"parentId":   body.ParentID,
+	}, internal)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]int{"id": commentID})
 }
 
-// AddReply adds a reply to a comment
-func AddReply(w http.ResponseWriter, r *http.Request) {
+// LikeComment handles liking/disliking a comment
+func LikeComment(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		CommentID int    `json:"commentId"`
-		Content   string `json:"content"`
+		CommentID int  `json:"commentId"`
+		IsLike    bool `json:"isLike"` // true for like, false for dislike
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate reply
-	if err := utils.ValidateComment(body.Content); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	repo := repositories.NewCommentRepository()
+	info, ok := liveCommentInfo(w, repo, principal, body.CommentID)
+	if !ok {
+		return
+	}
+
+	// Transaction handled at repository level
+	if err := repo.ToggleReaction(body.CommentID, principal.UserID, body.IsLike); err != nil {
+		if err == repositories.ErrCommentNotFound {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating reaction", http.StatusInternalServerError)
 		return
 	}
 
-	userID := getUserIDFromRequest(r)
+	if likes, dislikes, err := repo.GetCommentReactionCounts(body.CommentID); err == nil {
+		publishBoardEvent(info.BoardID, EventCommentReacted, map[string]int{
+			"feedbackId": info.FeedbackID,
+			"commentId":  body.CommentID,
+			"likes":      likes,
+			"dislikes":   dislikes,
+		}, info.Internal)
+	}
 
-	repo := repositories.NewCommentRepository()
-	replyID, err := repo.CreateReply(body.CommentID, userID, body.Content)
+	w.WriteHeader(http.StatusOK)
+}
+
+// resolveMentions resolves the @mentions in content against the board's members.
+// Mentions of anyone else stay plain text, unless the board's mention policy
+// rejects them, in which case a 400 response is written.
+func resolveMentions(w http.ResponseWriter, boardID int, content string) ([]repositories.CommentMention, bool) {
+	board, err := repositories.NewBoardRepository().GetBoardByID(boardID)
 	if err != nil {
-		http.Error(w, "Error adding reply", http.StatusInternalServerError)
-		return
+		http.Error(w, "Error fetching board", http.StatusInternalServerError)
+		return nil, false
+	}
+	members, err := GetUserRepository().GetBoardMembers(boardID)
+	if err != nil {
+		http.Error(w, "Error fetching board members", http.StatusInternalServerError)
+		return nil, false
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]int{"id": replyID})
+	candidates := make([]utils.MentionCandidate, len(members))
+	names := make(map[int]string, len(members))
+	for i, m := range members {
+		candidates[i] = utils.MentionCandidate{UserID: m.ID, Name: m.Name, Email: m.Email}
+		names[m.ID] = m.Name
+	}
+
+	resolved, unresolved := utils.FindMentions(content, candidates)
+	if len(unresolved) > 0 && board.MentionPolicy == repositories.MentionPolicyReject {
+		http.Error(w, fmt.Sprintf("@%s is not a member of this board", unresolved[0]), http.StatusBadRequest)
+		return nil, false
+	}
+
+	mentions := make([]repositories.CommentMention, len(resolved))
+	for i, m := range resolved {
+		mentions[i] = repositories.CommentMention{UserID: m.UserID, Name: names[m.UserID], Text: m.Text}
+	}
+	return mentions, true
 }
 
-// LikeComment handles liking/disliking a comment or reply
-func LikeComment(w http.ResponseWriter, r *http.Request) {
+// notifyMentions notifies newly mentioned users about a comment. Authors are not
+// notified of their own mentions, and internal notes only notify users who can read them.
+func notifyMentions(tx *sql.Tx, userIDs []int, authorID, boardID, feedbackID, commentID int, internal bool) error {
+	recipients, err := notificationRecipients(userIDs, authorID, boardID, internal)
+	if err != nil {
+		return err
+	}
+	return repositories.NewNotificationRepository().WithTx(tx).CreateNotifications(recipients, repositories.Notification{
+		Type:       repositories.NotificationMention,
+		ActorID:    &authorID,
+		FeedbackID: &feedbackID,
+		CommentID:  &commentID,
+	})
+}
+
+// notifyReply tells a comment's author about a reply to it, unless they wrote
+// the reply or were already notified of it as a mention
+func notifyReply(tx *sql.Tx, parentAuthorID int, mentioned []int, authorID, boardID, feedbackID, commentID int, internal bool) error {
+	for _, userID := range mentioned {
+		if userID == parentAuthorID {
+			return nil
+		}
+	}
+	recipients, err := notificationRecipients([]int{parentAuthorID}, authorID, boardID, internal)
+	if err != nil {
+		return err
+	}
+	return repositories.NewNotificationRepository().WithTx(tx).CreateNotifications(recipients, repositories.Notification{
+		Type:       repositories.NotificationReply,
+		ActorID:    &authorID,
+		FeedbackID: &feedbackID,
+		CommentID:  &commentID,
+	})
+}
+
+// commentEditWindow returns how long authors may edit their comments; 0 means no limit
+func commentEditWindow() time.Duration {
+	window := utils.GetConfig().CommentEditWindow
+	if window == "" {
+		return defaultCommentEditWindow
+	}
+	parsed, err := time.ParseDuration(window)
+	if err != nil || parsed < 0 {
+		return defaultCommentEditWindow
+	}
+	return parsed
+}
+
+// routeCommentID reads the comment ID from the "id" route variable
+func routeCommentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// liveCommentInfo fetches a comment that has not been deleted and that the
+// principal can see, writing an error response if there is none
+func liveCommentInfo(w http.ResponseWriter, repo repositories.CommentRepository, principal *auth.Principal, commentID int) (*repositories.CommentInfo, bool) {
+	info, err := repo.GetCommentInfo(commentID)
+	if err == repositories.ErrCommentNotFound || (err == nil && info.Deleted) {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Error fetching comment", http.StatusInternalServerError)
+		return nil, false
+	}
+	if info.Internal {
+		visible, err := canSeeInternalNotes(principal, info.BoardID)
+		if err != nil {
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !visible {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return nil, false
+		}
+	}
+	return info, true
+}
+
+// UpdateComment lets authors change their comment within the edit window
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := routeCommentID(w, r)
+	if !ok {
+		return
+	}
+
 	var body struct {
-		CommentID *int `json:"commentId"`
-		ReplyID   *int `json:"replyId"`
-		IsLike    bool `json:"isLike"` // true for like, false for dislike
+		Content string `json:"content"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if body.CommentID == nil && body.ReplyID == nil {
-		http.Error(w, "Either commentId or replyId must be provided", http.StatusBadRequest)
+	if err := utils.ValidateComment(body.Content); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
 
-	userID := getUserIDFromRequest(r)
 	repo := repositories.NewCommentRepository()
-	
-	// Transaction handled at repository level
-	if body.CommentID != nil {
-		// Handle comment like/dislike
-		commentID := *body.CommentID
-		likeInfo, err := repo.GetCommentLikeInfo(commentID, userID)
+	info, ok := liveCommentInfo(w, repo, principal, commentID)
+	if !ok {
+		return
+	}
+
+	if info.UserID != principal.UserID {
+		http.Error(w, "Forbidden: Only the author can edit a comment", http.StatusForbidden)
+		return
+	}
+	if window := commentEditWindow(); window > 0 && info.Age > window {
+		http.Error(w, "Forbidden: The edit window for this comment has passed", http.StatusForbidden)
+		return
+	}
+
+	mentions, ok := resolveMentions(w, info.BoardID, body.Content)
+	if !ok {
+		return
+	}
+
+	err := repositories.RunInTx(func(tx *sql.Tx) error {
+		txRepo := repo.WithTx(tx)
+		if err := txRepo.UpdateComment(commentID, principal.UserID, body.Content); err != nil {
+			return err
+		}
+		mentioned, err := txRepo.SetCommentMentions(commentID, mentions)
 		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			return err
+		}
+		return notifyMentions(tx, mentioned, principal.UserID, info.BoardID, info.FeedbackID, commentID, info.Internal)
+	})
+	if err != nil {
+		if err == repositories.ErrCommentNotFound {
+			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
-		
-		if likeInfo == nil {
-			// New reaction
-			if err := repo.UpsertCommentLike(commentID, userID, body.IsLike); err != nil {
-				http.Error(w, "Error adding reaction", http.StatusInternalServerError)
-				return
-			}
-			
-			if err := repo.UpdateCommentLikeCount(commentID, body.IsLike, true); err != nil {
-				http.Error(w, "Error updating counter", http.StatusInternalServerError)
+		http.Error(w, "Error updating comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment updated successfully"})
+}
+
+// DeleteComment lets authors delete their own comments and board stakeholders
+// delete any. A deleted comment with replies is left as a tombstone.
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := routeCommentID(w, r)
+	if !ok {
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	repo := repositories.NewCommentRepository()
+	info, ok := liveCommentInfo(w, repo, principal, commentID)
+	if !ok {
+		return
+	}
+
+	if info.UserID != principal.UserID {
+		if err := authz.Authorize(principal, authz.ModerateComments, info.BoardID); err != nil {
+			if err == authz.ErrForbidden {
+				http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
 				return
 			}
-		} else {
-			// Existing reaction
-			if likeInfo.IsLike == body.IsLike {
-				// Same reaction - remove it (toggle off)
-				if err := repo.DeleteCommentLike(likeInfo.ID); err != nil {
-					http.Error(w, "Error removing reaction", http.StatusInternalServerError)
-					return
-				}
-				
-				if err := repo.UpdateCommentLikeCount(commentID, body.IsLike, false); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-			} else {
-				// Different reaction - update and adjust counters
-				if err := repo.UpsertCommentLike(commentID, userID, body.IsLike); err != nil {
-					http.Error(w, "Error updating reaction", http.StatusInternalServerError)
-					return
-				}
-				
-				// Decrement old counter
-				if err := repo.UpdateCommentLikeCount(commentID, !body.IsLike, false); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-				
-				// Increment new counter
-				if err := repo.UpdateCommentLikeCount(commentID, body.IsLike, true); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-			}
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return
 		}
-	} else {
-		// Handle reply like/dislike similarly
-		replyID := *body.ReplyID
-		likeInfo, err := repo.GetReplyLikeInfo(replyID, userID)
-		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+	}
+
+	if err := repo.DeleteComment(commentID, principal.UserID); err != nil {
+		if err == repositories.ErrCommentNotFound {
+			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
-		
-		if likeInfo == nil {
-			// New reaction
-			if err := repo.UpsertReplyLike(replyID, userID, body.IsLike); err != nil {
-				http.Error(w, "Error adding reaction", http.StatusInternalServerError)
-				return
-			}
-			
-			if err := repo.UpdateReplyLikeCount(replyID, body.IsLike, true); err != nil {
-				http.Error(w, "Error updating counter", http.StatusInternalServerError)
-				return
-			}
+		http.Error(w, "Error deleting comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted successfully"})
+}
+
+// GetCommentHistory returns the earlier versions of a comment
+func GetCommentHistory(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := routeCommentID(w, r)
+	if !ok {
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	repo := repositories.NewCommentRepository()
+	if _, ok := liveCommentInfo(w, repo, principal, commentID); !ok {
+		return
+	}
+
+	edits, err := repo.GetCommentEdits(commentID)
+	if err != nil {
+		http.Error(w, "Error fetching comment history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
+
+// StartReactionReconciler periodically rebuilds comment and reply reaction
+// counters from comment_likes. An interval of "off" disables it.
+func StartReactionReconciler(interval string) {
+	if interval == "off" {
+		return
+	}
+
+	period := defaultReactionReconcileInterval
+	if interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid reaction reconcile interval %q, using %s", interval, period)
 		} else {
-			// Existing reaction
-			if likeInfo.IsLike == body.IsLike {
-				// Same reaction - remove it (toggle off)
-				if err := repo.DeleteCommentLike(likeInfo.ID); err != nil {
-					http.Error(w, "Error removing reaction", http.StatusInternalServerError)
-					return
-				}
-				
-				if err := repo.UpdateReplyLikeCount(replyID, body.IsLike, false); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-			} else {
-				// Different reaction - update and adjust counters
-				if err := repo.UpsertReplyLike(replyID, userID, body.IsLike); err != nil {
-					http.Error(w, "Error updating reaction", http.StatusInternalServerError)
-					return
-				}
-				
-				// Decrement old counter
-				if err := repo.UpdateReplyLikeCount(replyID, !body.IsLike, false); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-				
-				// Increment new counter
-				if err := repo.UpdateReplyLikeCount(replyID, body.IsLike, true); err != nil {
-					http.Error(w, "Error updating counter", http.StatusInternalServerError)
-					return
-				}
-			}
+			period = parsed
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			repo := repositories.NewCommentRepository()
+			updated, err := repo.RecountReactions()
+			if err != nil {
+				log.Printf("Failed to reconcile comment reactions: %v", err)
+				continue
+			}
+			if updated > 0 {
+				log.Printf("Reconciled reaction counters on %d comments and replies", updated)
+			}
+		}
+	}()
 }
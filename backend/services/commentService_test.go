@@ -0,0 +1,217 @@
+package services
+
+import (
+	"canny-clone/auth"
+	"canny-clone/internal/testdb"
+	"canny-clone/repositories"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// internalNotesFixture is a feedback item with a public thread and an internal
+// note, and the people looking at it
+type internalNotesFixture struct {
+	feedback testdb.Feedback
+
+	publicID, publicReplyID   int
+	internalID, forcedReplyID int
+
+	member, stakeholder, admin *auth.Principal
+}
+
+func seedInternalNotes(t *testing.T) *internalNotesFixture {
+	db := openTestDB(t)
+	f := &internalNotesFixture{
+		member:      seedPrincipal(t, db, "user"),
+		stakeholder: seedPrincipal(t, db, "user"),
+		admin:       seedPrincipal(t, db, "app_admin"),
+	}
+	f.feedback = testdb.SeedFeedback(t, db, f.member.UserID, "Dark mode", "Please add a dark theme")
+
+	users := GetUserRepository()
+	if err := users.AddUserToBoard(f.member.UserID, f.feedback.BoardID, "user"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.AddUserToBoard(f.stakeholder.UserID, f.feedback.BoardID, "stakeholder"); err != nil {
+		t.Fatal(err)
+	}
+
+	comments := repositories.NewCommentRepository()
+	create := func(parentID, userID int, content string, internal bool) int {
+		id, err := comments.CreateComment(f.feedback.ID, parentID, userID, content, internal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	f.publicID = create(0, f.member.UserID, "Would love this for night shifts", false)
+	f.publicReplyID = create(f.publicID, f.stakeholder.UserID, "Thanks, we are looking into it", false)
+	f.internalID = create(0, f.stakeholder.UserID, "Blocked on the zebra redesign", true)
+	// Not asked to be internal, but it replies to an internal note
+	f.forcedReplyID = create(f.internalID, f.admin.UserID, "Redesign ships next quarter", false)
+	return f
+}
+
+type viewer struct {
+	name         string
+	principal    *auth.Principal
+	seesInternal bool
+}
+
+func (f *internalNotesFixture) viewers() []viewer {
+	return []viewer{
+		{"member", f.member, false},
+		{"stakeholder", f.stakeholder, true},
+		{"admin", f.admin, true},
+	}
+}
+
+func TestReplyToInternalNoteIsInternal(t *testing.T) {
+	f := seedInternalNotes(t)
+
+	info, err := repositories.NewCommentRepository().GetCommentInfo(f.forcedReplyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Internal {
+		t.Error("reply to an internal note was stored as public")
+	}
+}
+
+func TestGetCommentsHidesInternalNotes(t *testing.T) {
+	f := seedInternalNotes(t)
+
+	for _, v := range f.viewers() {
+		t.Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/comments?feedbackId="+strconv.Itoa(f.feedback.ID), nil)
+			w := serveAs(v.principal, GetComments, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+
+			var page repositories.CommentPage
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			seen := map[int]bool{}
+			var visit func(comments []*repositories.Comment)
+			visit = func(comments []*repositories.Comment) {
+				for _, c := range comments {
+					seen[c.ID] = true
+					visit(c.Replies)
+				}
+			}
+			visit(page.Comments)
+
+			want := map[int]bool{
+				f.publicID:      true,
+				f.publicReplyID: true,
+				f.internalID:    v.seesInternal,
+				f.forcedReplyID: v.seesInternal,
+			}
+			for id, visible := range want {
+				if seen[id] != visible {
+					t.Errorf("comment %d visible = %v, want %v", id, seen[id], visible)
+				}
+			}
+		})
+	}
+}
+
+func TestCommentCountHidesInternalNotes(t *testing.T) {
+	f := seedInternalNotes(t)
+
+	for _, v := range f.viewers() {
+		t.Run(v.name, func(t *testing.T) {
+			want := 2
+			if v.seesInternal {
+				want = 4
+			}
+
+			includeInternal, err := canSeeInternalNotes(v.principal, f.feedback.BoardID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, err := repositories.NewCommentRepository().CountCommentsByFeedbackID(f.feedback.ID, includeInternal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != want {
+				t.Errorf("CountCommentsByFeedbackID = %d, want %d", count, want)
+			}
+
+			r := httptest.NewRequest("GET", fmt.Sprintf("/feedback/%d", f.feedback.ID), nil)
+			r = mux.SetURLVars(r, map[string]string{"id": strconv.Itoa(f.feedback.ID)})
+			w := serveAs(v.principal, GetFeedback, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var detail FeedbackDetail
+			if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			if detail.CommentCount != want {
+				t.Errorf("detail commentCount = %d, want %d", detail.CommentCount, want)
+			}
+		})
+	}
+}
+
+func TestSearchHidesInternalNotes(t *testing.T) {
+	f := seedInternalNotes(t)
+
+	for _, v := range f.viewers() {
+		t.Run(v.name, func(t *testing.T) {
+			query := url.Values{"boardId": {strconv.Itoa(f.feedback.BoardID)}, "q": {"zebra"}}
+			r := httptest.NewRequest("GET", "/search?"+query.Encode(), nil)
+			w := serveAs(v.principal, SearchFeedback, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+
+			var results []repositories.SearchResult
+			if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+			found := len(results) == 1 && results[0].FeedbackID == f.feedback.ID && results[0].CommentSnippet != ""
+			if found != v.seesInternal {
+				t.Errorf("internal note found by search = %v, want %v (results %+v)", found, v.seesInternal, results)
+			}
+		})
+	}
+}
+
+func TestLiveCommentInfoHidesInternalNotes(t *testing.T) {
+	f := seedInternalNotes(t)
+	repo := repositories.NewCommentRepository()
+
+	for _, v := range f.viewers() {
+		t.Run(v.name, func(t *testing.T) {
+			for _, id := range []int{f.internalID, f.forcedReplyID} {
+				w := httptest.NewRecorder()
+				info, ok := liveCommentInfo(w, repo, v.principal, id)
+				if ok != v.seesInternal {
+					t.Errorf("liveCommentInfo(%d) ok = %v, want %v", id, ok, v.seesInternal)
+				}
+				if !ok && w.Code != http.StatusNotFound {
+					t.Errorf("liveCommentInfo(%d) wrote status %d, want 404", id, w.Code)
+				}
+				if ok && !info.Internal {
+					t.Errorf("liveCommentInfo(%d) is not marked internal", id)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			if _, ok := liveCommentInfo(w, repo, v.principal, f.publicID); !ok {
+				t.Errorf("public comment hidden: status %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"canny-clone/auth"
+	"canny-clone/internal/testdb"
+	"canny-clone/repositories"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// openTestDB points the repositories at the test database
+func openTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	conn := testdb.Open(tb)
+	repositories.SetDB(conn)
+	return conn
+}
+
+// seedPrincipal creates a user with the given global role and returns them as
+// a principal
+func seedPrincipal(tb testing.TB, db *sql.DB, role string) *auth.Principal {
+	tb.Helper()
+	user, err := GetUserRepository().GetUserByID(testdb.SeedUser(tb, db, role))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return &auth.Principal{UserID: user.ID, Email: user.Email, Name: user.Name, Role: user.Role}
+}
+
+// serveAs runs a handler for a request made by the principal
+func serveAs(p *auth.Principal, handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	return w
+}
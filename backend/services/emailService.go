@@ -0,0 +1,430 @@
+package services
+
+import (
+	"canny-clone/mailer"
+	"canny-clone/repositories"
+	"canny-clone/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEmailInterval = time.Minute
+	defaultDigestHour    = 8
+	emailBatchSize       = 200
+	emailClaimTimeout    = 15 * time.Minute   // Claims left by a replica that stopped mid-batch expire
+	maxEmailAge          = 7 * 24 * time.Hour // Older notifications are not emailed
+	maxDigestItems       = 50
+)
+
+// emailDeliveries lists the email delivery choices users have
+var emailDeliveries = map[string]bool{
+	repositories.EmailDeliveryImmediate: true,
+	repositories.EmailDeliveryDigest:    true,
+	repositories.EmailDeliveryOff:       true,
+}
+
+// emailNotifier sends notifications by email
+type emailNotifier struct {
+	sender     mailer.Sender
+	templates  *mailer.Templates
+	digestHour int
+	appURL     string
+	apiURL     string
+}
+
+// emailData is what the email templates are given
+type emailData struct {
+	Name           string
+	AppURL         string
+	UnsubscribeURL string
+	Notifications  []emailNotification
+	Total          int // Notifications in a digest, including those left out
+	More           int // Notifications left out of a digest
+}
+
+type emailNotification struct {
+	Type   string
+	Actor  string
+	Title  string
+	Status string
+	Link   string
+}
+
+// StartEmailNotifier emails notifications soon after they are created, or once
+// a day to users who chose a digest. Replicas share the work by claiming
+// notifications before sending them.
+// Email stays off until an SMTP host is configured.
+func StartEmailNotifier() {
+	config := utils.GetConfig()
+	if config.SMTPHost == "" {
+		log.Printf("Email notifications are off: no SMTP host configured")
+		return
+	}
+
+	sender, err := mailer.NewSMTPSender(mailer.SMTPConfig{
+		Host:        config.SMTPHost,
+		Port:        config.SMTPPort,
+		Username:    config.SMTPUsername,
+		Password:    config.SMTPPassword,
+		From:        config.SMTPFrom,
+		ImplicitTLS: config.SMTPImplicitTLS,
+	})
+	if err != nil {
+		log.Fatalf("Invalid SMTP configuration: %v", err)
+	}
+	templates, err := mailer.LoadTemplates()
+	if err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+
+	digestHour := defaultDigestHour
+	if config.EmailDigestHour != nil {
+		if hour := *config.EmailDigestHour; hour < 0 || hour > 23 {
+			log.Printf("Invalid email digest hour %d, using %d", hour, digestHour)
+		} else {
+			digestHour = hour
+		}
+	}
+
+	period := defaultEmailInterval
+	if config.EmailInterval != "" {
+		parsed, err := time.ParseDuration(config.EmailInterval)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid email interval %q, using %s", config.EmailInterval, period)
+		} else {
+			period = parsed
+		}
+	}
+
+	notifier := &emailNotifier{
+		sender:     sender,
+		templates:  templates,
+		digestHour: digestHour,
+		appURL:     strings.TrimRight(config.AppUrl, "/"),
+		apiURL:     strings.TrimRight(config.ApiUrl, "/"),
+	}
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			notifier.run()
+		}
+	}()
+}
+
+// run sends every email that is due
+func (e *emailNotifier) run() {
+	for {
+		more, err := e.sendBatch()
+		if err != nil {
+			log.Printf("Failed to send notification emails: %v", err)
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// sendBatch sends one batch of due emails and reports whether more may be
+// waiting. The batch is claimed first and sent without holding any locks, so a
+// slow mail server does not keep a transaction open. Each recipient's
+// notifications are marked emailed as soon as they are sent, so each is sent
+// once even with several replicas. When the server cannot be reached, what was
+// sent is kept and the claim on the rest is released.
+func (e *emailNotifier) sendBatch() (more bool, err error) {
+	repo := repositories.NewNotificationRepository()
+	pending, err := repo.ClaimPendingEmails(e.digestHour, maxEmailAge, emailClaimTimeout, emailBatchSize)
+	if err != nil {
+		return false, err
+	}
+	more = len(pending) == emailBatchSize
+	if more {
+		// The last recipient may have more waiting; leave them for the next
+		// batch so a digest is not split in two
+		if last := lastRecipientStart(pending); last > 0 {
+			if err := repo.ReleaseEmails(notificationIDs(pending[last:])); err != nil {
+				return false, err
+			}
+			pending = pending[:last]
+		}
+	}
+
+	for start := 0; start < len(pending); {
+		end := start
+		for end < len(pending) && pending[end].UserID == pending[start].UserID {
+			end++
+		}
+		handled, digest, sendErr := e.sendTo(pending[start:end])
+
+		err := repositories.RunInTx(func(tx *sql.Tx) error {
+			repo := repo.WithTx(tx)
+			if err := repo.MarkEmailed(handled); err != nil {
+				return err
+			}
+			if digest {
+				return repo.MarkDigestSent([]int{pending[start].UserID})
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if sendErr != nil {
+			if err := repo.ReleaseEmails(notificationIDs(pending[start:])); err != nil {
+				log.Printf("Failed to release notification emails: %v", err)
+			}
+			return false, sendErr
+		}
+		start = end
+	}
+	return more, nil
+}
+
+func notificationIDs(pending []repositories.PendingEmail) []int {
+	ids := make([]int, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// lastRecipientStart returns where the last recipient's notifications begin
+func lastRecipientStart(pending []repositories.PendingEmail) int {
+	last := len(pending) - 1
+	for last > 0 && pending[last-1].UserID == pending[len(pending)-1].UserID {
+		last--
+	}
+	return last
+}
+
+// sendTo emails one recipient's pending notifications, one email each or as a
+// digest. It returns the notifications that are done with, including those
+// passed over or refused by the server, and whether a digest went out. An
+// error means the rest should be tried again later.
+func (e *emailNotifier) sendTo(pending []repositories.PendingEmail) (handled []int, digest bool, err error) {
+	var due []repositories.PendingEmail
+	for _, p := range pending {
+		if p.Skip {
+			handled = append(handled, p.ID)
+		} else {
+			due = append(due, p)
+		}
+	}
+	if len(due) == 0 {
+		return handled, false, nil
+	}
+
+	if due[0].Delivery == repositories.EmailDeliveryDigest {
+		err := e.send("digest", due)
+		if err != nil && !mailer.IsPermanent(err) {
+			return handled, false, err
+		}
+		if err != nil {
+			log.Printf("Email digest to user %d refused: %v", due[0].UserID, err)
+		}
+		for _, p := range due {
+			handled = append(handled, p.ID)
+		}
+		return handled, err == nil, nil
+	}
+
+	for _, p := range due {
+		err := e.send("notification", []repositories.PendingEmail{p})
+		if err != nil && !mailer.IsPermanent(err) {
+			return handled, false, err
+		}
+		if err != nil {
+			log.Printf("Notification email to user %d refused: %v", p.UserID, err)
+		}
+		handled = append(handled, p.ID)
+	}
+	return handled, false, nil
+}
+
+// send renders the named email for notifications, all to the same recipient, and sends it
+func (e *emailNotifier) send(name string, notifications []repositories.PendingEmail) error {
+	recipient := notifications[0]
+	data := emailData{
+		Name:           recipient.Name,
+		AppURL:         e.appURL,
+		UnsubscribeURL: e.apiURL + "/unsubscribe?token=" + url.QueryEscape(unsubscribeToken(recipient.UserID)),
+		Total:          len(notifications),
+	}
+	if len(notifications) > maxDigestItems {
+		data.More = len(notifications) - maxDigestItems
+		notifications = notifications[:maxDigestItems]
+	}
+	for _, p := range notifications {
+		data.Notifications = append(data.Notifications, e.describe(p.Notification))
+	}
+
+	m := mailer.Message{
+		To:             mail.Address{Name: recipient.Name, Address: recipient.Email},
+		UnsubscribeURL: data.UnsubscribeURL,
+	}
+	if err := e.templates.Render(name, data, &m); err != nil {
+		return err
+	}
+	return e.sender.Send(m)
+}
+
+// describe flattens a notification for the templates
+func (e *emailNotifier) describe(n repositories.Notification) emailNotification {
+	d := emailNotification{
+		Type:  n.Type,
+		Actor: "Someone",
+		Link:  e.appURL,
+	}
+	if n.ActorName != nil {
+		d.Actor = *n.ActorName
+	}
+	if n.FeedbackTitle != nil {
+		d.Title = *n.FeedbackTitle
+	}
+	if n.Status != nil {
+		d.Status = *n.Status
+	}
+	if n.BoardID != nil {
+		d.Link = fmt.Sprintf("%s/boards/%d", e.appURL, *n.BoardID)
+	}
+	return d
+}
+
+// unsubscribeToken signs a user ID for the unsubscribe link in emails. It does
+// not expire, so links in old emails keep working.
+func unsubscribeToken(userID int) string {
+	id := strconv.Itoa(userID)
+	return id + "." + unsubscribeSignature(id)
+}
+
+func unsubscribeSignature(id string) string {
+	mac := hmac.New(sha256.New, []byte(JWTSecret))
+	mac.Write([]byte("unsubscribe:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseUnsubscribeToken returns the user an unsubscribe token was made for
+func parseUnsubscribeToken(token string) (int, bool) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(id))) {
+		return 0, false
+	}
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body style="font-family:Arial,sans-serif;color:#1f2937;max-width:480px;margin:64px auto;text-align:center">
+{{if .Done}}
+  <p>You will no longer receive notification emails. You can turn them back on from the notifications menu in the app.</p>
+{{else}}
+  <p>Stop receiving notification emails?</p>
+  <form method="POST">
+    <button type="submit" style="padding:8px 16px;background:#3b82f6;color:#ffffff;border:none;border-radius:4px">Unsubscribe</button>
+  </form>
+{{end}}
+</body>
+</html>`))
+
+// UnsubscribePage asks to confirm unsubscribing from an email link. Nothing
+// changes on GET, since mail scanners follow links.
+func UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := parseUnsubscribeToken(r.URL.Query().Get("token")); !ok {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]bool{"Done": false})
+}
+
+// Unsubscribe turns off notification emails for the user an email was sent to.
+// Mail clients post here directly for one-click unsubscribe (RFC 8058).
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUnsubscribeToken(r.URL.Query().Get("token"))
+	if !ok {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+	err := GetUserRepository().SetEmailDelivery(userID, repositories.EmailDeliveryOff)
+	if err == repositories.ErrUserNotFound {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating email preferences", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]bool{"Done": true})
+}
+
+// GetEmailPreferences returns how the current user receives notifications by
+// email, and whether the server sends email at all
+func GetEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := GetUserRepository().GetEmailDelivery(principal.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching email preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"delivery":  delivery,
+		"available": utils.GetConfig().SMTPHost != "",
+	})
+}
+
+// UpdateEmailPreferences changes how the current user receives notifications
+// by email: "immediate", "digest" or "off"
+func UpdateEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Delivery string `json:"delivery"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !emailDeliveries[body.Delivery] {
+		http.Error(w, "Invalid delivery value", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if err := GetUserRepository().SetEmailDelivery(principal.UserID, body.Delivery); err != nil {
+		http.Error(w, "Error updating email preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"delivery": body.Delivery})
+}
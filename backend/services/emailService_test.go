@@ -0,0 +1,200 @@
+package services
+
+import (
+	"canny-clone/mailer"
+	"canny-clone/repositories"
+	"database/sql"
+	"errors"
+	"net/textproto"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// fakeSender records messages instead of sending them. fail, when set, decides
+// the result for each message.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	fail func(m mailer.Message) error
+}
+
+func (s *fakeSender) Send(m mailer.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		if err := s.fail(m); err != nil {
+			return err
+		}
+	}
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeSender) sentTo(email string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, m := range s.sent {
+		if m.To.Address == email {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestNotifier(t *testing.T, sender mailer.Sender) *emailNotifier {
+	t.Helper()
+	templates, err := mailer.LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &emailNotifier{sender: sender, templates: templates, digestHour: defaultDigestHour}
+}
+
+// seedNotifications gives the user n notifications and returns their IDs
+func seedNotifications(t *testing.T, db *sql.DB, userID, n int) []int {
+	t.Helper()
+	repo := repositories.NewNotificationRepository()
+	for i := 0; i < n; i++ {
+		if err := repo.CreateNotifications([]int{userID}, repositories.Notification{Type: repositories.NotificationReply}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []int
+	rows, err := db.Query(`SELECT id FROM notifications WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// emailState counts the notifications that were emailed and those still claimed
+func emailState(t *testing.T, db *sql.DB, ids []int) (emailed, claimed int) {
+	t.Helper()
+	err := db.QueryRow(`
+		SELECT COUNT(emailed_at), COUNT(*) FILTER (WHERE emailed_at IS NULL AND email_claimed_at IS NOT NULL)
+		FROM notifications WHERE id = ANY($1)
+	`, pq.Array(ids)).Scan(&emailed, &claimed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return emailed, claimed
+}
+
+// Emails go out after the claim is committed, with no row locks held
+func TestEmailsAreSentWithoutLocks(t *testing.T) {
+	db := openTestDB(t)
+	user := seedPrincipal(t, db, "user")
+	ids := seedNotifications(t, db, user.UserID, 2)
+
+	sender := &fakeSender{}
+	sender.fail = func(m mailer.Message) error {
+		if m.To.Address != user.Email {
+			return nil
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec(`SELECT id FROM notifications WHERE id = ANY($1) FOR UPDATE NOWAIT`, pq.Array(ids)); err != nil {
+			t.Errorf("notifications were locked while emailing: %v", err)
+		}
+		return nil
+	}
+	newTestNotifier(t, sender).run()
+
+	if got := sender.sentTo(user.Email); got != 2 {
+		t.Errorf("sent %d emails, want 2", got)
+	}
+	if emailed, claimed := emailState(t, db, ids); emailed != 2 || claimed != 0 {
+		t.Errorf("%d emailed and %d still claimed, want 2 and 0", emailed, claimed)
+	}
+}
+
+func TestEmailClaimIsReleasedOnTransientFailure(t *testing.T) {
+	db := openTestDB(t)
+	user := seedPrincipal(t, db, "user")
+	ids := seedNotifications(t, db, user.UserID, 2)
+
+	down := errors.New("connection refused")
+	sender := &fakeSender{fail: func(m mailer.Message) error {
+		if m.To.Address == user.Email {
+			return down
+		}
+		return nil
+	}}
+	newTestNotifier(t, sender).run()
+
+	if emailed, claimed := emailState(t, db, ids); emailed != 0 || claimed != 0 {
+		t.Fatalf("after a transient failure %d emailed and %d claimed, want 0 and 0", emailed, claimed)
+	}
+
+	// The next run tries again
+	sender.fail = nil
+	newTestNotifier(t, sender).run()
+	if emailed, _ := emailState(t, db, ids); emailed != 2 {
+		t.Errorf("%d emailed after retrying, want 2", emailed)
+	}
+}
+
+// A message the server refuses is not retried
+func TestEmailPermanentFailureIsNotRetried(t *testing.T) {
+	db := openTestDB(t)
+	user := seedPrincipal(t, db, "user")
+	ids := seedNotifications(t, db, user.UserID, 1)
+
+	sender := &fakeSender{fail: func(m mailer.Message) error {
+		if m.To.Address == user.Email {
+			return &mailer.RejectedError{Stage: "RCPT", Err: &textproto.Error{Code: 550, Msg: "mailbox unavailable"}}
+		}
+		return nil
+	}}
+	newTestNotifier(t, sender).run()
+
+	if emailed, claimed := emailState(t, db, ids); emailed != 1 || claimed != 0 {
+		t.Errorf("%d emailed and %d claimed, want 1 and 0", emailed, claimed)
+	}
+}
+
+// A digest user without a recorded digest waits for the next one instead of
+// being emailed straight away
+func TestFirstDigestWaitsForDigestHour(t *testing.T) {
+	db := openTestDB(t)
+	user := seedPrincipal(t, db, "user")
+	if _, err := db.Exec(`UPDATE users SET email_delivery = 'digest', digest_sent_at = NULL WHERE id = $1`, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+	ids := seedNotifications(t, db, user.UserID, 2)
+
+	sender := &fakeSender{}
+	newTestNotifier(t, sender).run()
+	if got := sender.sentTo(user.Email); got != 0 {
+		t.Fatalf("sent %d emails before the digest was due, want 0", got)
+	}
+	if emailed, _ := emailState(t, db, ids); emailed != 0 {
+		t.Fatalf("%d notifications marked emailed before the digest was due", emailed)
+	}
+
+	// A day later the digest is due
+	if _, err := db.Exec(`UPDATE users SET digest_sent_at = digest_sent_at - INTERVAL '1 day' WHERE id = $1`, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+	newTestNotifier(t, sender).run()
+	if got := sender.sentTo(user.Email); got != 1 {
+		t.Errorf("sent %d emails once the digest was due, want 1 digest", got)
+	}
+	if emailed, _ := emailState(t, db, ids); emailed != 2 {
+		t.Errorf("%d notifications marked emailed, want 2", emailed)
+	}
+}
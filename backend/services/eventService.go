@@ -0,0 +1,170 @@
+package services
+
+import (
+	"canny-clone/authz"
+	"canny-clone/events"
+	"canny-clone/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Board event types
+const (
+	EventFeedbackCreated = "feedback.created"
+	EventFeedbackVoted   = "feedback.voted"
+	EventFeedbackStatus  = "feedback.status"
+	EventCommentCreated  = "comment.created"
+	EventCommentReacted  = "comment.reacted"
+)
+
+const (
+	boardEventHistory  = 500 // Events kept per board for Last-Event-ID replay
+	boardEventBuffer   = 64  // Events queued per connection before it is dropped as too slow
+	eventHeartbeat     = 20 * time.Second
+	eventWriteTimeout  = 10 * time.Second
+	eventRetryInterval = 3 * time.Second
+)
+
+var (
+	// boardEvents delivers events to the clients connected to this replica
+	boardEvents = events.NewBroker(boardEventHistory, boardEventBuffer)
+	// boardEventPublisher sends events to every replica's clients; until
+	// InitEventBus runs, only to this one's
+	boardEventPublisher events.Publisher = boardEvents
+)
+
+// InitEventBus shares board events between replicas through Postgres
+// LISTEN/NOTIFY, publishing on the connection pool set up by InitDB
+func InitEventBus(db *sql.DB) {
+	bus, err := events.NewPostgresBus(db, utils.GetConfig().DatabaseURL, boardEvents)
+	if err != nil {
+		log.Fatalf("Failed to listen for board events: %v", err)
+	}
+	boardEventPublisher = bus
+}
+
+// PublishBoardEvent pushes a change to everyone following the board. The change
+// has already been made, so failures are only logged.
+func PublishBoardEvent(boardID int, eventType string, data interface{}) {
+	publishBoardEvent(boardID, eventType, data, false)
+}
+
+func publishBoardEvent(boardID int, eventType string, data interface{}, stakeholdersOnly bool) {
+	e, err := events.NewEvent(boardID, eventType, data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+	e.StakeholdersOnly = stakeholdersOnly
+	if err := boardEventPublisher.Publish(e); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// BoardEvents streams a board's changes as Server-Sent Events. Clients that
+// reconnect with Last-Event-ID get the events they missed, or a "reset" event
+// when those are no longer known. Membership is checked again on every heartbeat,
+// and a connection that falls too far behind is closed so the client reconnects.
+func BoardEvents(w http.ResponseWriter, r *http.Request) {
+	boardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint64
+	resume := lastEventID != ""
+	if resume {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	canSeeInternal, err := canSeeInternalNotes(principal, boardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	sub, replay, complete := boardEvents.Subscribe(boardID, lastID, resume)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	send := func(format string, args ...interface{}) bool {
+		rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	sendEvent := func(e events.Event) bool {
+		if e.StakeholdersOnly && !canSeeInternal {
+			return true
+		}
+		return send("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	}
+
+	if !send("retry: %d\n\n", eventRetryInterval/time.Millisecond) {
+		return
+	}
+	if !complete && !send("event: reset\ndata: {}\n\n") {
+		return
+	}
+	for _, e := range replay {
+		if !sendEvent(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays
+				return
+			}
+			if !sendEvent(e) {
+				return
+			}
+
+		case <-heartbeat.C:
+			if err := authz.Authorize(principal, authz.ViewBoard, boardID); err != nil {
+				return
+			}
+			if canSeeInternal, err = canSeeInternalNotes(principal, boardID); err != nil {
+				return
+			}
+			if !send(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
@@ -1,30 +1,240 @@
 package services
 
 import (
+	"canny-clone/auth"
+	"canny-clone/authz"
 	"canny-clone/repositories"
 	"canny-clone/utils"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultFeedbackPageSize = 20
+	maxFeedbackPageSize     = 100
 )
 
+var errFeedbackNotFound = errors.New("feedback not found")
+
+// FeedbackDetail is a single feedback item with its related data
+type FeedbackDetail struct {
+	repositories.Feedback
+	Board         *repositories.Board                 `json:"board"`
+	Category      *repositories.Category              `json:"category"`
+	CommentCount  int                                 `json:"commentCount"`
+	StatusHistory []repositories.FeedbackStatusChange `json:"statusHistory"`
+}
+
+// parseFeedbackFilter reads the status, categoryId, sort, limit and cursor
+// query parameters shared by the feedback listings
+func parseFeedbackFilter(query url.Values) (repositories.FeedbackFilter, error) {
+	filter := repositories.FeedbackFilter{
+		Status: query.Get("status"),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+		Limit:  defaultFeedbackPageSize,
+	}
+
+	if filter.Status != "" && !repositories.IsFeedbackStatus(filter.Status) {
+		return filter, errors.New("Invalid status value")
+	}
+
+	var err error
+	if categoryIDStr := query.Get("categoryId"); categoryIDStr != "" {
+		filter.CategoryID, err = strconv.Atoi(categoryIDStr)
+		if err != nil || filter.CategoryID <= 0 {
+			return filter, errors.New("Invalid category ID")
+		}
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = "new"
+	}
+	if filter.Sort != "top" && filter.Sort != "new" && filter.Sort != "trending" {
+		return filter, errors.New("Invalid sort value")
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		filter.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxFeedbackPageSize {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+
+	return filter, nil
+}
+
+// writeFeedbackPage fetches a page of feedback matching the filter and writes it as JSON
+func writeFeedbackPage(w http.ResponseWriter, filter repositories.FeedbackFilter) {
+	repo := repositories.NewFeedbackRepository()
+	page, err := repo.GetFeedbacks(filter)
+	if err == repositories.ErrInvalidCursor {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching feedbacks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetFeedbacks returns a page of a board's feedback, optionally filtered by
+// status and category and sorted by "top", "new" or "trending"
 func GetFeedbacks(w http.ResponseWriter, r *http.Request) {
-	boardIDStr := r.URL.Query().Get("boardId")
-	boardID, err := strconv.Atoi(boardIDStr)
+	query := r.URL.Query()
+	boardID, err := strconv.Atoi(query.Get("boardId"))
 	if err != nil {
 		http.Error(w, "Invalid board ID", http.StatusBadRequest)
 		return
 	}
 
-	repo := repositories.NewFeedbackRepository()
-	feedbacks, err := repo.GetFeedbacksByBoardID(boardID)
+	filter, err := parseFeedbackFilter(query)
 	if err != nil {
-		http.Error(w, "Error fetching feedbacks", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.BoardID = boardID
+
+	// Soft-deleted feedback is only listed for admins
+	if query.Get("deleted") == "true" {
+		principal, ok := requirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		if err := authz.Authorize(principal, authz.RestoreFeedback, boardID); err != nil {
+			http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		filter.Deleted = true
+	}
+
+	writeFeedbackPage(w, filter)
+}
+
+// GetMyFeedback returns a page of the feedback the current user posted on
+// the boards they belong to, including posts that were merged
+func GetMyFeedback(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseFeedbackFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.AuthorID = principal.UserID
+	filter.MemberID = principal.UserID
+	filter.Merged = true
+
+	writeFeedbackPage(w, filter)
+}
+
+// GetMyVotes returns a page of the feedback the current user voted on across
+// the boards they belong to, each with the user's vote
+func GetMyVotes(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseFeedbackFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.VoterID = principal.UserID
+	filter.MemberID = principal.UserID
+
+	writeFeedbackPage(w, filter)
+}
+
+// GetFeedback returns a single feedback item (access checked by the route policy)
+func GetFeedback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	feedbackID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
 
+	feedbackRepo := repositories.NewFeedbackRepository()
+	feedback, err := feedbackRepo.GetFeedbackByID(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching feedback", http.StatusInternalServerError)
+		return
+	}
+
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+
+	board, err := repositories.NewBoardRepository().GetBoardByID(feedback.BoardID)
+	if err != nil {
+		http.Error(w, "Error fetching board", http.StatusInternalServerError)
+		return
+	}
+
+	category, err := repositories.NewCategoryRepository().GetCategoryByID(feedback.CategoryID)
+	if err != nil {
+		http.Error(w, "Error fetching category", http.StatusInternalServerError)
+		return
+	}
+
+	vote, err := repositories.NewVoteRepository().GetVoteByFeedbackAndUser(feedbackID, principal.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching vote", http.StatusInternalServerError)
+		return
+	}
+
+	includeInternal, err := canSeeInternalNotes(principal, feedback.BoardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	commentCount, err := repositories.NewCommentRepository().CountCommentsByFeedbackID(feedbackID, includeInternal)
+	if err != nil {
+		http.Error(w, "Error fetching comment count", http.StatusInternalServerError)
+		return
+	}
+
+	statusHistory, err := feedbackRepo.GetStatusHistory(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching status history", http.StatusInternalServerError)
+		return
+	}
+
+	detail := FeedbackDetail{
+		Feedback:      *feedback,
+		Board:         board,
+		Category:      category,
+		CommentCount:  commentCount,
+		StatusHistory: statusHistory,
+	}
+	if vote != nil {
+		detail.UserVote = &vote.VoteType
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(feedbacks)
+	json.NewEncoder(w).Encode(detail)
 }
 
 func AddFeedback(w http.ResponseWriter, r *http.Request) {
@@ -44,11 +254,28 @@ func AddFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	// The category must belong to the board the feedback is posted on
+	category, err := repositories.NewCategoryRepository().GetCategoryByID(body.CategoryID)
+	if err != nil {
+		http.Error(w, "Error fetching category", http.StatusInternalServerError)
+		return
+	}
+	if category == nil || category.BoardID != body.BoardID {
+		http.Error(w, "Invalid category for this board", http.StatusBadRequest)
+		return
+	}
+
 	feedback := &repositories.Feedback{
 		BoardID:     body.BoardID,
 		Title:       body.Title,
 		Description: body.Description,
 		CategoryID:  body.CategoryID,
+		UserID:      &principal.UserID,
 	}
 
 	repo := repositories.NewFeedbackRepository()
@@ -57,17 +284,10 @@ func AddFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-}
+	PublishBoardEvent(feedback.BoardID, EventFeedbackCreated, map[string]int{"feedbackId": feedback.ID})
 
-// Helper to get user ID from request (in a real app, this would come from auth middleware)
-func getUserIDFromRequest(r *http.Request) int {
-	userIDStr := r.Header.Get("User-ID")
-	if userIDStr == "" {
-		return 0
-	}
-	userID, _ := strconv.Atoi(userIDStr)
-	return userID
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"id": feedback.ID})
 }
 
 // Get a reference to the feedback repository
@@ -91,71 +311,417 @@ func VoteFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from request (in a real app, this would come from auth middleware)
-	userID := getUserIDFromRequest(r)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
 
-	// Initialize repositories
-	voteRepo := repositories.NewVoteRepository()
-	feedbackRepo := repositories.NewFeedbackRepository()
+	// Apply the vote and recount in one transaction so counters always match the votes table
+	var voted *repositories.Feedback
+	err := repositories.RunInTx(func(tx *sql.Tx) error {
+		voteRepo := repositories.NewVoteRepository().WithTx(tx)
+		feedbackRepo := repositories.NewFeedbackRepository().WithTx(tx)
+
+		// Lock the feedback row so concurrent votes on it are applied one at a time
+		feedback, err := feedbackRepo.GetFeedbackByIDForUpdate(body.FeedbackID)
+		if err != nil {
+			return err
+		}
+		if feedback == nil {
+			return errFeedbackNotFound
+		}
+
+		// Merged feedback is read-only
+		if feedback.MergedInto != nil {
+			return repositories.ErrFeedbackMerged
+		}
+
+		// Check if user has already voted on this feedback
+		existingVote, err := voteRepo.GetVoteByFeedbackAndUser(body.FeedbackID, principal.UserID)
+		if err != nil {
+			return err
+		}
+
+		// Handle voting logic
+		if existingVote == nil {
+			// User hasn't voted before, create new vote
+			err = voteRepo.CreateVote(&repositories.Vote{
+				FeedbackID: body.FeedbackID,
+				UserID:     principal.UserID,
+				VoteType:   body.VoteType,
+			})
+		} else if existingVote.VoteType == body.VoteType {
+			// User is clicking the same vote type again - toggle off (remove vote)
+			err = voteRepo.DeleteVote(existingVote.ID)
+		} else {
+			// User is changing their vote from upvote to downvote or vice versa
+			err = voteRepo.UpdateVote(existingVote.ID, body.VoteType)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := feedbackRepo.RecountFeedbackVotes(body.FeedbackID); err != nil {
+			return err
+		}
+		voted, err = feedbackRepo.GetFeedbackByID(body.FeedbackID)
+		return err
+	})
+
+	switch {
+	case err == errFeedbackNotFound:
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	case err == repositories.ErrFeedbackMerged:
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+
+	PublishBoardEvent(voted.BoardID, EventFeedbackVoted, map[string]int{
+		"feedbackId": voted.ID,
+		"upvotes":    voted.Upvotes,
+		"downvotes":  voted.Downvotes,
+	})
 
-	// Check if user has already voted on this feedback
-	existingVote, err := voteRepo.GetVoteByFeedbackAndUser(body.FeedbackID, userID)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RecountVotes rebuilds every feedback vote counter from the votes table (admin only)
+func RecountVotes(w http.ResponseWriter, r *http.Request) {
+	repo := repositories.NewFeedbackRepository()
+	updated, err := repo.RecountAllFeedbackVotes()
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		http.Error(w, "Error recounting votes", http.StatusInternalServerError)
 		return
 	}
 
-	// Handle voting logic
-	if existingVote == nil {
-		// User hasn't voted before, create new vote
-		newVote := &repositories.Vote{
-			FeedbackID: body.FeedbackID,
-			UserID:     userID,
-			VoteType:   body.VoteType,
-		}
-		
-		if err := voteRepo.CreateVote(newVote); err != nil {
-			http.Error(w, "Error creating vote", http.StatusInternalServerError)
-			return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
+// markdownBackfillBatch is how many rows StartMarkdownBackfill renders at a time
+const markdownBackfillBatch = 500
+
+// StartMarkdownBackfill renders the descriptions and comments written before
+// their HTML was stored, so reads stop rendering them. Until it gets to a row,
+// reading it renders the Markdown as before.
+func StartMarkdownBackfill() {
+	go func() {
+		feedback := repositories.NewFeedbackRepository()
+		comments := repositories.NewCommentRepository()
+		for _, backfill := range []struct {
+			what   string
+			render func(limit int) (int, error)
+		}{
+			{"feedback descriptions", feedback.RenderMissingDescriptions},
+			{"comments", comments.RenderMissingContent},
+		} {
+			total := 0
+			for {
+				n, err := backfill.render(markdownBackfillBatch)
+				if err != nil {
+					log.Printf("Failed to render stored %s: %v", backfill.what, err)
+					break
+				}
+				total += n
+				if n < markdownBackfillBatch {
+					break
+				}
+			}
+			if total > 0 {
+				log.Printf("Rendered Markdown of %d %s", total, backfill.what)
+			}
 		}
-		
-		// Update feedback vote count
-		if err := feedbackRepo.UpdateFeedbackVote(body.FeedbackID, body.VoteType == "upvote", true); err != nil {
-			http.Error(w, "Error updating vote count", http.StatusInternalServerError)
+	}()
+}
+
+// TextChange is a field's value before and after a revision
+type TextChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CategoryChange is the category before and after a revision
+type CategoryChange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// RevisionChanges describes what a revision changed compared to the one before it
+type RevisionChanges struct {
+	Title       *TextChange      `json:"title,omitempty"`
+	Category    *CategoryChange  `json:"category,omitempty"`
+	Description []utils.DiffLine `json:"description,omitempty"`
+}
+
+// FeedbackRevisionDetail is a revision with its changes; the first revision has none
+type FeedbackRevisionDetail struct {
+	repositories.FeedbackRevision
+	Changes *RevisionChanges `json:"changes"`
+}
+
+// canModifyFeedback reports whether the principal may edit or delete a feedback
+// item: its author while it is still pending, or a stakeholder of its board
+func canModifyFeedback(principal *auth.Principal, feedback *repositories.Feedback) (bool, error) {
+	if feedback.UserID != nil && *feedback.UserID == principal.UserID && feedback.Status == "pending" {
+		return true, nil
+	}
+	err := authz.Authorize(principal, authz.ModerateFeedback, feedback.BoardID)
+	if err == authz.ErrForbidden {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// feedbackForChange loads the feedback in the "id" route variable and checks
+// that the principal may change it, writing an error response if not
+func feedbackForChange(w http.ResponseWriter, r *http.Request) (*auth.Principal, *repositories.Feedback, bool) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	feedback, err := repositories.NewFeedbackRepository().GetFeedbackByID(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching feedback", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	allowed, err := canModifyFeedback(principal, feedback)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if !allowed {
+		http.Error(w, "Forbidden: Only the author can change a pending post", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return principal, feedback, true
+}
+
+// UpdateFeedback edits a feedback item's title, description and category,
+// recording the new content as a revision
+func UpdateFeedback(w http.ResponseWriter, r *http.Request) {
+	principal, feedback, ok := feedbackForChange(w, r)
+	if !ok {
+		return
+	}
+
+	if feedback.MergedInto != nil {
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	}
+
+	var body struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		CategoryID  int    `json:"categoryId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ValidateFeedback(body.Title, body.Description, body.CategoryID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.CategoryID != feedback.CategoryID {
+		category, err := repositories.NewCategoryRepository().GetCategoryByID(body.CategoryID)
+		if err != nil {
+			http.Error(w, "Error fetching category", http.StatusInternalServerError)
 			return
 		}
-	} else if existingVote.VoteType == body.VoteType {
-		// User is clicking the same vote type again - toggle off (remove vote)
-		if err := voteRepo.DeleteVote(existingVote.ID); err != nil {
-			http.Error(w, "Error removing vote", http.StatusInternalServerError)
+		if category == nil || category.BoardID != feedback.BoardID {
+			http.Error(w, "Invalid category for this board", http.StatusBadRequest)
 			return
 		}
-		
-		// Decrement vote count
-		if err := feedbackRepo.UpdateFeedbackVote(body.FeedbackID, body.VoteType == "upvote", false); err != nil {
-			http.Error(w, "Error updating vote count", http.StatusInternalServerError)
+	}
+
+	// Only record a revision when something actually changed
+	if body.Title != feedback.Title || body.Description != feedback.Description || body.CategoryID != feedback.CategoryID {
+		feedback.Title = body.Title
+		feedback.Description = body.Description
+		feedback.CategoryID = body.CategoryID
+
+		repo := repositories.NewFeedbackRepository()
+		if err := repo.UpdateFeedback(feedback, principal.UserID); err != nil {
+			if err == repositories.ErrFeedbackNotFound {
+				http.Error(w, "Feedback not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error updating feedback", http.StatusInternalServerError)
 			return
 		}
-	} else {
-		// User is changing their vote from upvote to downvote or vice versa
-		if err := voteRepo.UpdateVote(existingVote.ID, body.VoteType); err != nil {
-			http.Error(w, "Error updating vote", http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// DeleteFeedback soft-deletes a feedback item
+func DeleteFeedback(w http.ResponseWriter, r *http.Request) {
+	principal, feedback, ok := feedbackForChange(w, r)
+	if !ok {
+		return
+	}
+
+	repo := repositories.NewFeedbackRepository()
+	if err := repo.DeleteFeedback(feedback.ID, principal.UserID); err != nil {
+		if err == repositories.ErrFeedbackNotFound {
+			http.Error(w, "Feedback not found", http.StatusNotFound)
 			return
 		}
-		
-		// Decrement old vote type count
-		if err := feedbackRepo.UpdateFeedbackVote(body.FeedbackID, existingVote.VoteType == "upvote", false); err != nil {
-			http.Error(w, "Error updating vote count", http.StatusInternalServerError)
+		http.Error(w, "Error deleting feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Feedback deleted successfully"})
+}
+
+// RestoreFeedback brings back a soft-deleted feedback item (admin only)
+func RestoreFeedback(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	repo := repositories.NewFeedbackRepository()
+	if err := repo.RestoreFeedback(feedbackID); err != nil {
+		if err == repositories.ErrFeedbackNotFound {
+			http.Error(w, "Deleted feedback not found", http.StatusNotFound)
 			return
 		}
-		
-		// Increment new vote type count
-		if err := feedbackRepo.UpdateFeedbackVote(body.FeedbackID, body.VoteType == "upvote", true); err != nil {
-			http.Error(w, "Error updating vote count", http.StatusInternalServerError)
-			return
+		http.Error(w, "Error restoring feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Feedback restored successfully"})
+}
+
+// PinOfficialResponse pins one of a feedback item's comments as its official
+// response, replacing any previously pinned comment
+func PinOfficialResponse(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		CommentID int `json:"commentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.CommentID <= 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setOfficialResponse(w, feedbackID, &body.CommentID)
+}
+
+// UnpinOfficialResponse removes a feedback item's official response; the
+// comment itself stays in the thread
+func UnpinOfficialResponse(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	setOfficialResponse(w, feedbackID, nil)
+}
+
+func setOfficialResponse(w http.ResponseWriter, feedbackID int, commentID *int) {
+	repo := repositories.NewFeedbackRepository()
+	feedback, err := repo.GetFeedbackByID(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching feedback", http.StatusInternalServerError)
+		return
+	}
+	if feedback == nil {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	if feedback.MergedInto != nil {
+		http.Error(w, "Feedback has been merged", http.StatusConflict)
+		return
+	}
+
+	if err := repo.SetOfficialResponse(feedbackID, commentID); err != nil {
+		switch err {
+		case repositories.ErrCommentNotFound:
+			http.Error(w, "Comment not found on this feedback", http.StatusNotFound)
+		case repositories.ErrFeedbackNotFound:
+			http.Error(w, "Feedback not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Error updating official response", http.StatusInternalServerError)
 		}
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                 feedbackID,
+		"officialResponseId": commentID,
+	})
+}
+
+// GetFeedbackRevisions returns a feedback item's revisions, oldest first,
+// each with what changed since the previous one
+func GetFeedbackRevisions(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feedback ID", http.StatusBadRequest)
+		return
+	}
+
+	repo := repositories.NewFeedbackRepository()
+	revisions, err := repo.GetFeedbackRevisions(feedbackID)
+	if err != nil {
+		http.Error(w, "Error fetching revisions", http.StatusInternalServerError)
+		return
+	}
+
+	details := make([]FeedbackRevisionDetail, len(revisions))
+	for i, rev := range revisions {
+		details[i].FeedbackRevision = rev
+		if i == 0 {
+			continue
+		}
+
+		prev := revisions[i-1]
+		changes := &RevisionChanges{}
+		if prev.Title != rev.Title {
+			changes.Title = &TextChange{From: prev.Title, To: rev.Title}
+		}
+		if prev.CategoryID != rev.CategoryID {
+			changes.Category = &CategoryChange{From: prev.CategoryID, To: rev.CategoryID}
+		}
+		if prev.Description != rev.Description {
+			changes.Description = utils.DiffLines(prev.Description, rev.Description)
+		}
+		details[i].Changes = changes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
 }
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"canny-clone/authz"
+	"canny-clone/repositories"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+
+	// completedStatus is the status that notifies everyone who voted on a feedback item
+	completedStatus = "completed"
+)
+
+// GetMyNotifications returns a page of the current user's notifications, newest
+// first, with how many are unread
+func GetMyNotifications(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultNotificationPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxNotificationPageSize {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := repositories.NewNotificationRepository().GetNotifications(principal.UserID, query.Get("cursor"), limit)
+	if err == repositories.ErrInvalidCursor {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// MarkNotificationRead marks one of the current user's notifications as read
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	repo := repositories.NewNotificationRepository()
+	if err := repo.MarkRead(principal.UserID, notificationID); err != nil {
+		if err == repositories.ErrNotificationNotFound {
+			http.Error(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating notification", http.StatusInternalServerError)
+		return
+	}
+	writeUnreadCount(w, repo, principal.UserID)
+}
+
+// MarkAllNotificationsRead marks all of the current user's notifications as read
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	repo := repositories.NewNotificationRepository()
+	if _, err := repo.MarkAllRead(principal.UserID); err != nil {
+		http.Error(w, "Error updating notifications", http.StatusInternalServerError)
+		return
+	}
+	writeUnreadCount(w, repo, principal.UserID)
+}
+
+// writeUnreadCount responds with the user's unread count so clients can update their badge
+func writeUnreadCount(w http.ResponseWriter, repo repositories.NotificationRepository, userID int) {
+	count, err := repo.CountUnread(userID)
+	if err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unreadCount": count})
+}
+
+// NotifyStatusChange tells a feedback item's author that a stakeholder moved it
+// to status and, when it was completed, everyone who voted on it. Runs in tx so
+// notifications are only sent if the change is saved.
+func NotifyStatusChange(tx *sql.Tx, feedback *repositories.Feedback, actorID int, status string) error {
+	notifications := repositories.NewNotificationRepository().WithTx(tx)
+
+	var author []int
+	if feedback.UserID != nil && *feedback.UserID != actorID {
+		author = []int{*feedback.UserID}
+	}
+	err := notifications.CreateNotifications(author, repositories.Notification{
+		Type:       repositories.NotificationStatusChange,
+		ActorID:    &actorID,
+		FeedbackID: &feedback.ID,
+		Status:     &status,
+	})
+	if err != nil || status != completedStatus {
+		return err
+	}
+
+	voterIDs, err := repositories.NewFeedbackRepository().WithTx(tx).GetFeedbackVoterIDs(feedback.ID)
+	if err != nil {
+		return err
+	}
+	// The author already heard about it as a status change
+	voters := []int{}
+	for _, userID := range voterIDs {
+		if userID != actorID && (feedback.UserID == nil || userID != *feedback.UserID) {
+			voters = append(voters, userID)
+		}
+	}
+	return notifications.CreateNotifications(voters, repositories.Notification{
+		Type:       repositories.NotificationCompleted,
+		ActorID:    &actorID,
+		FeedbackID: &feedback.ID,
+	})
+}
+
+// NotifyMerged tells the users who voted on a duplicate that it was merged and
+// their votes moved to the target
+func NotifyMerged(tx *sql.Tx, voterIDs []int, actorID, sourceID int) error {
+	voters := []int{}
+	for _, userID := range voterIDs {
+		if userID != actorID {
+			voters = append(voters, userID)
+		}
+	}
+	return repositories.NewNotificationRepository().WithTx(tx).CreateNotifications(voters, repositories.Notification{
+		Type:       repositories.NotificationMerged,
+		ActorID:    &actorID,
+		FeedbackID: &sourceID,
+	})
+}
+
+// notificationRecipients drops the actor from userIDs and, for internal notes,
+// the users who cannot read them
+func notificationRecipients(userIDs []int, actorID, boardID int, internal bool) ([]int, error) {
+	userRepo := GetUserRepository()
+	recipients := []int{}
+	for _, userID := range userIDs {
+		if userID == actorID {
+			continue
+		}
+		if internal {
+			user, err := userRepo.GetUserByID(userID)
+			if err != nil {
+				return nil, err
+			}
+			boardRoles, err := userRepo.GetUserBoardRoles(userID)
+			if err != nil {
+				return nil, err
+			}
+			if user == nil || !authz.Allowed(authz.ViewInternalNotes, user.Role, boardRoles[boardID]) {
+				continue
+			}
+		}
+		recipients = append(recipients, userID)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"canny-clone/repositories"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchFeedback searches a board's feedback and comments
+func SearchFeedback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	boardID, err := strconv.Atoi(query.Get("boardId"))
+	if err != nil {
+		http.Error(w, "Invalid board ID", http.StatusBadRequest)
+		return
+	}
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		http.Error(w, "Search query cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	includeInternal, err := canSeeInternalNotes(principal, boardID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	repo := repositories.NewSearchRepository()
+	results, err := repo.SearchFeedback(boardID, q, limit, includeInternal)
+	if err != nil {
+		http.Error(w, "Error searching feedback", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range results {
+		results[i].TitleHighlight = escapeHighlight(results[i].TitleHighlight)
+		results[i].Snippet = escapeHighlight(results[i].Snippet)
+		results[i].CommentSnippet = escapeHighlight(results[i].CommentSnippet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// escapeHighlight HTML-escapes a highlighted snippet, keeping only the <mark> tags
+func escapeHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(s, "&lt;/mark&gt;", "</mark>")
+}
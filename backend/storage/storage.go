@@ -0,0 +1,37 @@
+// Package storage keeps uploaded files, such as attachments, outside the database.
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under a key
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for keys that are empty, absolute or contain ".." segments
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage stores objects under slash-separated keys
+type Storage interface {
+	// Put stores size bytes read from body under key, replacing any object already there
+	Put(key string, body io.Reader, size int64, contentType string) error
+	// Open returns the object stored under key, or ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(key string) error
+}
+
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"attachments/12/report.pdf", true},
+		{"report.pdf", true},
+		{"a/..b/c", true},
+		{"", false},
+		{"/attachments/12/report.pdf", false},
+		{"/", false},
+		{"..", false},
+		{"../secrets", false},
+		{"attachments/../../secrets", false},
+		{"attachments/..", false},
+		{"attachments/./report.pdf", false},
+		{"attachments//report.pdf", false},
+		{"attachments/", false},
+		{`attachments\..\secrets`, false},
+		{`..\secrets`, false},
+		{`attachments\report.pdf`, false},
+	}
+	for _, tt := range tests {
+		if got := validKey(tt.key); got != tt.want {
+			t.Errorf("validKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+// testRoundTrip puts, reads and deletes an object, checking that missing and
+// invalid keys are reported as such
+func testRoundTrip(t *testing.T, store Storage, key string) {
+	t.Helper()
+	const content = "Hello, attachments"
+
+	if err := store.Put(key, strings.NewReader(content+" and more"), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	body, err := store.Open(key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("Open() read %q, want the %d bytes put: %q", got, len(content), content)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Open(key); err != ErrNotFound {
+		t.Errorf("Open() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete() of a missing object error = %v, want nil", err)
+	}
+
+	for _, bad := range []string{"../escape", "/absolute", `dir\..\escape`} {
+		if err := store.Put(bad, strings.NewReader(content), int64(len(content)), ""); err != ErrInvalidKey {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", bad, err)
+		}
+		if _, err := store.Open(bad); err != ErrInvalidKey {
+			t.Errorf("Open(%q) error = %v, want ErrInvalidKey", bad, err)
+		}
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRoundTrip(t, store, "attachments/1/hello.txt")
+}
@@ -14,6 +14,36 @@ type Configuration struct {
 	GoogleClientSecret string `json:"googleClientSecret"`
 	GoogleRedirectURL string `json:"googleRedirectUrl"`
 	JWTSecret         string `json:"jwtSecret"`
+	// How often reaction counters are rebuilt, e.g. "10m"; "off" disables it
+	ReactionReconcileInterval string `json:"reactionReconcileInterval"`
+	// How long authors may edit their comments, e.g. "15m"; "0" removes the limit
+	CommentEditWindow string `json:"commentEditWindow"`
+	// Where attachments are stored: "local" (the default) or "s3"
+	AttachmentStorage string `json:"attachmentStorage"`
+	// Directory of the local attachment store; defaults to "uploads"
+	AttachmentDir string `json:"attachmentDir"`
+	// S3-compatible attachment store. Leave the endpoint empty for AWS, or set it
+	// to e.g. "http://minio:9000" with path-style addressing for MinIO.
+	S3Endpoint  string `json:"s3Endpoint"`
+	S3Region    string `json:"s3Region"`
+	S3Bucket    string `json:"s3Bucket"`
+	S3AccessKey string `json:"s3AccessKey"`
+	S3SecretKey string `json:"s3SecretKey"`
+	S3PathStyle bool   `json:"s3PathStyle"`
+	// Address of the web app, used for links in emails, e.g. "http://localhost:3000"
+	AppUrl string `json:"appUrl"`
+	// SMTP server for notification emails; email is off while smtpHost is empty.
+	// For MailHog, use "smtpHost": "mailhog" and "smtpPort": 1025.
+	SMTPHost        string `json:"smtpHost"`
+	SMTPPort        int    `json:"smtpPort"`
+	SMTPUsername    string `json:"smtpUsername"`
+	SMTPPassword    string `json:"smtpPassword"`
+	SMTPFrom        string `json:"smtpFrom"`
+	SMTPImplicitTLS bool   `json:"smtpImplicitTls"`
+	// How often waiting notification emails are sent, e.g. "1m"
+	EmailInterval string `json:"emailInterval"`
+	// Hour of the day (0-23, database time zone) daily digests are sent; defaults to 8
+	EmailDigestHour *int `json:"emailDigestHour"`
 }
 
 var config Configuration
@@ -0,0 +1,160 @@
+package utils
+
+import "strings"
+
+// maxDiffCost bounds the number of edits DiffLines searches for between two
+// stretches of lines. Stretches that need more are reported as deleted and
+// reinserted as a whole, which keeps very different descriptions cheap to diff.
+const maxDiffCost = 1000
+
+// DiffLine is one line of a line-based diff
+type DiffLine struct {
+	Op   string `json:"op"` // "equal", "insert" or "delete"
+	Text string `json:"text"`
+}
+
+// DiffLines returns the line-based diff turning from into to. It uses Myers'
+// linear-space algorithm, so memory grows with the number of lines rather
+// than with their product.
+func DiffLines(from, to string) []DiffLine {
+	d := differ{
+		a:    strings.Split(from, "\n"),
+		b:    strings.Split(to, "\n"),
+		diff: []DiffLine{},
+	}
+	d.compare(0, len(d.a), 0, len(d.b))
+	return d.diff
+}
+
+type differ struct {
+	a, b []string
+	diff []DiffLine
+}
+
+func (d *differ) add(op string, lines []string) {
+	for _, line := range lines {
+		d.diff = append(d.diff, DiffLine{Op: op, Text: line})
+	}
+}
+
+// compare appends the diff turning a[aStart:aEnd] into b[bStart:bEnd]
+func (d *differ) compare(aStart, aEnd, bStart, bEnd int) {
+	prefix := aStart
+	for aStart < aEnd && bStart < bEnd && d.a[aStart] == d.b[bStart] {
+		aStart++
+		bStart++
+	}
+	d.add("equal", d.a[prefix:aStart])
+
+	suffixEnd := aEnd
+	for aStart < aEnd && bStart < bEnd && d.a[aEnd-1] == d.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch {
+	case aStart == aEnd:
+		d.add("insert", d.b[bStart:bEnd])
+	case bStart == bEnd:
+		d.add("delete", d.a[aStart:aEnd])
+	default:
+		if x, y, ok := d.middleSnake(aStart, aEnd, bStart, bEnd); ok {
+			d.compare(aStart, x, bStart, y)
+			d.compare(x, aEnd, y, bEnd)
+		} else {
+			d.add("delete", d.a[aStart:aEnd])
+			d.add("insert", d.b[bStart:bEnd])
+		}
+	}
+
+	d.add("equal", d.a[aEnd:suffixEnd])
+}
+
+// middleSnake searches for a shortest edit script from both ends at once and
+// returns a point on it where the two searches meet, splitting the problem in
+// two. It gives up once the script would need more than maxDiffCost edits.
+func (d *differ) middleSnake(aStart, aEnd, bStart, bEnd int) (x, y int, ok bool) {
+	a, b := d.a[aStart:aEnd], d.b[bStart:bEnd]
+	n, m := len(a), len(b)
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] is the furthest x reached on diagonal k = x - y from
+	// the start; backward is the same measured from the end
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	oddDelta := delta%2 != 0
+	// Diagonals that have run off the edge are trimmed from later rounds
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for step := 0; step < maxD && step <= maxDiffCost/2; step++ {
+		for k := -step + forwardStart; k <= step-forwardEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -step || (k != step && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && a[fx] == b[fy] {
+				fx++
+				fy++
+			}
+			forward[i] = fx
+
+			switch {
+			case fx > n:
+				forwardEnd += 2
+			case fy > m:
+				forwardStart += 2
+			case oddDelta:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+					return aStart + fx, bStart + fy, true
+				}
+			}
+		}
+
+		for k := -step + backwardStart; k <= step-backwardEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -step || (k != step && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[n-bx-1] == b[m-by-1] {
+				bx++
+				by++
+			}
+			backward[i] = bx
+
+			switch {
+			case bx > n:
+				backwardEnd += 2
+			case by > m:
+				backwardStart += 2
+			case !oddDelta:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					fy := offset + fx - j
+					if fx >= n-bx {
+						return aStart + fx, bStart + fy, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
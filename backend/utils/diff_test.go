@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	eq := func(s string) DiffLine { return DiffLine{Op: "equal", Text: s} }
+	ins := func(s string) DiffLine { return DiffLine{Op: "insert", Text: s} }
+	del := func(s string) DiffLine { return DiffLine{Op: "delete", Text: s} }
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     []DiffLine
+	}{
+		{"unchanged", "a\nb", "a\nb", []DiffLine{eq("a"), eq("b")}},
+		{"from empty", "", "a", []DiffLine{del(""), ins("a")}},
+		{"line appended", "a", "a\nb", []DiffLine{eq("a"), ins("b")}},
+		{"line removed", "a\nb\nc", "a\nc", []DiffLine{eq("a"), del("b"), eq("c")}},
+		{"line replaced", "a\nb\nc", "a\nx\nc", []DiffLine{eq("a"), del("b"), ins("x"), eq("c")}},
+		{"moved line", "a\nb\nc", "b\nc\na", []DiffLine{del("a"), eq("b"), eq("c"), ins("a")}},
+		{"nothing shared", "a\nb", "c\nd", []DiffLine{del("a"), del("b"), ins("c"), ins("d")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffLines(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLines(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+// Random edits over a small alphabet of lines force plenty of repeats. Every
+// diff must rebuild both sides and keep as many lines as the longest common
+// subsequence.
+func TestDiffLinesIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		diff := DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		from, to, equal := rebuild(diff)
+		if from != strings.Join(a, "\n") || to != strings.Join(b, "\n") {
+			t.Fatalf("diff of %q and %q does not rebuild them: %v", a, b, diff)
+		}
+		if want := lcsLength(strings.Split(strings.Join(a, "\n"), "\n"), strings.Split(strings.Join(b, "\n"), "\n")); equal != want {
+			t.Fatalf("diff of %q and %q keeps %d lines, want %d", a, b, equal, want)
+		}
+	}
+}
+
+// Descriptions with no lines in common are cut off by the cost bound and
+// come back as a plain replacement
+func TestDiffLinesBoundsCost(t *testing.T) {
+	from := strings.Repeat("a\n", 5000)
+	to := strings.Repeat("b\n", 5000)
+	diff := DiffLines(from, to)
+
+	gotFrom, gotTo, _ := rebuild(diff)
+	if gotFrom != from || gotTo != to {
+		t.Fatal("diff does not rebuild its inputs")
+	}
+	if len(diff) != 10001 {
+		t.Errorf("len(diff) = %d, want 10001", len(diff))
+	}
+}
+
+func BenchmarkDiffLinesDisjoint(b *testing.B) {
+	from := strings.Repeat("a\n", 5000)
+	to := strings.Repeat("b\n", 5000)
+	for i := 0; i < b.N; i++ {
+		DiffLines(from, to)
+	}
+}
+
+func rebuild(diff []DiffLine) (from, to string, equal int) {
+	var a, b []string
+	for _, line := range diff {
+		switch line.Op {
+		case "equal":
+			a = append(a, line.Text)
+			b = append(b, line.Text)
+			equal++
+		case "delete":
+			a = append(a, line.Text)
+		case "insert":
+			b = append(b, line.Text)
+		}
+	}
+	return strings.Join(a, "\n"), strings.Join(b, "\n"), equal
+}
+
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,520 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxMarkdownLinks is how many links a description or comment may contain
+	MaxMarkdownLinks = 10
+	// MaxMarkdownNesting is how deeply lists and block quotes may be nested
+	MaxMarkdownNesting = 4
+
+	// maxMarkdownDepth stops the parser from recursing on hostile input; blocks
+	// nested deeper than this are rendered as plain paragraphs
+	maxMarkdownDepth = 16
+)
+
+var (
+	// ErrTooManyLinks is returned for Markdown with more than MaxMarkdownLinks links
+	ErrTooManyLinks = fmt.Errorf("Text cannot contain more than %d links", MaxMarkdownLinks)
+	// ErrNestedTooDeeply is returned for Markdown nested deeper than MaxMarkdownNesting
+	ErrNestedTooDeeply = fmt.Errorf("Lists and quotes cannot be nested more than %d levels deep", MaxMarkdownNesting)
+)
+
+// markdownAllowedTags is every tag the renderer may emit. Text is always escaped,
+// so these are the only elements that can appear in rendered output.
+var markdownAllowedTags = map[string]bool{
+	"p": true, "br": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"strong": true, "em": true, "del": true, "code": true, "pre": true,
+	"blockquote": true, "ul": true, "ol": true, "li": true, "a": true,
+}
+
+// markdownAllowedSchemes are the URL schemes links may use; relative URLs are not allowed
+var markdownAllowedSchemes = map[string]bool{
+	"http": true, "https": true, "mailto": true,
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t]*#*[ \t]*$`)
+	mdRule    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdFence   = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	mdQuote   = regexp.MustCompile(`^ {0,3}> ?`)
+	mdBullet  = regexp.MustCompile(`^( {0,3})([-*+])([ \t]+|$)`)
+	mdOrdered = regexp.MustCompile(`^( {0,3})(\d{1,9})[.)]([ \t]+|$)`)
+)
+
+// mdBlock is a parsed block of Markdown
+type mdBlock struct {
+	kind     string // "p", "h", "code", "quote", "ul", "ol", "li" or "hr"
+	level    int    // heading level
+	text     string // inline source of paragraphs and headings, raw text of code
+	children []*mdBlock
+}
+
+// markdownDoc is a parsed document and what the limits are checked against
+type markdownDoc struct {
+	blocks  []*mdBlock
+	nesting int // deepest list or quote nesting
+	links   int
+}
+
+// RenderMarkdown renders Markdown to safe HTML. Raw HTML in the source is
+// escaped, links are kept only with an allowed scheme and open with
+// rel="nofollow noopener noreferrer", and images render as plain text.
+func RenderMarkdown(src string) string {
+	doc := parseMarkdown(src)
+	var b strings.Builder
+	doc.renderBlocks(&b, doc.blocks)
+	return b.String()
+}
+
+// ValidateMarkdown checks Markdown against the link and nesting limits
+func ValidateMarkdown(src string) error {
+	doc := parseMarkdown(src)
+	var b strings.Builder
+	doc.renderBlocks(&b, doc.blocks) // counts the links
+	if doc.links > MaxMarkdownLinks {
+		return ErrTooManyLinks
+	}
+	if doc.nesting > MaxMarkdownNesting {
+		return ErrNestedTooDeeply
+	}
+	return nil
+}
+
+func parseMarkdown(src string) *markdownDoc {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	doc := &markdownDoc{}
+	doc.blocks = doc.parseBlocks(strings.Split(src, "\n"), 0)
+	return doc
+}
+
+// parseBlocks parses lines into blocks; depth is the list and quote nesting of the lines
+func (d *markdownDoc) parseBlocks(lines []string, depth int) []*mdBlock {
+	if depth > d.nesting {
+		d.nesting = depth
+	}
+
+	var blocks []*mdBlock
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, &mdBlock{kind: "p", text: strings.Join(para, "\n")})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case mdFence.MatchString(line):
+			flush()
+			fence := strings.TrimLeft(mdFence.FindString(line), " ")
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimLeft(lines[i], " "), fence) {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, &mdBlock{kind: "code", text: strings.Join(code, "\n")})
+
+		case mdHeading.MatchString(line):
+			flush()
+			m := mdHeading.FindStringSubmatch(line)
+			blocks = append(blocks, &mdBlock{kind: "h", level: len(m[1]), text: m[2]})
+
+		case mdRule.MatchString(line):
+			flush()
+			blocks = append(blocks, &mdBlock{kind: "hr"})
+
+		case depth < maxMarkdownDepth && mdQuote.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && mdQuote.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuote.ReplaceAllString(lines[i], ""))
+			}
+			i--
+			blocks = append(blocks, &mdBlock{kind: "quote", children: d.parseBlocks(quoted, depth+1)})
+
+		case depth < maxMarkdownDepth && listMarker(line) != nil:
+			flush()
+			var list *mdBlock
+			list, i = d.parseList(lines, i, depth)
+			i--
+			blocks = append(blocks, list)
+
+		default:
+			para = append(para, strings.TrimLeft(line, " \t"))
+		}
+	}
+	flush()
+	return blocks
+}
+
+// mdMarker is a list item marker found at the start of a line
+type mdMarker struct {
+	ordered bool
+	width   int // columns up to where the item's content starts
+}
+
+func listMarker(line string) *mdMarker {
+	if m := mdBullet.FindString(line); m != "" {
+		return &mdMarker{width: len(m)}
+	}
+	if m := mdOrdered.FindString(line); m != "" {
+		return &mdMarker{ordered: true, width: len(m)}
+	}
+	return nil
+}
+
+// parseList parses the list starting at lines[start] and returns it with the
+// index of the first line after it
+func (d *markdownDoc) parseList(lines []string, start int, depth int) (*mdBlock, int) {
+	first := listMarker(lines[start])
+	list := &mdBlock{kind: "ul"}
+	if first.ordered {
+		list.kind = "ol"
+	}
+
+	i := start
+	for i < len(lines) {
+		marker := listMarker(lines[i])
+		if marker == nil || marker.ordered != first.ordered {
+			break
+		}
+
+		// The item runs on while lines are indented past its marker; a blank
+		// line only continues it when an indented line follows
+		item := []string{lines[i][marker.width:]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				if i+1 < len(lines) && indentOf(lines[i+1]) >= marker.width {
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if indentOf(line) >= marker.width {
+				item = append(item, dedent(line, marker.width))
+				continue
+			}
+			if listMarker(line) != nil || mdQuote.MatchString(line) || mdFence.MatchString(line) ||
+				mdHeading.MatchString(line) || mdRule.MatchString(line) {
+				break
+			}
+			// Lazy continuation of the item's paragraph
+			item = append(item, line)
+		}
+		list.children = append(list.children, &mdBlock{kind: "li", children: d.parseBlocks(item, depth+1)})
+
+		// A single blank line may separate items
+		if i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && listMarker(lines[i+1]) != nil {
+			i++
+		}
+	}
+	return list, i
+}
+
+// dedent removes up to n columns of leading whitespace, with a tab counting as four
+func dedent(line string, n int) string {
+	for n > 0 && line != "" {
+		switch line[0] {
+		case ' ':
+			n--
+		case '\t':
+			n -= 4
+		default:
+			return line
+		}
+		line = line[1:]
+	}
+	return line
+}
+
+// indentOf counts leading spaces, with a tab counting as four
+func indentOf(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func (d *markdownDoc) renderBlocks(b *strings.Builder, blocks []*mdBlock) {
+	for _, block := range blocks {
+		switch block.kind {
+		case "p":
+			writeTag(b, "p", func() { d.renderInline(b, block.text, 0, false) })
+		case "h":
+			writeTag(b, fmt.Sprintf("h%d", block.level), func() { d.renderInline(b, block.text, 0, false) })
+		case "code":
+			writeTag(b, "pre", func() {
+				writeTag(b, "code", func() { b.WriteString(html.EscapeString(block.text)) })
+			})
+		case "hr":
+			b.WriteString("<hr>")
+		case "quote":
+			writeTag(b, "blockquote", func() { d.renderBlocks(b, block.children) })
+		case "ul", "ol":
+			writeTag(b, block.kind, func() {
+				for _, item := range block.children {
+					writeTag(b, "li", func() {
+						// A lone paragraph is rendered without <p>, as in a tight list
+						if len(item.children) == 1 && item.children[0].kind == "p" {
+							d.renderInline(b, item.children[0].text, 0, false)
+						} else {
+							d.renderBlocks(b, item.children)
+						}
+					})
+				}
+			})
+		}
+		b.WriteString("\n")
+	}
+}
+
+// writeTag writes content wrapped in an allowed tag, or just the content if the tag is not allowed
+func writeTag(b *strings.Builder, tag string, content func()) {
+	if !markdownAllowedTags[tag] {
+		content()
+		return
+	}
+	b.WriteString("<" + tag + ">")
+	content()
+	b.WriteString("</" + tag + ">")
+}
+
+// mdDelimiters are the emphasis delimiters, longest first
+var mdDelimiters = []struct{ delim, tag string }{
+	{"**", "strong"}, {"__", "strong"}, {"~~", "del"}, {"*", "em"}, {"_", "em"},
+}
+
+// renderInline renders the inline Markdown in s. inLink is set inside link text,
+// where nested links are kept as plain text.
+func (d *markdownDoc) renderInline(b *strings.Builder, s string, depth int, inLink bool) {
+	var match []int
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!~<>|", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == '`':
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			fence := s[i : i+run]
+			if end := strings.Index(s[i+run:], fence); end >= 0 {
+				code := strings.TrimSpace(s[i+run : i+run+end])
+				writeTag(b, "code", func() { b.WriteString(html.EscapeString(code)) })
+				i += run + end + run
+				continue
+			}
+			b.WriteString(fence)
+			i += run
+			continue
+
+		case c == '<':
+			// An autolink cannot contain whitespace, so the scan for its end
+			// stops there and at the next "<"
+			end := 1
+			for i+end < len(s) && strings.IndexByte(" \t\n<>", s[i+end]) < 0 {
+				end++
+			}
+			if i+end < len(s) && s[i+end] == '>' {
+				target := s[i+1 : i+end]
+				if href, ok := safeURL(target); ok {
+					d.links++
+					d.writeLink(b, href, func() { b.WriteString(html.EscapeString(target)) }, inLink)
+					i += end + 1
+					continue
+				}
+			}
+
+		case (c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '[')) && depth < maxMarkdownDepth:
+			image := c == '!'
+			open := i
+			if image {
+				open++
+			}
+			if match == nil {
+				match = matchBrackets(s)
+			}
+			if text, target, next, ok := parseLink(s, match, open); ok {
+				d.links++
+				if image {
+					// Images are not allowed; show their alt text instead
+					d.renderInline(b, text, depth+1, inLink)
+				} else if href, ok := safeURL(target); ok {
+					d.writeLink(b, href, func() { d.renderInline(b, text, depth+1, true) }, inLink)
+				} else {
+					d.renderInline(b, text, depth+1, inLink)
+				}
+				i = next
+				continue
+			}
+
+		case (c == '*' || c == '_' || c == '~') && depth < maxMarkdownDepth:
+			if n := d.renderEmphasis(b, s, i, depth, inLink); n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		// Copy plain text up to the next character that may start markup
+		j := i + 1
+		for j < len(s) && strings.IndexByte("\\\n`<[!*_~", s[j]) < 0 {
+			j++
+		}
+		b.WriteString(html.EscapeString(s[i:j]))
+		i = j
+	}
+}
+
+// renderEmphasis renders emphasis opening at s[i] and returns how many bytes it
+// used, or 0 if s[i] does not open emphasis
+func (d *markdownDoc) renderEmphasis(b *strings.Builder, s string, i int, depth int, inLink bool) int {
+	for _, m := range mdDelimiters {
+		if !strings.HasPrefix(s[i:], m.delim) {
+			continue
+		}
+		start := i + len(m.delim)
+		// Intraword underscores, as in snake_case, are not emphasis
+		if m.delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			return 0
+		}
+		if start >= len(s) || unicode.IsSpace(rune(s[start])) {
+			continue
+		}
+		end := closingDelimiter(s[start:], m.delim)
+		if end <= 0 || unicode.IsSpace(rune(s[start+end-1])) {
+			continue
+		}
+		inner := s[start : start+end]
+		writeTag(b, m.tag, func() { d.renderInline(b, inner, depth+1, inLink) })
+		return len(m.delim)*2 + end
+	}
+	return 0
+}
+
+// closingDelimiter finds where delim closes in s. A single * or _ does not close
+// on part of a doubled one, so "*a **b** c*" stays one emphasis.
+func closingDelimiter(s string, delim string) int {
+	for from := 0; from < len(s); {
+		end := strings.Index(s[from:], delim)
+		if end < 0 {
+			return -1
+		}
+		end += from
+		if len(delim) == 1 && end+1 < len(s) && s[end+1] == delim[0] {
+			from = end + 2
+			continue
+		}
+		return end
+	}
+	return -1
+}
+
+func (d *markdownDoc) writeLink(b *strings.Builder, href string, text func(), inLink bool) {
+	if inLink {
+		text()
+		return
+	}
+	b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">`)
+	text()
+	b.WriteString("</a>")
+}
+
+// matchBrackets pairs up the brackets in s: match[i] is the index of the "]"
+// closing a "[" at s[i], or of the ")" closing a "(" at s[i], and -1 when
+// there is none. Finding every pair in one pass keeps link parsing linear in
+// the length of s. Backslash-escaped square brackets are skipped.
+func matchBrackets(s string) []int {
+	match := make([]int, len(s))
+	var brackets, parens []int
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		match[i] = -1
+		switch c := s[i]; {
+		case c == '(':
+			parens = append(parens, i)
+		case c == ')' && len(parens) > 0:
+			match[parens[len(parens)-1]] = i
+			parens = parens[:len(parens)-1]
+		case escaped:
+		case c == '\\':
+			escaped = true
+			continue
+		case c == '[':
+			brackets = append(brackets, i)
+		case c == ']' && len(brackets) > 0:
+			match[brackets[len(brackets)-1]] = i
+			brackets = brackets[:len(brackets)-1]
+		}
+		escaped = false
+	}
+	return match
+}
+
+// parseLink parses "[text](target)" starting at the "[" at s[open], using the
+// bracket pairs from matchBrackets. It returns the link text, the target and
+// the index just past the closing parenthesis.
+func parseLink(s string, match []int, open int) (text, target string, next int, ok bool) {
+	close := match[open]
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return "", "", 0, false
+	}
+	// The target may contain balanced parentheses
+	end := match[close+1]
+	if end < 0 {
+		return "", "", 0, false
+	}
+	target = strings.TrimSpace(s[close+2 : end])
+	// Drop an optional title: [text](url "title")
+	if space := strings.IndexAny(target, " \t\n"); space >= 0 {
+		target = target[:space]
+	}
+	return s[open+1 : close], strings.Trim(target, "<>"), end + 1, true
+}
+
+// safeURL returns the normalized URL when it is absolute and uses an allowed scheme
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || !markdownAllowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if strings.ToLower(u.Scheme) != "mailto" && u.Host == "" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const linkAttrs = ` rel="nofollow noopener noreferrer"`
+
+var markdownXSSTests = []struct {
+	name string
+	src  string
+	want string
+}{
+	// Raw HTML is always escaped
+	{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+	{"event handler", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+	{"anchor with handler", `<a href="https://example.com" onclick="alert(1)">x</a>`,
+		"<p>&lt;a href=&#34;https://example.com&#34; onclick=&#34;alert(1)&#34;&gt;x&lt;/a&gt;</p>\n"},
+	{"html in emphasis", "**<i>bold</i>**", "<p><strong>&lt;i&gt;bold&lt;/i&gt;</strong></p>\n"},
+	{"html in heading", "# <h1>", "<h1>&lt;h1&gt;</h1>\n"},
+	{"html in code", "`<b>`", "<p><code>&lt;b&gt;</code></p>\n"},
+	{"html in code block", "```\n</code><script>\n```", "<pre><code>&lt;/code&gt;&lt;script&gt;</code></pre>\n"},
+
+	// Only absolute http, https and mailto links survive; the rest keep their text
+	{"javascript scheme", "[x](javascript:alert(1))", "<p>x</p>\n"},
+	{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+	{"padded scheme", "[x](  javascript:alert(1)  )", "<p>x</p>\n"},
+	{"entity encoded scheme", "[x](&#106;avascript:alert(1))", "<p>x</p>\n"},
+	{"entity inside scheme", "[x](java&#115;cript:alert(1))", "<p>x</p>\n"},
+	{"percent encoded scheme", "[x](%6Aavascript:alert(1))", "<p>x</p>\n"},
+	{"vbscript scheme", "[x](vbscript:msgbox)", "<p>x</p>\n"},
+	{"data url", "[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)", "<p>x</p>\n"},
+	{"relative url", "[x](/relative)", "<p>x</p>\n"},
+	{"protocol relative url", "[x](//evil.example)", "<p>x</p>\n"},
+	{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+
+	// Quotes and angle brackets cannot leave the href attribute
+	{"double quote in href", `[x](https://example.com/"onmouseover="alert(1))`,
+		`<p><a href="https://example.com/%22onmouseover=%22alert%281%29"` + linkAttrs + ">x</a></p>\n"},
+	{"single quote in href", "[x](https://example.com/'><script>)",
+		`<p><a href="https://example.com/%27%3E%3Cscript"` + linkAttrs + ">x</a></p>\n"},
+	{"ampersand in href", "[x](https://a.example?a=1&b=2)",
+		`<p><a href="https://a.example?a=1&amp;b=2"` + linkAttrs + ">x</a></p>\n"},
+
+	// Links, autolinks and images
+	{"link", "[*docs*](https://example.com \"title\")", `<p><a href="https://example.com"` + linkAttrs + "><em>docs</em></a></p>\n"},
+	{"parentheses in target", "[x](https://example.com/a(b)c)", `<p><a href="https://example.com/a(b)c"` + linkAttrs + ">x</a></p>\n"},
+	{"nested link", "[outer [inner](https://b.example)](https://a.example)",
+		`<p><a href="https://a.example"` + linkAttrs + ">outer inner</a></p>\n"},
+	{"autolink", "<https://example.com/a>", `<p><a href="https://example.com/a"` + linkAttrs + ">https://example.com/a</a></p>\n"},
+	{"mailto autolink", "<mailto:a@example.com>", `<p><a href="mailto:a@example.com"` + linkAttrs + ">mailto:a@example.com</a></p>\n"},
+	{"image", "![alt](https://example.com/x.png)", "<p>alt</p>\n"},
+	{"quote in image alt", `![alt" onerror="x](https://example.com/x.png)`, "<p>alt&#34; onerror=&#34;x</p>\n"},
+	{"unclosed image", "![![![", "<p>![![![</p>\n"},
+}
+
+func TestRenderMarkdownEscapesHTML(t *testing.T) {
+	for _, tt := range markdownXSSTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderMarkdown(tt.src); got != tt.want {
+				t.Errorf("RenderMarkdown(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+var (
+	renderedTag  = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)([^>]*)>`)
+	renderedHref = regexp.MustCompile(`^ href="(https?://|mailto:)[^"<>]*"` + linkAttrs + `$`)
+)
+
+// Whatever the input, the output only holds allowed tags, and the only
+// attributes are safe hrefs on links
+func TestRenderMarkdownOutputIsSafe(t *testing.T) {
+	inputs := []string{
+		"> <script>\n> - [a](javascript:x) <b onclick=x>",
+		"- **[a](https://a.example)**\n  - ~~<iframe>~~\n    > `</code>`",
+		"<https://a.example/\"><script>> <https://a.example/ x>",
+		"[a](https://a.example/\" onclick=\"x) [b](<javascript:x>)",
+		"*_~~[![a](https://b.example)](https://a.example)~~_*",
+		strings.Repeat("> ", 50) + "<script>",
+		strings.Repeat("[", 200) + "x" + strings.Repeat("](https://a.example)", 200),
+	}
+	for _, tt := range markdownXSSTests {
+		inputs = append(inputs, tt.src)
+	}
+
+	for _, src := range inputs {
+		out := RenderMarkdown(src)
+		for _, m := range renderedTag.FindAllStringSubmatch(out, -1) {
+			tag, attrs := m[2], m[3]
+			if !markdownAllowedTags[tag] {
+				t.Errorf("RenderMarkdown(%q) emitted <%s>", src, tag)
+			}
+			if attrs == "" {
+				continue
+			}
+			if tag != "a" || m[1] != "" || !renderedHref.MatchString(attrs) {
+				t.Errorf("RenderMarkdown(%q) emitted attributes %q on <%s%s>", src, attrs, m[1], tag)
+			}
+		}
+	}
+}
+
+func TestValidateMarkdownLimits(t *testing.T) {
+	links := func(n int) string {
+		return strings.Repeat("[a](https://a.example) ", n)
+	}
+	quotes := func(n int) string {
+		return strings.Repeat("> ", n) + "x"
+	}
+	list := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(strings.Repeat("  ", i) + "- item\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want error
+	}{
+		{"links at limit", links(MaxMarkdownLinks), nil},
+		{"too many links", links(MaxMarkdownLinks + 1), ErrTooManyLinks},
+		{"images count as links", links(MaxMarkdownLinks) + "![a](https://a.example/a.png)", ErrTooManyLinks},
+		{"autolinks count as links", links(MaxMarkdownLinks) + "<https://a.example>", ErrTooManyLinks},
+		{"unsafe links count as links", strings.Repeat("[a](javascript:x) ", MaxMarkdownLinks+1), ErrTooManyLinks},
+		{"quotes at limit", quotes(MaxMarkdownNesting), nil},
+		{"quotes too deep", quotes(MaxMarkdownNesting + 1), ErrNestedTooDeeply},
+		{"lists at limit", list(MaxMarkdownNesting), nil},
+		{"lists too deep", list(MaxMarkdownNesting + 1), ErrNestedTooDeeply},
+		{"quote in list", list(MaxMarkdownNesting) + strings.Repeat("  ", MaxMarkdownNesting) + "> x", ErrNestedTooDeeply},
+		{"far too deep", quotes(1000), ErrNestedTooDeeply},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateMarkdown(tt.src); got != tt.want {
+				t.Errorf("ValidateMarkdown() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Unclosed and deeply nested brackets used to make every "[" rescan the rest
+// of the text; rendering them should now grow linearly with their size.
+func BenchmarkRenderMarkdownHostile(b *testing.B) {
+	inputs := []struct {
+		name string
+		src  string
+	}{
+		{"plain", strings.Repeat("ab", 5000)},
+		{"unclosed images", strings.Repeat("![", 5000)},
+		{"unclosed targets", strings.Repeat("[a](", 2500)},
+		{"unclosed autolinks", strings.Repeat("<a", 5000)},
+		{"nested links", strings.Repeat("[", 500) + "x" + strings.Repeat("](https://a.example)", 500)},
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				RenderMarkdown(in.src)
+			}
+		})
+	}
+}
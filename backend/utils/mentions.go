@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// mentionToken matches what follows an @ that looks like a mention: a handle or an email address
+var mentionToken = regexp.MustCompile(`^[\w.+-]+(@[\w-]+(\.[\w-]+)+)?`)
+
+// MentionCandidate is a user who can be mentioned by name or email
+type MentionCandidate struct {
+	UserID int
+	Name   string
+	Email  string
+}
+
+// MentionMatch is a resolved @mention; Text is what followed the @
+type MentionMatch struct {
+	UserID int
+	Text   string
+}
+
+// FindMentions scans content for @name and @email mentions. Mentions matching a
+// candidate are resolved, at most once per user; anything else that looks like a
+// mention is returned as unresolved. An @ inside a word, like in an email
+// address written without a leading @, is not a mention.
+func FindMentions(content string, candidates []MentionCandidate) (resolved []MentionMatch, unresolved []string) {
+	// Longer names first, so "@Ann Lee" is not taken as a mention of "Ann"
+	byName := make([]MentionCandidate, len(candidates))
+	copy(byName, candidates)
+	sort.SliceStable(byName, func(i, j int) bool { return len(byName[i].Name) > len(byName[j].Name) })
+
+	seen := make(map[int]bool)
+	for i := 0; i < len(content); i++ {
+		if content[i] != '@' {
+			continue
+		}
+		if prev, _ := utf8.DecodeLastRuneInString(content[:i]); i > 0 && isMentionRune(prev) {
+			continue
+		}
+
+		rest := content[i+1:]
+		if c, text, ok := matchCandidate(rest, byName); ok {
+			if !seen[c.UserID] {
+				seen[c.UserID] = true
+				resolved = append(resolved, MentionMatch{UserID: c.UserID, Text: text})
+			}
+			i += len(text)
+			continue
+		}
+
+		if token := strings.TrimRight(mentionToken.FindString(rest), ".-"); token != "" {
+			unresolved = append(unresolved, token)
+			i += len(token)
+		}
+	}
+	return resolved, unresolved
+}
+
+// matchCandidate finds the candidate whose email or name starts text and ends at a word boundary
+func matchCandidate(text string, candidates []MentionCandidate) (MentionCandidate, string, bool) {
+	for _, c := range candidates {
+		if c.Email != "" && hasMentionPrefix(text, c.Email) {
+			return c, text[:len(c.Email)], true
+		}
+	}
+	for _, c := range candidates {
+		if c.Name != "" && hasMentionPrefix(text, c.Name) {
+			return c, text[:len(c.Name)], true
+		}
+	}
+	return MentionCandidate{}, "", false
+}
+
+func hasMentionPrefix(text, prefix string) bool {
+	if len(text) < len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(text[len(prefix):])
+	return len(text) == len(prefix) || !(isMentionRune(next) || next == '@')
+}
+
+func isMentionRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
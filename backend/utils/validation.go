@@ -2,9 +2,31 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+const (
+	// MaxAttachmentSize is the largest file that can be attached, in bytes
+	MaxAttachmentSize = 10 << 20
+	// MaxAttachments is how many files a feedback item, or a comment, can have
+	MaxAttachments = 5
+)
+
+// AttachmentTypes are the MIME types files may be attached with. SVG and HTML
+// are left out because browsers run scripts in them.
+var AttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
 func ValidateBoardName(name string) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -28,6 +50,12 @@ func ValidateFeedback(title, description string, categoryID int) error {
 	if description == "" {
 		return errors.New("Description cannot be empty")
 	}
+	if len(description) > 10000 {
+		return errors.New("Description cannot exceed 10000 characters")
+	}
+	if err := ValidateMarkdown(description); err != nil {
+		return err
+	}
 	if categoryID <= 0 {
 		return errors.New("Invalid category ID")
 	}
@@ -43,5 +71,40 @@ func ValidateComment(content string) error {
 	if len(content) > 1000 {
 		return errors.New("Comment cannot exceed 1000 characters")
 	}
+	return ValidateMarkdown(content)
+}
+
+// Validate an uploaded file; contentType is the type sniffed from its content
+func ValidateAttachment(filename, contentType string, size int64) error {
+	if strings.TrimSpace(filename) == "" {
+		return errors.New("File name cannot be empty")
+	}
+	if size == 0 {
+		return errors.New("File cannot be empty")
+	}
+	if size > MaxAttachmentSize {
+		return fmt.Errorf("File cannot exceed %d MB", MaxAttachmentSize>>20)
+	}
+	if !AttachmentTypes[contentType] {
+		return errors.New("File type is not allowed")
+	}
+	return nil
+}
+
+// Validate category fields
+func ValidateCategory(name, color, description string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("Category name cannot be empty")
+	}
+	if len(name) > 100 {
+		return errors.New("Category name cannot exceed 100 characters")
+	}
+	if !hexColor.MatchString(color) {
+		return errors.New("Color must be a hex value like #3b82f6")
+	}
+	if len(description) > 500 {
+		return errors.New("Category description cannot exceed 500 characters")
+	}
 	return nil
 }
@@ -1,12 +1,17 @@
 import React, { useState } from 'react';
+import { ATTACHMENT_TYPES, MAX_ATTACHMENTS, MAX_ATTACHMENT_SIZE } from '../services/attachmentService';
 
 interface AddCommentProps {
   feedbackId: number;
-  onAddComment: (content: string) => void;
+  onAddComment: (content: string, internal: boolean, files: File[]) => void;
+  // Stakeholders can post internal notes that other users never see
+  allowInternal?: boolean;
 }
 
-const AddComment: React.FC<AddCommentProps> = ({ feedbackId, onAddComment }) => {
+const AddComment: React.FC<AddCommentProps> = ({ feedbackId, onAddComment, allowInternal = false }) => {
   const [content, setContent] = useState('');
+  const [internal, setInternal] = useState(false);
+  const [files, setFiles] = useState<File[]>([]);
   const [error, setError] = useState('');
 
   const handleSubmit = (e: React.FormEvent) => {
@@ -17,8 +22,25 @@ const AddComment: React.FC<AddCommentProps> = ({ feedbackId, onAddComment }) =>
       return;
     }
     
-    onAddComment(content);
+    onAddComment(content, internal, files);
     setContent('');
+    setInternal(false);
+    setFiles([]);
+    setError('');
+  };
+
+  const handleFiles = (e: React.ChangeEvent<HTMLInputElement>) => {
+    const selected = Array.from(e.target.files ?? []);
+    e.target.value = '';
+    if (selected.length > MAX_ATTACHMENTS) {
+      setError(`You can attach up to ${MAX_ATTACHMENTS} files`);
+      return;
+    }
+    if (selected.some(f => f.size > MAX_ATTACHMENT_SIZE)) {
+      setError('Files cannot exceed 10 MB');
+      return;
+    }
+    setFiles(selected);
     setError('');
   };
 
@@ -33,12 +55,27 @@ const AddComment: React.FC<AddCommentProps> = ({ feedbackId, onAddComment }) =>
         rows={3}
       />
       {error && <p className="text-red-500 text-sm mt-1">{error}</p>}
-      <div className="flex justify-end mt-2">
+      <div className="flex justify-end items-center space-x-4 mt-2">
+        <label className="text-sm text-gray-600 mr-auto cursor-pointer">
+          <input type="file" multiple accept={ATTACHMENT_TYPES.join(',')} className="hidden" onChange={handleFiles} />
+          {files.length > 0 ? `${files.length} file(s) attached` : 'Attach files'}
+        </label>
+        {allowInternal && (
+          <label className="flex items-center text-sm text-gray-600">
+            <input
+              type="checkbox"
+              className="mr-2"
+              checked={internal}
+              onChange={(e) => setInternal(e.target.checked)}
+            />
+            Internal note (stakeholders only)
+          </label>
+        )}
         <button 
           type="submit"
           className="px-6 py-2 bg-blue-500 text-white rounded hover:bg-blue-600 transition"
         >
-          Comment
+          {internal ? 'Add note' : 'Comment'}
         </button>
       </div>
     </form>
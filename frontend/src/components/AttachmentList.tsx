@@ -0,0 +1,77 @@
+import React, { useEffect, useState } from 'react';
+import { Attachment, attachmentService } from '../services/attachmentService';
+
+interface AttachmentListProps {
+  attachments: Attachment[];
+  // Set when the viewer may remove attachments
+  onDelete?: (attachmentId: number) => void;
+}
+
+const formatSize = (size: number) =>
+  size < 1024 * 1024 ? `${Math.ceil(size / 1024)} KB` : `${(size / (1024 * 1024)).toFixed(1)} MB`;
+
+// Image thumbnails are fetched with the auth header and shown from object URLs
+const AttachmentList: React.FC<AttachmentListProps> = ({ attachments, onDelete }) => {
+  const [thumbnails, setThumbnails] = useState<Record<number, string>>({});
+
+  useEffect(() => {
+    let cancelled = false;
+    const urls: string[] = [];
+    attachments
+      .filter(a => a.contentType.startsWith('image/'))
+      .forEach(async a => {
+        try {
+          const url = await attachmentService.getObjectUrl(a.id);
+          urls.push(url);
+          if (!cancelled) {
+            setThumbnails(prev => ({ ...prev, [a.id]: url }));
+          }
+        } catch (err) {
+          console.error('Error loading attachment:', err);
+        }
+      });
+    return () => {
+      cancelled = true;
+      urls.forEach(url => URL.revokeObjectURL(url));
+    };
+  }, [attachments]);
+
+  const handleOpen = async (attachment: Attachment) => {
+    try {
+      const url = thumbnails[attachment.id] ?? (await attachmentService.getObjectUrl(attachment.id));
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = attachment.filename;
+      link.click();
+    } catch (err) {
+      console.error('Error downloading attachment:', err);
+    }
+  };
+
+  if (attachments.length === 0) {
+    return null;
+  }
+
+  return (
+    <div className="flex flex-wrap gap-2 my-2">
+      {attachments.map(attachment => (
+        <div key={attachment.id} className="border rounded p-2 text-sm flex items-center space-x-2">
+          {thumbnails[attachment.id] && (
+            <img src={thumbnails[attachment.id]} alt={attachment.filename} className="h-16 w-16 object-cover rounded" />
+          )}
+          <button onClick={() => handleOpen(attachment)} className="text-blue-600 hover:underline">
+            {attachment.filename}
+          </button>
+          <span className="text-gray-400">{formatSize(attachment.size)}</span>
+          {onDelete && (
+            <button onClick={() => onDelete(attachment.id)} className="text-red-500 hover:underline">
+              Remove
+            </button>
+          )}
+        </div>
+      ))}
+    </div>
+  );
+};
+
+export default AttachmentList;
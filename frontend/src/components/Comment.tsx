@@ -1,44 +1,108 @@
-import React, { useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
+import AttachmentList from './AttachmentList';
+import { Attachment } from '../services/attachmentService';
 
-interface User {
+// Matches the server's nesting limit; top-level comments have depth 0
+export const MAX_COMMENT_DEPTH = 8;
+
+export interface CommentAuthor {
   id: number;
   name: string;
+  picture: string;
+  badge?: 'admin' | 'stakeholder';
+}
+
+export interface CommentMention {
+  userId: number;
+  name: string;
+  text: string;
 }
 
-interface CommentReply {
+export interface CommentNode {
   id: number;
-  commentId: number;
+  feedbackId: number;
+  parentId: number | null;
   userId: number;
+  author: CommentAuthor | null;
   content: string;
+  contentHtml: string; // Sanitized HTML rendered by the server from the Markdown content
   likes: number;
   dislikes: number;
+  depth: number;
   createdAt: string;
-  isLiked: boolean;
-  isDisliked: boolean;
+  isLiked?: boolean;
+  isDisliked?: boolean;
+  editedAt?: string;
+  deleted?: boolean;
+  official?: boolean;
+  internal?: boolean;
+  mentions: CommentMention[];
+  replies: CommentNode[];
 }
 
+// Turn resolved @mentions in the rendered HTML into links; anything else stays plain text.
+// Only text nodes outside of links and code are touched, so the server's sanitized markup is kept.
+const linkMentions = (root: HTMLElement, mentions: CommentMention[]) => {
+  if (!mentions || mentions.length === 0) {
+    return;
+  }
+  const escape = (s: string) => s.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+  const byText = new Map(mentions.map(m => [m.text.toLowerCase(), m]));
+  const pattern = new RegExp(`(@(?:${mentions.map(m => escape(m.text)).join('|')}))`, 'gi');
+
+  const walker = document.createTreeWalker(root, NodeFilter.SHOW_TEXT);
+  const textNodes: Text[] = [];
+  while (walker.nextNode()) {
+    const node = walker.currentNode as Text;
+    if (!node.parentElement?.closest('a, code')) {
+      textNodes.push(node);
+    }
+  }
+
+  textNodes.forEach(node => {
+    const parts = (node.textContent ?? '').split(pattern);
+    if (parts.length === 1) {
+      return;
+    }
+    const fragment = document.createDocumentFragment();
+    parts.forEach(part => {
+      const mention = part.startsWith('@') ? byText.get(part.slice(1).toLowerCase()) : undefined;
+      if (!mention) {
+        fragment.appendChild(document.createTextNode(part));
+        return;
+      }
+      const link = document.createElement('a');
+      link.href = `#user-${mention.userId}`;
+      link.title = mention.name;
+      link.className = 'text-blue-600 font-medium hover:underline';
+      link.textContent = part;
+      fragment.appendChild(link);
+    });
+    node.replaceWith(fragment);
+  });
+};
+
 interface CommentProps {
-  comment: {
-    id: number;
-    feedbackId: number;
-    userId: number;
-    content: string;
-    likes: number;
-    dislikes: number;
-    createdAt: string;
-    isLiked: boolean;
-    isDisliked: boolean;
-    replies: CommentReply[];
-  };
+  comment: CommentNode;
   onLike: (commentId: number, isLike: boolean) => void;
-  onReplyLike: (replyId: number, isLike: boolean) => void;
-  onAddReply: (commentId: number, content: string) => void;
+  onAddReply: (parentId: number, content: string) => void;
+  // Set for stakeholders, who can pin a comment as the official response
+  onPin?: (commentId: number, pinned: boolean) => void;
+  // Files attached to each comment, by comment ID
+  attachments?: Record<number, Attachment[]>;
 }
 
-const Comment: React.FC<CommentProps> = ({ comment, onLike, onReplyLike, onAddReply }) => {
+const Comment: React.FC<CommentProps> = ({ comment, onLike, onAddReply, onPin, attachments }) => {
   const [showReplyForm, setShowReplyForm] = useState(false);
   const [replyContent, setReplyContent] = useState('');
   const [error, setError] = useState('');
+  const contentRef = useRef<HTMLDivElement>(null);
+
+  useEffect(() => {
+    if (contentRef.current) {
+      linkMentions(contentRef.current, comment.mentions);
+    }
+  }, [comment.contentHtml, comment.mentions]);
 
   const handleSubmitReply = (e: React.FormEvent) => {
     e.preventDefault();
@@ -54,14 +118,62 @@ const Comment: React.FC<CommentProps> = ({ comment, onLike, onReplyLike, onAddRe
     setError('');
   };
 
+  const isReply = comment.depth > 0;
+
+  const renderReplies = () => {
+    if (!comment.replies || comment.replies.length === 0) {
+      return null;
+    }
+    return (
+      <div className="mt-4 pl-6 border-l-2 border-gray-200">
+        {comment.replies.map(reply => (
+          <Comment key={reply.id} comment={reply} onLike={onLike} onAddReply={onAddReply} onPin={onPin} attachments={attachments} />
+        ))}
+      </div>
+    );
+  };
+
+  if (comment.deleted) {
+    return (
+      <div className={isReply ? 'bg-gray-50 rounded-lg p-3 my-2' : 'bg-white rounded-lg shadow p-4 my-4'}>
+        <p className="italic text-gray-400">This comment was deleted</p>
+        {renderReplies()}
+      </div>
+    );
+  }
+
   return (
-    <div className="bg-white rounded-lg shadow p-4 my-4">
+    <div className={isReply ? 'bg-gray-50 rounded-lg p-3 my-2' : 'bg-white rounded-lg shadow p-4 my-4'}>
       <div className="flex justify-between items-start">
-        <p className="font-semibold">User {comment.userId}</p>
-        <span className="text-sm text-gray-500">{new Date(comment.createdAt).toLocaleString()}</span>
+        <div className="flex items-center space-x-2">
+          {comment.author?.picture && (
+            <img src={comment.author.picture} alt="" className="w-6 h-6 rounded-full" />
+          )}
+          <p className="font-semibold">{comment.author?.name ?? `User ${comment.userId}`}</p>
+          {comment.author?.badge && (
+            <span className="text-xs uppercase bg-blue-100 text-blue-800 px-2 py-0.5 rounded">
+              {comment.author.badge}
+            </span>
+          )}
+          {comment.internal && (
+            <span className="text-xs bg-yellow-100 text-yellow-800 px-2 py-0.5 rounded">Internal</span>
+          )}
+          {comment.official && (
+            <span className="text-xs bg-green-100 text-green-800 px-2 py-0.5 rounded">Official response</span>
+          )}
+        </div>
+        <span className="text-sm text-gray-500">
+          {new Date(comment.createdAt).toLocaleString()}
+          {comment.editedAt && ' (edited)'}
+        </span>
       </div>
       
-      <p className="my-2">{comment.content}</p>
+      {comment.contentHtml ? (
+        <div ref={contentRef} className="my-2 markdown" dangerouslySetInnerHTML={{ __html: comment.contentHtml }} />
+      ) : (
+        <p className="my-2 whitespace-pre-wrap">{comment.content}</p>
+      )}
+      <AttachmentList attachments={attachments?.[comment.id] ?? []} />
       
       <div className="flex space-x-4">
         <button 
@@ -84,12 +196,23 @@ const Comment: React.FC<CommentProps> = ({ comment, onLike, onReplyLike, onAddRe
           {comment.dislikes}
         </button>
         
-        <button 
-          className="text-blue-500"
-          onClick={() => setShowReplyForm(!showReplyForm)}
-        >
-          Reply
-        </button>
+        {comment.depth < MAX_COMMENT_DEPTH && (
+          <button 
+            className="text-blue-500"
+            onClick={() => setShowReplyForm(!showReplyForm)}
+          >
+            Reply
+          </button>
+        )}
+        
+        {onPin && !comment.internal && (
+          <button 
+            className="text-gray-500"
+            onClick={() => onPin(comment.id, !comment.official)}
+          >
+            {comment.official ? 'Unpin' : 'Pin as official response'}
+          </button>
+        )}
       </div>
       
       {showReplyForm && (
@@ -123,37 +246,7 @@ const Comment: React.FC<CommentProps> = ({ comment, onLike, onReplyLike, onAddRe
       )}
       
       {/* Replies */}
-      <div className="mt-4 pl-6 border-l-2 border-gray-200">
-        {comment.replies && comment.replies.map(reply => (
-          <div key={reply.id} className="bg-gray-50 rounded-lg p-3 my-2">
-            <div className="flex justify-between items-start">
-              <p className="font-semibold">User {reply.userId}</p>
-              <span className="text-sm text-gray-500">{new Date(reply.createdAt).toLocaleString()}</span>
-            </div>
-            <p className="my-2">{reply.content}</p>
-            <div className="flex space-x-4">
-              <button 
-                className={`flex items-center ${reply.isLiked ? 'text-blue-500' : 'text-gray-500'}`}
-                onClick={() => onReplyLike(reply.id, true)}
-              >
-                <svg xmlns="http://www.w3.org/2000/svg" className="h-5 w-5 mr-1" viewBox="0 0 20 20" fill="currentColor">
-                  <path d="M2 10.5a1.5 1.5 0 113 0v6a1.5 1.5 0 01-3 0v-6zM6 10.333v5.43a2 2 0 001.106 1.79l.05.025A4 4 0 008.943 18h5.416a2 2 0 001.962-1.608l1.2-6A2 2 0 0015.56 8H12V4a2 2 0 00-2-2 1 1 0 00-1 1v.667a4 4 0 01-.8 2.4L6.8 7.933a4 4 0 00-.8 2.4z" />
-                </svg>
-                {reply.likes}
-              </button>
-              <button 
-                className={`flex items-center ${reply.isDisliked ? 'text-red-500' : 'text-gray-500'}`}
-                onClick={() => onReplyLike(reply.id, false)}
-              >
-                <svg xmlns="http://www.w3.org/2000/svg" className="h-5 w-5 mr-1" viewBox="0 0 20 20" fill="currentColor">
-                  <path d="M18 9.5a1.5 1.5 0 11-3 0v-6a1.5 1.5 0 013 0v6zM14 9.667v-5.43a2 2 0 00-1.105-1.79l-.05-.025A4 4 0 0011.055 2H5.64a2 2 0 00-1.962 1.608l-1.2 6A2 2 0 004.44 12H8v4a2 2 0 002 2 1 1 0 001-1v-.667a4 4 0 01.8-2.4l1.4-1.866a4 4 0 00.8-2.4z" />
-                </svg>
-                {reply.dislikes}
-              </button>
-            </div>
-          </div>
-        ))}
-      </div>
+      {renderReplies()}
     </div>
   );
 };
@@ -1,53 +1,79 @@
 import React, { useState, useEffect } from 'react';
-import Comment from './Comment';
+import Comment, { CommentNode } from './Comment';
 import AddComment from './AddComment';
 import { environment } from '../environments/environment';
+import { feedbackService } from '../services/feedbackService';
+import { Attachment, attachmentService } from '../services/attachmentService';
 
-interface CommentReply {
-  id: number;
-  commentId: number;
-  userId: number;
-  content: string;
-  likes: number;
-  dislikes: number;
-  createdAt: string;
-  isLiked: boolean;
-  isDisliked: boolean;
-}
+// Apply update to the comment with the given id anywhere in the tree
+const updateInTree = (
+  comments: CommentNode[],
+  id: number,
+  update: (comment: CommentNode) => CommentNode
+): CommentNode[] =>
+  comments.map(comment => {
+    if (comment.id === id) {
+      return update(comment);
+    }
+    return { ...comment, replies: updateInTree(comment.replies, id, update) };
+  });
 
-interface CommentType {
-  id: number;
-  feedbackId: number;
-  userId: number;
-  content: string;
-  likes: number;
-  dislikes: number;
-  createdAt: string;
-  isLiked: boolean;
-  isDisliked: boolean;
-  replies: CommentReply[];
-}
+type CommentSort = 'new' | 'old' | 'top';
 
 interface CommentSectionProps {
   feedbackId: number;
+  // Stakeholders and admins can pin official responses and post internal notes
+  isStakeholder?: boolean;
+  // Changes when others comment or react, to reload the threads
+  refreshToken?: number;
 }
 
-const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
-  const [comments, setComments] = useState<CommentType[]>([]);
+const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId, isStakeholder = false, refreshToken = 0 }) => {
+  const [comments, setComments] = useState<CommentNode[]>([]);
+  const [officialResponse, setOfficialResponse] = useState<CommentNode | null>(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
+  const [sort, setSort] = useState<CommentSort>('new');
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
+  const [truncated, setTruncated] = useState(false);
+  const [attachments, setAttachments] = useState<Record<number, Attachment[]>>({});
 
-  const fetchComments = async () => {
+  // Group the feedback item's comment attachments by comment
+  const fetchAttachments = async () => {
+    try {
+      const byComment: Record<number, Attachment[]> = {};
+      (await attachmentService.getFeedbackAttachments(feedbackId)).forEach(a => {
+        if (a.commentId) {
+          byComment[a.commentId] = [...(byComment[a.commentId] ?? []), a];
+        }
+      });
+      setAttachments(byComment);
+    } catch (err) {
+      console.error('Error fetching attachments:', err);
+    }
+  };
+
+  // Fetch the first page of threads, or the page after cursor when loading more
+  const fetchComments = async (cursor?: string) => {
     try {
       setLoading(true);
-      const res = await fetch(`${environment.apiUrl}/comments?feedbackId=${feedbackId}`);
+      const params = new URLSearchParams({ feedbackId: String(feedbackId), sort });
+      if (cursor) {
+        params.set('cursor', cursor);
+      }
+      const res = await fetch(`${environment.apiUrl}/comments?${params}`);
       
       if (!res.ok) {
         throw new Error('Failed to fetch comments');
       }
       
       const data = await res.json();
-      setComments(data);
+      setComments(prev => (cursor ? [...prev, ...data.comments] : data.comments));
+      if (!cursor) {
+        setOfficialResponse(data.officialResponse ?? null);
+      }
+      setNextCursor(data.nextCursor);
+      setTruncated(prev => (cursor ? prev : false) || Boolean(data.truncated));
       setError('');
     } catch (err) {
       setError('Error loading comments');
@@ -59,9 +85,13 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
 
   useEffect(() => {
     fetchComments();
-  }, [feedbackId]);
+  }, [feedbackId, sort, refreshToken]);
 
-  const handleAddComment = async (content: string) => {
+  useEffect(() => {
+    fetchAttachments();
+  }, [feedbackId, refreshToken]);
+
+  const handleAddComment = async (content: string, internal: boolean, files: File[]) => {
     try {
       const res = await fetch(`${environment.apiUrl}/comment`, {
         method: 'POST',
@@ -71,6 +101,7 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
         body: JSON.stringify({
           feedbackId,
           content,
+          internal,
         }),
       });
       
@@ -78,23 +109,32 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
         throw new Error('Failed to add comment');
       }
       
+      const { id } = await res.json();
+      for (const file of files) {
+        await attachmentService.uploadToComment(id, file);
+      }
+      
       // Refresh comments after adding
       fetchComments();
+      if (files.length > 0) {
+        fetchAttachments();
+      }
     } catch (err) {
-      setError('Error adding comment');
+      setError(files.length > 0 ? 'Error adding comment or its attachments' : 'Error adding comment');
       console.error('Error adding comment:', err);
     }
   };
 
-  const handleAddReply = async (commentId: number, content: string) => {
+  const handleAddReply = async (parentId: number, content: string) => {
     try {
-      const res = await fetch(`${environment.apiUrl}/reply`, {
+      const res = await fetch(`${environment.apiUrl}/comment`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
         },
         body: JSON.stringify({
-          commentId,
+          feedbackId,
+          parentId,
           content,
         }),
       });
@@ -111,6 +151,20 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
     }
   };
 
+  const handlePin = async (commentId: number, pinned: boolean) => {
+    try {
+      if (pinned) {
+        await feedbackService.pinOfficialResponse(feedbackId, commentId);
+      } else {
+        await feedbackService.unpinOfficialResponse(feedbackId);
+      }
+      fetchComments();
+    } catch (err) {
+      setError('Error updating official response');
+      console.error('Error updating official response:', err);
+    }
+  };
+
   const handleLikeComment = async (commentId: number, isLike: boolean) => {
     try {
       const res = await fetch(`${environment.apiUrl}/comment-like`, {
@@ -130,43 +184,40 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
       
       // Update local state to show immediate feedback
       setComments(prevComments => 
-        prevComments.map(comment => {
-          if (comment.id === commentId) {
-            let likes = comment.likes;
-            let dislikes = comment.dislikes;
-            
-            // Logic to update like/dislike counts
-            if (comment.isLiked && isLike) {
-              // User is un-liking (toggling off)
-              likes--;
-            } else if (comment.isDisliked && !isLike) {
-              // User is un-disliking (toggling off)
-              dislikes--;
-            } else if (comment.isLiked && !isLike) {
-              // User is switching from like to dislike
-              likes--;
-              dislikes++;
-            } else if (comment.isDisliked && isLike) {
-              // User is switching from dislike to like
-              dislikes--;
-              likes++;
-            } else if (isLike) {
-              // New like
-              likes++;
-            } else {
-              // New dislike
-              dislikes++;
-            }
-            
-            return {
-              ...comment,
-              likes,
-              dislikes,
-              isLiked: comment.isLiked === isLike ? !comment.isLiked : isLike,
-              isDisliked: comment.isDisliked === !isLike ? !comment.isDisliked : !isLike
-            };
+        updateInTree(prevComments, commentId, comment => {
+          let likes = comment.likes;
+          let dislikes = comment.dislikes;
+          
+          // Logic to update like/dislike counts
+          if (comment.isLiked && isLike) {
+            // User is un-liking (toggling off)
+            likes--;
+          } else if (comment.isDisliked && !isLike) {
+            // User is un-disliking (toggling off)
+            dislikes--;
+          } else if (comment.isLiked && !isLike) {
+            // User is switching from like to dislike
+            likes--;
+            dislikes++;
+          } else if (comment.isDisliked && isLike) {
+            // User is switching from dislike to like
+            dislikes--;
+            likes++;
+          } else if (isLike) {
+            // New like
+            likes++;
+          } else {
+            // New dislike
+            dislikes++;
           }
-          return comment;
+          
+          return {
+            ...comment,
+            likes,
+            dislikes,
+            isLiked: comment.isLiked === isLike ? !comment.isLiked : isLike,
+            isDisliked: comment.isDisliked === !isLike ? !comment.isDisliked : !isLike
+          };
         })
       );
     } catch (err) {
@@ -175,80 +226,24 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
     }
   };
 
-  const handleLikeReply = async (replyId: number, isLike: boolean) => {
-    try {
-      const res = await fetch(`${environment.apiUrl}/comment-like`, {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-        },
-        body: JSON.stringify({
-          replyId,
-          isLike,
-        }),
-      });
-      
-      if (!res.ok) {
-        throw new Error('Failed to like reply');
-      }
-      
-      // Update local state to show immediate feedback
-      setComments(prevComments => 
-        prevComments.map(comment => ({
-          ...comment,
-          replies: comment.replies.map(reply => {
-            if (reply.id === replyId) {
-              let likes = reply.likes;
-              let dislikes = reply.dislikes;
-              
-              // Logic to update like/dislike counts
-              if (reply.isLiked && isLike) {
-                // User is un-liking (toggling off)
-                likes--;
-              } else if (reply.isDisliked && !isLike) {
-                // User is un-disliking (toggling off)
-                dislikes--;
-              } else if (reply.isLiked && !isLike) {
-                // User is switching from like to dislike
-                likes--;
-                dislikes++;
-              } else if (reply.isDisliked && isLike) {
-                // User is switching from dislike to like
-                dislikes--;
-                likes++;
-              } else if (isLike) {
-                // New like
-                likes++;
-              } else {
-                // New dislike
-                dislikes++;
-              }
-              
-              return {
-                ...reply,
-                likes,
-                dislikes,
-                isLiked: reply.isLiked === isLike ? !reply.isLiked : isLike,
-                isDisliked: reply.isDisliked === !isLike ? !reply.isDisliked : !isLike
-              };
-            }
-            return reply;
-          })
-        }))
-      );
-    } catch (err) {
-      setError('Error liking reply');
-      console.error('Error liking reply:', err);
-    }
-  };
-
   if (loading && comments.length === 0) {
     return <div className="text-center py-8">Loading comments...</div>;
   }
 
   return (
     <div className="mt-8">
-      <h2 className="text-xl font-bold mb-4">Comments ({comments.length})</h2>
+      <div className="flex justify-between items-center mb-4">
+        <h2 className="text-xl font-bold">Comments</h2>
+        <select
+          value={sort}
+          onChange={e => setSort(e.target.value as CommentSort)}
+          className="border rounded px-2 py-1 text-sm"
+        >
+          <option value="new">Newest</option>
+          <option value="old">Oldest</option>
+          <option value="top">Top</option>
+        </select>
+      </div>
       
       {error && (
         <div className="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">
@@ -256,7 +251,19 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
         </div>
       )}
       
-      <AddComment feedbackId={feedbackId} onAddComment={handleAddComment} />
+      {officialResponse && (
+        <div className="border-2 border-green-500 rounded-lg mb-4">
+          <Comment
+            comment={officialResponse}
+            onLike={handleLikeComment}
+            onAddReply={handleAddReply}
+            onPin={isStakeholder ? handlePin : undefined}
+            attachments={attachments}
+          />
+        </div>
+      )}
+      
+      <AddComment feedbackId={feedbackId} onAddComment={handleAddComment} allowInternal={isStakeholder} />
       
       <div className="space-y-4">
         {comments.map(comment => (
@@ -264,10 +271,27 @@ const CommentSection: React.FC<CommentSectionProps> = ({ feedbackId }) => {
             key={comment.id} 
             comment={comment} 
             onLike={handleLikeComment}
-            onReplyLike={handleLikeReply}
             onAddReply={handleAddReply}
+            onPin={isStakeholder ? handlePin : undefined}
+            attachments={attachments}
           />
         ))}
+
+        {truncated && (
+          <div className="text-sm text-gray-500 text-center">
+            Some replies in a long thread are not shown.
+          </div>
+        )}
+        
+        {nextCursor && (
+          <button
+            onClick={() => fetchComments(nextCursor)}
+            disabled={loading}
+            className="w-full py-2 text-blue-600 hover:underline disabled:text-gray-400"
+          >
+            {loading ? 'Loading...' : 'Load more comments'}
+          </button>
+        )}
         
         {comments.length === 0 && !loading && (
           <div className="text-gray-500 text-center py-8">
@@ -0,0 +1,75 @@
+import React, { useEffect, useState } from 'react';
+import AttachmentList from './AttachmentList';
+import { ATTACHMENT_TYPES, Attachment, MAX_ATTACHMENT_SIZE, attachmentService } from '../services/attachmentService';
+
+interface FeedbackAttachmentsProps {
+  feedbackId: number;
+  // Set for the people who can edit the feedback item
+  canEdit?: boolean;
+}
+
+// Files attached to the feedback item itself; comment attachments are shown with their comments
+const FeedbackAttachments: React.FC<FeedbackAttachmentsProps> = ({ feedbackId, canEdit = false }) => {
+  const [attachments, setAttachments] = useState<Attachment[]>([]);
+  const [uploading, setUploading] = useState(false);
+  const [error, setError] = useState('');
+
+  const fetchAttachments = async () => {
+    try {
+      const all = await attachmentService.getFeedbackAttachments(feedbackId);
+      setAttachments(all.filter(a => !a.commentId));
+    } catch (err) {
+      console.error('Error fetching attachments:', err);
+    }
+  };
+
+  useEffect(() => {
+    fetchAttachments();
+  }, [feedbackId]);
+
+  const handleUpload = async (e: React.ChangeEvent<HTMLInputElement>) => {
+    const file = e.target.files?.[0];
+    e.target.value = '';
+    if (!file) {
+      return;
+    }
+    if (file.size > MAX_ATTACHMENT_SIZE) {
+      setError('Files cannot exceed 10 MB');
+      return;
+    }
+    try {
+      setUploading(true);
+      await attachmentService.uploadToFeedback(feedbackId, file);
+      setError('');
+      fetchAttachments();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Error uploading file');
+    } finally {
+      setUploading(false);
+    }
+  };
+
+  const handleDelete = async (attachmentId: number) => {
+    try {
+      await attachmentService.deleteAttachment(attachmentId);
+      setAttachments(prev => prev.filter(a => a.id !== attachmentId));
+    } catch (err) {
+      setError('Error removing file');
+    }
+  };
+
+  return (
+    <div className="mb-4">
+      <AttachmentList attachments={attachments} onDelete={canEdit ? handleDelete : undefined} />
+      {canEdit && (
+        <label className="text-sm text-blue-600 hover:underline cursor-pointer">
+          <input type="file" accept={ATTACHMENT_TYPES.join(',')} className="hidden" onChange={handleUpload} disabled={uploading} />
+          {uploading ? 'Uploading...' : 'Attach a file'}
+        </label>
+      )}
+      {error && <p className="text-red-500 text-sm mt-1">{error}</p>}
+    </div>
+  );
+};
+
+export default FeedbackAttachments;
@@ -10,6 +10,10 @@ interface FeedbackCardProps {
     categoryId?: number;
     status?: string;
     userVote?: 'upvote' | 'downvote' | null | undefined;
+    officialResponse?: {
+      content: string;
+      author: { name: string } | null;
+    };
   };
   category?: {
     id: number;
@@ -29,6 +33,14 @@ function FeedbackCard({ feedback, category, onVote, onViewMore }: FeedbackCardPr
           {category.name}
         </span>
       )}
+      {feedback.officialResponse && (
+        <div className="mt-2 border-l-4 border-blue-500 bg-blue-50 px-3 py-2 text-sm">
+          <span className="font-semibold text-blue-800">
+            Official response{feedback.officialResponse.author && ` from ${feedback.officialResponse.author.name}`}:
+          </span>{' '}
+          {feedback.officialResponse.content.substring(0, 100)}
+        </div>
+      )}
       <div className="flex space-x-2 mt-2">
         <button
           onClick={() => onVote(feedback.id, 'upvote')}
@@ -0,0 +1,161 @@
+import React, { useEffect, useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { EmailDelivery, EmailPreferences, Notification, notificationService } from '../services/notificationService';
+
+const POLL_INTERVAL_MS = 60000;
+
+const describe = (n: Notification): string => {
+  const actor = n.actorName || 'Someone';
+  const title = n.feedbackTitle ? `"${n.feedbackTitle}"` : 'a post';
+  switch (n.type) {
+    case 'mention':
+      return `${actor} mentioned you on ${title}`;
+    case 'reply':
+      return `${actor} replied to your comment on ${title}`;
+    case 'status_change':
+      return `${title} was marked ${n.status}`;
+    case 'merged':
+      return `${title}, which you voted on, was merged into another post`;
+    case 'completed':
+      return `${title}, which you voted on, was completed`;
+    default:
+      return title;
+  }
+};
+
+// Inbox button with an unread badge; the list loads when opened
+const NotificationBell: React.FC = () => {
+  const navigate = useNavigate();
+  const [open, setOpen] = useState(false);
+  const [notifications, setNotifications] = useState<Notification[]>([]);
+  const [unreadCount, setUnreadCount] = useState(0);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
+  const [emailPreferences, setEmailPreferences] = useState<EmailPreferences | null>(null);
+
+  const fetchNotifications = async (cursor?: string) => {
+    try {
+      const page = await notificationService.getNotifications(cursor);
+      setNotifications(prev => (cursor ? [...prev, ...page.notifications] : page.notifications));
+      setUnreadCount(page.unreadCount);
+      setNextCursor(page.nextCursor);
+    } catch (err) {
+      console.error('Error fetching notifications:', err);
+    }
+  };
+
+  useEffect(() => {
+    if (open && !emailPreferences) {
+      notificationService.getEmailPreferences()
+        .then(setEmailPreferences)
+        .catch(err => console.error('Error fetching email preferences:', err));
+    }
+  }, [open]);
+
+  useEffect(() => {
+    fetchNotifications();
+    const timer = setInterval(() => fetchNotifications(), POLL_INTERVAL_MS);
+    return () => clearInterval(timer);
+  }, []);
+
+  const handleClick = async (n: Notification) => {
+    if (!n.readAt) {
+      try {
+        setUnreadCount(await notificationService.markRead(n.id));
+        setNotifications(prev => prev.map(p => (p.id === n.id ? { ...p, readAt: new Date().toISOString() } : p)));
+      } catch (err) {
+        console.error('Error marking notification read:', err);
+      }
+    }
+    if (n.boardId) {
+      navigate(`/boards/${n.boardId}`);
+    }
+  };
+
+  const handleMarkAllRead = async () => {
+    try {
+      setUnreadCount(await notificationService.markAllRead());
+      const now = new Date().toISOString();
+      setNotifications(prev => prev.map(p => ({ ...p, readAt: p.readAt || now })));
+    } catch (err) {
+      console.error('Error marking notifications read:', err);
+    }
+  };
+
+  const handleEmailDeliveryChange = async (delivery: EmailDelivery) => {
+    try {
+      await notificationService.updateEmailDelivery(delivery);
+      setEmailPreferences(prev => (prev ? { ...prev, delivery } : prev));
+    } catch (err) {
+      console.error('Error updating email preferences:', err);
+    }
+  };
+
+  return (
+    <div className="relative mr-4">
+      <button
+        onClick={() => setOpen(!open)}
+        className="relative px-3 py-2 text-sm text-gray-600 hover:text-gray-800 border border-gray-300 rounded"
+      >
+        Notifications
+        {unreadCount > 0 && (
+          <span className="absolute -top-2 -right-2 px-1.5 text-xs font-semibold text-white bg-red-500 rounded-full">
+            {unreadCount}
+          </span>
+        )}
+      </button>
+
+      {open && (
+        <div className="absolute right-0 mt-2 w-80 bg-white border border-gray-200 rounded-md shadow-lg z-10">
+          <div className="flex justify-between items-center px-4 py-2 border-b">
+            <span className="text-sm font-semibold text-gray-700">Notifications</span>
+            {unreadCount > 0 && (
+              <button onClick={handleMarkAllRead} className="text-xs text-blue-600 hover:underline">
+                Mark all as read
+              </button>
+            )}
+          </div>
+          <ul className="max-h-96 overflow-y-auto">
+            {notifications.length === 0 && (
+              <li className="px-4 py-3 text-sm text-gray-500">No notifications yet</li>
+            )}
+            {notifications.map(n => (
+              <li
+                key={n.id}
+                onClick={() => handleClick(n)}
+                className={`px-4 py-3 text-sm cursor-pointer hover:bg-gray-50 ${n.readAt ? 'text-gray-500' : 'text-gray-800 bg-blue-50'}`}
+              >
+                <div>{describe(n)}</div>
+                <div className="text-xs text-gray-400 mt-1">{new Date(n.createdAt).toLocaleString()}</div>
+              </li>
+            ))}
+          </ul>
+          {nextCursor && (
+            <button
+              onClick={() => fetchNotifications(nextCursor)}
+              className="w-full px-4 py-2 text-xs text-blue-600 hover:underline border-t"
+            >
+              Load more
+            </button>
+          )}
+          {emailPreferences?.available && (
+            <div className="flex justify-between items-center px-4 py-2 border-t text-xs text-gray-600">
+              <label htmlFor="emailDelivery">Email me</label>
+              <select
+                id="emailDelivery"
+                value={emailPreferences.delivery}
+                onChange={e => handleEmailDeliveryChange(e.target.value as EmailDelivery)}
+                className="border border-gray-300 rounded px-1 py-0.5"
+              >
+                <option value="immediate">Right away</option>
+                <option value="digest">Daily digest</option>
+                <option value="off">Never</option>
+              </select>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default NotificationBell;
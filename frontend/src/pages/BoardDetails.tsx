@@ -4,9 +4,11 @@ import { authService } from '../services/authService';
 import { feedbackService, Feedback } from '../services/feedbackService';
 import { categoryService, Category } from '../services/categoryService';
 import { boardService, BoardMember } from '../services/boardService';
+import { subscribeToBoard } from '../services/boardEvents';
 import FeedbackForm from '../components/FeedbackForm';
 import FeedbackList from '../components/FeedbackList';
 import CommentSection from '../components/CommentSection';
+import FeedbackAttachments from '../components/FeedbackAttachments';
 import BoardMemberManagement from '../components/BoardMemberManagement';
 
 function BoardDetails() {
@@ -27,6 +29,8 @@ function BoardDetails() {
   const isAppAdmin = authService.isAppAdmin();
   const isStakeholder = authService.isBoardStakeholder(boardIdNum);
   const currentUserId = authService.getUserId();
+  // Bumped per feedback item when its comments change, so the open thread reloads
+  const [commentVersions, setCommentVersions] = useState<Record<number, number>>({});
 
   useEffect(() => {
     // Redirect to sign-in if not authenticated
@@ -46,6 +50,48 @@ function BoardDetails() {
     }
   }, [boardId, navigate, isAppAdmin]);
 
+  // Keep the board up to date with changes made by others
+  useEffect(() => {
+    if (!boardIdNum || !authService.isAuthenticated()) {
+      return;
+    }
+    const reloadFeedbacks = () =>
+      feedbackService.getFeedbacksByBoardId(boardIdNum)
+        .then(setFeedbacks)
+        .catch(err => console.error('Error refreshing feedback:', err));
+
+    return subscribeToBoard(boardIdNum, event => {
+      switch (event.type) {
+        case 'feedback.voted':
+        case 'feedback.status': {
+          const { feedbackId, ...changes } = event.data;
+          const apply = (f: Feedback) => (f.id === feedbackId ? { ...f, ...changes } : f);
+          setFeedbacks(prev => prev.map(apply));
+          setSelectedFeedback(prev => (prev ? apply(prev) : prev));
+          break;
+        }
+        case 'comment.created':
+        case 'comment.reacted': {
+          const { feedbackId } = event.data;
+          setCommentVersions(prev => ({ ...prev, [feedbackId]: (prev[feedbackId] ?? 0) + 1 }));
+          break;
+        }
+        case 'feedback.created':
+          reloadFeedbacks();
+          break;
+        case 'reset':
+          // Changes were missed; reload everything shown
+          reloadFeedbacks();
+          setCommentVersions(prev => {
+            const bumped: Record<number, number> = {};
+            Object.keys(prev).forEach(id => { bumped[Number(id)] = prev[Number(id)] + 1; });
+            return bumped;
+          });
+          break;
+      }
+    });
+  }, [boardIdNum]);
+
   const fetchData = async () => {
     setIsLoading(true);
     setError(null);
@@ -57,9 +103,11 @@ function BoardDetails() {
         setBoard(boardData);
       }
 
-      // Fetch categories
-      const categoriesData = await categoryService.getAllCategories();
-      setCategories(categoriesData);
+      // Fetch this board's categories
+      if (boardId) {
+        const categoriesData = await categoryService.getCategoriesByBoardId(parseInt(boardId));
+        setCategories(categoriesData);
+      }
       
       // Fetch feedbacks for this board
       if (boardId) {
@@ -243,9 +291,23 @@ function BoardDetails() {
           
           <div className="mb-8">
             <h2 className="text-2xl font-bold mb-2">{selectedFeedback.title}</h2>
-            <div className="text-gray-700 mb-4">
-              {selectedFeedback.description}
-            </div>
+            {selectedFeedback.descriptionHtml ? (
+              <div
+                className="text-gray-700 mb-4 markdown"
+                dangerouslySetInnerHTML={{ __html: selectedFeedback.descriptionHtml }}
+              />
+            ) : (
+              <div className="text-gray-700 mb-4 whitespace-pre-wrap">
+                {selectedFeedback.description}
+              </div>
+            )}
+            <FeedbackAttachments
+              feedbackId={selectedFeedback.id}
+              canEdit={
+                isStakeholder || isAppAdmin ||
+                (selectedFeedback.userId === currentUserId && (selectedFeedback.status ?? 'pending') === 'pending')
+              }
+            />
             <div className="flex items-center justify-between">
               <div>
                 <span className="bg-blue-100 text-blue-800 text-xs px-2 py-1 rounded-full">
@@ -257,6 +319,7 @@ function BoardDetails() {
                   selectedFeedback.status === 'approved' ? 'bg-green-100 text-green-800' :
                   selectedFeedback.status === 'reviewing' ? 'bg-blue-100 text-blue-800' :
                   selectedFeedback.status === 'declined' ? 'bg-red-100 text-red-800' :
+                  selectedFeedback.status === 'completed' ? 'bg-purple-100 text-purple-800' :
                   'bg-gray-100 text-gray-800'
                 }`}>
                   {selectedFeedback.status || 'Pending'}
@@ -292,14 +355,18 @@ function BoardDetails() {
             </div>
           </div>
           
-          <CommentSection feedbackId={selectedFeedback.id} />
+          <CommentSection
+            feedbackId={selectedFeedback.id}
+            isStakeholder={isStakeholder || isAppAdmin}
+            refreshToken={commentVersions[selectedFeedback.id] ?? 0}
+          />
           
           {/* Status Control for Stakeholders and Admins */}
           {(isStakeholder || isAppAdmin) && (
             <div className="mt-4 border-t pt-4">
               <h3 className="text-sm font-semibold text-gray-500 mb-2">Update Status:</h3>
               <div className="flex flex-wrap gap-2">
-                {['pending', 'reviewing', 'approved', 'declined'].map(status => (
+                {['pending', 'reviewing', 'approved', 'declined', 'completed'].map(status => (
                   <button
                     key={status}
                     onClick={() => handleUpdateStatus(selectedFeedback.id, status)}
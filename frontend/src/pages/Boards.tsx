@@ -3,6 +3,7 @@ import { useForm } from 'react-hook-form';
 import { useNavigate } from 'react-router-dom';
 import { boardService, Board } from '../services/boardService';
 import { authService } from '../services/authService';
+import NotificationBell from '../components/NotificationBell';
 
 interface FormData {
   boardName: string;
@@ -84,6 +85,7 @@ function Boards() {
           )}
         </div>
         <div className="flex items-center">
+          <NotificationBell />
           <div className="mr-4 text-right">
             <span className="text-sm text-gray-600">
               {authService.getCurrentUser()?.email || 'Not signed in'}
@@ -0,0 +1,93 @@
+import { environment } from '../environments/environment';
+import { authService } from './authService';
+
+export interface Attachment {
+  id: number;
+  feedbackId: number;
+  commentId?: number; // unset for files attached to the feedback item itself
+  userId: number | null;
+  filename: string;
+  contentType: string;
+  size: number;
+  createdAt: string;
+}
+
+// Matches the server's limits
+export const MAX_ATTACHMENT_SIZE = 10 * 1024 * 1024;
+export const MAX_ATTACHMENTS = 5;
+export const ATTACHMENT_TYPES = ['image/png', 'image/jpeg', 'image/gif', 'image/webp', 'application/pdf', 'text/plain'];
+
+class AttachmentService {
+  // Files attached to a feedback item and its comments
+  async getFeedbackAttachments(feedbackId: number): Promise<Attachment[]> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}/attachments`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to fetch attachments');
+    }
+
+    return response.json();
+  }
+
+  async uploadToFeedback(feedbackId: number, file: File): Promise<Attachment> {
+    return this.upload(`${environment.apiUrl}/feedback/${feedbackId}/attachments`, file);
+  }
+
+  async uploadToComment(commentId: number, file: File): Promise<Attachment> {
+    return this.upload(`${environment.apiUrl}/comments/${commentId}/attachments`, file);
+  }
+
+  private async upload(url: string, file: File): Promise<Attachment> {
+    const body = new FormData();
+    body.append('file', file);
+
+    const response = await fetch(url, {
+      method: 'POST',
+      headers: {
+        ...authService.getAuthHeader()
+      },
+      body
+    });
+
+    if (!response.ok) {
+      const message = await response.text().catch(() => '');
+      throw new Error(message.trim() || 'Failed to upload file');
+    }
+
+    return response.json();
+  }
+
+  // Downloads need the auth header, so files are fetched and opened from an object URL
+  async getObjectUrl(attachmentId: number): Promise<string> {
+    const response = await fetch(`${environment.apiUrl}/attachments/${attachmentId}`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to download file');
+    }
+
+    return URL.createObjectURL(await response.blob());
+  }
+
+  async deleteAttachment(attachmentId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/attachments/${attachmentId}`, {
+      method: 'DELETE',
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to delete attachment');
+    }
+  }
+}
+
+export const attachmentService = new AttachmentService();
@@ -0,0 +1,100 @@
+import { environment } from '../environments/environment';
+import { authService } from './authService';
+
+export type BoardEventType =
+  | 'feedback.created'
+  | 'feedback.voted'
+  | 'feedback.status'
+  | 'comment.created'
+  | 'comment.reacted'
+  | 'reset'; // events were missed and can't be replayed; reload instead
+
+export interface BoardEvent {
+  type: BoardEventType;
+  data: any;
+}
+
+const DEFAULT_RETRY_MS = 3000;
+
+// Follow a board's Server-Sent Events stream. EventSource can't send the auth
+// header, so the stream is read with fetch; reconnects send Last-Event-ID so the
+// server replays what was missed. Returns a function that stops following.
+export const subscribeToBoard = (boardId: number, onEvent: (event: BoardEvent) => void): (() => void) => {
+  const controller = new AbortController();
+  let lastEventId = '';
+  let retryMs = DEFAULT_RETRY_MS;
+
+  const dispatch = (block: string) => {
+    let type = 'message';
+    let data = '';
+    for (const line of block.split('\n')) {
+      if (line.startsWith(':')) {
+        continue; // heartbeat
+      }
+      const colon = line.indexOf(':');
+      const field = colon < 0 ? line : line.slice(0, colon);
+      const value = colon < 0 ? '' : line.slice(colon + 1).replace(/^ /, '');
+      if (field === 'id') {
+        lastEventId = value;
+      } else if (field === 'event') {
+        type = value;
+      } else if (field === 'data') {
+        data += data ? `\n${value}` : value;
+      } else if (field === 'retry' && /^\d+$/.test(value)) {
+        retryMs = Number(value);
+      }
+    }
+    if (data) {
+      try {
+        onEvent({ type: type as BoardEventType, data: JSON.parse(data) });
+      } catch (err) {
+        console.error('Error handling board event:', err);
+      }
+    }
+  };
+
+  const connect = async () => {
+    while (!controller.signal.aborted) {
+      try {
+        const response = await fetch(`${environment.apiUrl}/boards/${boardId}/events`, {
+          headers: {
+            ...authService.getAuthHeader(),
+            ...(lastEventId ? { 'Last-Event-ID': lastEventId } : {})
+          },
+          signal: controller.signal
+        });
+        if (response.status === 401 || response.status === 403) {
+          return; // no longer allowed on this board
+        }
+        if (!response.ok || !response.body) {
+          throw new Error('Failed to open board events');
+        }
+
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        for (;;) {
+          const { done, value } = await reader.read();
+          if (done) {
+            break;
+          }
+          buffer += decoder.decode(value, { stream: true }).replace(/\r\n?/g, '\n');
+          let end;
+          while ((end = buffer.indexOf('\n\n')) >= 0) {
+            dispatch(buffer.slice(0, end));
+            buffer = buffer.slice(end + 2);
+          }
+        }
+      } catch (err) {
+        if (controller.signal.aborted) {
+          return;
+        }
+        console.error('Board events disconnected:', err);
+      }
+      await new Promise(resolve => setTimeout(resolve, retryMs));
+    }
+  };
+
+  connect();
+  return () => controller.abort();
+};
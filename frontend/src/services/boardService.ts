@@ -1,9 +1,12 @@
 import { environment } from '../environments/environment';
 import { authService } from './authService';
 
+export type MentionPolicy = 'plain' | 'reject';
+
 export interface Board {
   id: number;
   name: string;
+  mentionPolicy?: MentionPolicy;
 }
 
 export interface BoardMember {
@@ -62,6 +65,22 @@ class BoardService {
     return response.json();
   }
   
+  // Update a board's name and, optionally, how mentions of non-members are handled
+  async updateBoard(boardId: number, name: string, mentionPolicy?: MentionPolicy): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/boards/${boardId}`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify({ name, mentionPolicy })
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to update board');
+    }
+  }
+  
   // Get board members (for stakeholders and admins)
   async getBoardMembers(boardId: number): Promise<BoardMember[]> {
     // Check if user has permission
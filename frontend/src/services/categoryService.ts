@@ -3,13 +3,23 @@ import { authService } from './authService';
 
 export interface Category {
   id: number;
+  boardId: number;
   name: string;
+  color: string;
+  description: string;
+  sortOrder: number;
+}
+
+export interface CategoryInput {
+  name: string;
+  color?: string;
+  description?: string;
 }
 
 class CategoryService {
-  // Get all categories
-  async getAllCategories(): Promise<Category[]> {
-    const response = await fetch(`${environment.apiUrl}/categories`, {
+  // Get a board's categories in display order
+  async getCategoriesByBoardId(boardId: number): Promise<Category[]> {
+    const response = await fetch(`${environment.apiUrl}/boards/${boardId}/categories`, {
       headers: {
         ...authService.getAuthHeader()
       }
@@ -21,6 +31,73 @@ class CategoryService {
     
     return response.json();
   }
+
+  // Create a category on a board
+  async createCategory(boardId: number, category: CategoryInput): Promise<Category> {
+    const response = await fetch(`${environment.apiUrl}/boards/${boardId}/categories`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify(category)
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to create category');
+    }
+    
+    return response.json();
+  }
+
+  // Update a category's name, colour or description
+  async updateCategory(boardId: number, categoryId: number, category: CategoryInput): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/boards/${boardId}/categories/${categoryId}`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify(category)
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to update category');
+    }
+  }
+
+  // Set the display order of every category on a board
+  async reorderCategories(boardId: number, categoryIds: number[]): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/boards/${boardId}/categories/order`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify({ categoryIds })
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to reorder categories');
+    }
+  }
+
+  // Delete a category, moving its feedback to the fallback category
+  async deleteCategory(boardId: number, categoryId: number, fallbackCategoryId: number): Promise<void> {
+    const response = await fetch(
+      `${environment.apiUrl}/boards/${boardId}/categories/${categoryId}?fallbackCategoryId=${fallbackCategoryId}`,
+      {
+        method: 'DELETE',
+        headers: {
+          ...authService.getAuthHeader()
+        }
+      }
+    );
+    
+    if (!response.ok) {
+      throw new Error('Failed to delete category');
+    }
+  }
 }
 
 export const categoryService = new CategoryService();
@@ -1,35 +1,65 @@
 import { environment } from '../environments/environment';
 import { authService } from './authService';
 
-export interface Reply {
+export interface CommentAuthor {
   id: number;
-  commentId: number;
+  name: string;
+  picture: string;
+  badge?: 'admin' | 'stakeholder';
+}
+
+export interface CommentMention {
   userId: number;
-  content: string;
-  likes: number;
-  dislikes: number;
-  createdAt: string;
-  isLiked?: boolean;
-  isDisliked?: boolean;
+  name: string;
+  text: string; // as written after the @
 }
 
 export interface Comment {
   id: number;
   feedbackId: number;
+  parentId: number | null;
   userId: number;
+  author: CommentAuthor | null;
   content: string;
+  contentHtml: string; // Sanitized HTML rendered by the server from the Markdown content
   likes: number;
   dislikes: number;
+  depth: number;
   createdAt: string;
   isLiked?: boolean;
   isDisliked?: boolean;
-  replies: Reply[];
+  editedAt?: string;
+  deleted?: boolean;
+  official?: boolean;
+  internal?: boolean;
+  mentions: CommentMention[];
+  replies: Comment[];
+}
+
+export type CommentSort = 'new' | 'old' | 'top';
+
+export interface CommentPage {
+  comments: Comment[];
+  nextCursor?: string;
+  // Set when a thread on the page was too long to load in full
+  truncated?: boolean;
 }
 
+export interface CommentEdit {
+  previousContent: string;
+  editedBy: number | null;
+  editedAt: string;
+}
+
+
 class CommentService {
-  // Get comments for a feedback
-  async getCommentsByFeedbackId(feedbackId: number): Promise<Comment[]> {
-    const response = await fetch(`${environment.apiUrl}/comments?feedbackId=${feedbackId}`, {
+  // Get one page of the comment threads of a feedback
+  async getCommentsByFeedbackId(feedbackId: number, sort: CommentSort = 'new', cursor?: string): Promise<CommentPage> {
+    const params = new URLSearchParams({ feedbackId: String(feedbackId), sort });
+    if (cursor) {
+      params.set('cursor', cursor);
+    }
+    const response = await fetch(`${environment.apiUrl}/comments?${params}`, {
       headers: {
         ...authService.getAuthHeader()
       }
@@ -42,8 +72,8 @@ class CommentService {
     return response.json();
   }
   
-  // Add a comment to a feedback
-  async addComment(feedbackId: number, content: string): Promise<number> {
+  // Add a comment to a feedback; internal notes are only visible to stakeholders
+  async addComment(feedbackId: number, content: string, internal = false): Promise<number> {
     const response = await fetch(`${environment.apiUrl}/comment`, {
       method: 'POST',
       headers: {
@@ -52,7 +82,8 @@ class CommentService {
       },
       body: JSON.stringify({
         feedbackId,
-        content
+        content,
+        internal
       })
     });
     
@@ -65,16 +96,17 @@ class CommentService {
     return result.id;
   }
   
-  // Add a reply to a comment
-  async addReply(commentId: number, content: string): Promise<number> {
-    const response = await fetch(`${environment.apiUrl}/reply`, {
+  // Reply to a comment at any depth
+  async addReply(feedbackId: number, parentId: number, content: string): Promise<number> {
+    const response = await fetch(`${environment.apiUrl}/comment`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
         ...authService.getAuthHeader()
       },
       body: JSON.stringify({
-        commentId,
+        feedbackId,
+        parentId,
         content
       })
     });
@@ -107,24 +139,50 @@ class CommentService {
     }
   }
   
-  // Like/dislike a reply
-  async likeReply(replyId: number, isLike: boolean): Promise<void> {
-    const response = await fetch(`${environment.apiUrl}/comment-like`, {
-      method: 'POST',
+  // Edit a comment (authors only, within the edit window)
+  async editComment(id: number, content: string): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/comments/${id}`, {
+      method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
         ...authService.getAuthHeader()
       },
-      body: JSON.stringify({
-        replyId,
-        isLike
-      })
+      body: JSON.stringify({ content })
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to edit comment');
+    }
+  }
+  
+  // Delete a comment (authors, or stakeholders of the board)
+  async deleteComment(id: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/comments/${id}`, {
+      method: 'DELETE',
+      headers: {
+        ...authService.getAuthHeader()
+      }
     });
     
     if (!response.ok) {
-      throw new Error('Failed to like/dislike reply');
+      throw new Error('Failed to delete comment');
     }
   }
+  
+  // Get the earlier versions of a comment
+  async getCommentHistory(id: number): Promise<CommentEdit[]> {
+    const response = await fetch(`${environment.apiUrl}/comments/${id}/history`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to fetch comment history');
+    }
+    
+    return response.json();
+  }
 }
 
 export const commentService = new CommentService();
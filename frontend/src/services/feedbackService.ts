@@ -1,18 +1,77 @@
 import { environment } from '../environments/environment';
 import { authService } from './authService';
 
-export type FeedbackStatus = 'pending' | 'reviewing' | 'approved' | 'declined';
+export type FeedbackStatus = 'pending' | 'reviewing' | 'approved' | 'declined' | 'completed';
+
+export interface FeedbackAuthor {
+  id: number;
+  name: string;
+  picture?: string;
+}
+
+export interface OfficialResponse {
+  commentId: number;
+  content: string;
+  contentHtml: string;
+  author: { id: number; name: string; picture: string; badge?: 'admin' | 'stakeholder' } | null;
+  createdAt: string;
+  editedAt?: string;
+}
 
 export interface Feedback {
   id: number;
   boardId: number;
   title: string;
-  description: string;
+  description: string; // Markdown source
+  descriptionHtml: string; // Sanitized HTML rendered by the server
   categoryId: number;
   upvotes: number;
   downvotes: number;
   status?: FeedbackStatus;
+  createdAt?: string;
+  mergedInto?: number;
+  updatedAt?: string;
+  deletedAt?: string;
+  userId?: number | null;
+  author?: FeedbackAuthor | null;
   userVote?: 'upvote' | 'downvote' | null;
+  officialResponse?: OfficialResponse;
+}
+
+export type FeedbackSort = 'top' | 'new' | 'trending';
+
+export interface FeedbackQuery {
+  status?: FeedbackStatus;
+  categoryId?: number;
+  sort?: FeedbackSort;
+  cursor?: string;
+  limit?: number;
+}
+
+export interface FeedbackPage {
+  feedbacks: Feedback[];
+  nextCursor?: string;
+}
+
+export interface DiffLine {
+  op: 'equal' | 'insert' | 'delete';
+  text: string;
+}
+
+export interface FeedbackRevision {
+  id: number;
+  feedbackId: number;
+  revision: number;
+  title: string;
+  description: string;
+  categoryId: number;
+  editor: FeedbackAuthor | null;
+  createdAt: string;
+  changes: {
+    title?: { from: string; to: string };
+    category?: { from: number; to: number };
+    description?: DiffLine[];
+  } | null;
 }
 
 export interface FeedbackSubmission {
@@ -23,9 +82,30 @@ export interface FeedbackSubmission {
 }
 
 class FeedbackService {
-  // Get all feedback for a board
-  async getFeedbacksByBoardId(boardId: number): Promise<Feedback[]> {
-    const response = await fetch(`${environment.apiUrl}/feedbacks?boardId=${boardId}`, {
+  // Get a page of feedback for a board
+  async getFeedbackPage(boardId: number, query: FeedbackQuery = {}): Promise<FeedbackPage> {
+    return this.fetchPage('/feedbacks', query, { boardId: String(boardId) });
+  }
+
+  // Get a page of the feedback the current user posted
+  async getMyFeedback(query: FeedbackQuery = {}): Promise<FeedbackPage> {
+    return this.fetchPage('/me/feedback', query);
+  }
+
+  // Get a page of the feedback the current user voted on
+  async getMyVotes(query: FeedbackQuery = {}): Promise<FeedbackPage> {
+    return this.fetchPage('/me/votes', query);
+  }
+
+  private async fetchPage(path: string, query: FeedbackQuery, base: Record<string, string> = {}): Promise<FeedbackPage> {
+    const params = new URLSearchParams(base);
+    Object.entries(query).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        params.set(key, String(value));
+      }
+    });
+
+    const response = await fetch(`${environment.apiUrl}${path}?${params.toString()}`, {
       headers: {
         ...authService.getAuthHeader()
       }
@@ -37,6 +117,12 @@ class FeedbackService {
     
     return response.json();
   }
+
+  // Get the first page of feedback for a board
+  async getFeedbacksByBoardId(boardId: number, query: FeedbackQuery = {}): Promise<Feedback[]> {
+    const page = await this.getFeedbackPage(boardId, query);
+    return page.feedbacks;
+  }
   
   // Submit new feedback
   async submitFeedback(feedback: FeedbackSubmission): Promise<void> {
@@ -126,6 +212,113 @@ class FeedbackService {
       throw error;
     }
   }
+
+  // Edit a feedback item (its author while pending, or stakeholders)
+  async updateFeedback(feedbackId: number, update: Omit<FeedbackSubmission, 'boardId'>): Promise<Feedback> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify(update)
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to update feedback');
+    }
+
+    return response.json();
+  }
+
+  // Delete a feedback item; admins can restore it later
+  async deleteFeedback(feedbackId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}`, {
+      method: 'DELETE',
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to delete feedback');
+    }
+  }
+
+  // Restore a deleted feedback item (admins only)
+  async restoreFeedback(feedbackId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/admin/feedback/${feedbackId}/restore`, {
+      method: 'POST',
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to restore feedback');
+    }
+  }
+
+  // Pin a comment as the official response (stakeholders and admins only)
+  async pinOfficialResponse(feedbackId: number, commentId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}/official-response`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify({ commentId })
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to pin official response');
+    }
+  }
+
+  // Unpin the official response (stakeholders and admins only)
+  async unpinOfficialResponse(feedbackId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}/official-response`, {
+      method: 'DELETE',
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to unpin official response');
+    }
+  }
+
+  // Get the edit history of a feedback item
+  async getFeedbackRevisions(feedbackId: number): Promise<FeedbackRevision[]> {
+    const response = await fetch(`${environment.apiUrl}/feedback/${feedbackId}/revisions`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to fetch feedback revisions');
+    }
+
+    return response.json();
+  }
+
+  // Merge a duplicate feedback into another (stakeholders and admins only)
+  async mergeFeedback(feedbackId: number, targetId: number): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/feedbacks/${feedbackId}/merge`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify({ targetId })
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to merge feedback');
+    }
+  }
 }
 
 export const feedbackService = new FeedbackService();
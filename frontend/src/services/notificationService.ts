@@ -0,0 +1,108 @@
+import { environment } from '../environments/environment';
+import { authService } from './authService';
+
+export type NotificationType = 'mention' | 'reply' | 'status_change' | 'merged' | 'completed';
+
+export interface Notification {
+  id: number;
+  type: NotificationType;
+  actorId: number | null;
+  actorName?: string;
+  feedbackId?: number;
+  feedbackTitle?: string;
+  boardId?: number;
+  commentId?: number;
+  status?: string; // the new status, for status changes
+  mergedInto?: number;
+  createdAt: string;
+  readAt?: string;
+}
+
+export interface NotificationPage {
+  notifications: Notification[];
+  unreadCount: number;
+  nextCursor?: string;
+}
+
+export type EmailDelivery = 'immediate' | 'digest' | 'off';
+
+export interface EmailPreferences {
+  delivery: EmailDelivery;
+  available: boolean; // false when the server does not send email
+}
+
+class NotificationService {
+  async getNotifications(cursor?: string): Promise<NotificationPage> {
+    const params = new URLSearchParams();
+    if (cursor) {
+      params.set('cursor', cursor);
+    }
+    const response = await fetch(`${environment.apiUrl}/me/notifications?${params}`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to fetch notifications');
+    }
+
+    return response.json();
+  }
+
+  // Returns the number of notifications still unread
+  async markRead(notificationId: number): Promise<number> {
+    return this.post(`${environment.apiUrl}/me/notifications/${notificationId}/read`);
+  }
+
+  async markAllRead(): Promise<number> {
+    return this.post(`${environment.apiUrl}/me/notifications/read-all`);
+  }
+
+  async getEmailPreferences(): Promise<EmailPreferences> {
+    const response = await fetch(`${environment.apiUrl}/me/email-preferences`, {
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to fetch email preferences');
+    }
+
+    return response.json();
+  }
+
+  async updateEmailDelivery(delivery: EmailDelivery): Promise<void> {
+    const response = await fetch(`${environment.apiUrl}/me/email-preferences`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authService.getAuthHeader()
+      },
+      body: JSON.stringify({ delivery })
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to update email preferences');
+    }
+  }
+
+  private async post(url: string): Promise<number> {
+    const response = await fetch(url, {
+      method: 'POST',
+      headers: {
+        ...authService.getAuthHeader()
+      }
+    });
+
+    if (!response.ok) {
+      throw new Error('Failed to update notifications');
+    }
+
+    const body = await response.json();
+    return body.unreadCount;
+  }
+}
+
+export const notificationService = new NotificationService();